@@ -0,0 +1,151 @@
+package apierror
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// Code is a stable, machine-readable error identifier. Clients switch on the
+// code; the message is for people and may change.
+type Code string
+
+const (
+	CodeBadRequest           Code = "bad_request"
+	CodeValidation           Code = "validation_failed"
+	CodeUnauthorized         Code = "unauthorized"
+	CodeForbidden            Code = "forbidden"
+	CodeNotFound             Code = "not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodeConflict             Code = "conflict"
+	CodePayloadTooLarge      Code = "payload_too_large"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeUnavailable          Code = "unavailable"
+	CodeInternal             Code = "internal_error"
+)
+
+// Error is an error that is safe to show to API clients. Err holds the
+// underlying cause; it is logged but never sent.
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details any
+	Err     error
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %v", e.Message, e.Err)
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// WithDetails returns a copy of e carrying extra data for the client, such
+// as per-field validation messages
+func (e *Error) WithDetails(details any) *Error {
+	c := *e
+	c.Details = details
+	return &c
+}
+
+func newError(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func BadRequest(message string) *Error {
+	return newError(http.StatusBadRequest, CodeBadRequest, message)
+}
+
+// Validation reports invalid input; fields maps each field name to its problem
+func Validation(message string, fields map[string]string) *Error {
+	return newError(http.StatusUnprocessableEntity, CodeValidation, message).WithDetails(map[string]any{"fields": fields})
+}
+
+func Unauthorized(message string) *Error {
+	return newError(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func Forbidden(message string) *Error {
+	return newError(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return newError(http.StatusNotFound, CodeNotFound, message)
+}
+
+func MethodNotAllowed() *Error {
+	return newError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method not allowed")
+}
+
+func Conflict(message string) *Error {
+	return newError(http.StatusConflict, CodeConflict, message)
+}
+
+func PayloadTooLarge(message string) *Error {
+	return newError(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+func UnsupportedMediaType(message string) *Error {
+	return newError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, message)
+}
+
+func Unavailable(message string) *Error {
+	return newError(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Internal wraps an unexpected failure. message is sent to the client, err
+// is only logged.
+func Internal(message string, err error) *Error {
+	e := newError(http.StatusInternalServerError, CodeInternal, message)
+	e.Err = err
+	return e
+}
+
+// From maps any error to an *Error. Errors that are not already an *Error
+// are mapped by kind; anything unrecognised becomes an internal error.
+func From(err error) *Error {
+	var apiErr *Error
+	var maxBytes *http.MaxBytesError
+	switch {
+	case errors.As(err, &apiErr):
+		return apiErr
+	case errors.Is(err, sql.ErrNoRows):
+		e := NotFound("Not found")
+		e.Err = err
+		return e
+	case errors.As(err, &maxBytes):
+		return PayloadTooLarge(fmt.Sprintf("Request body is larger than %d bytes", maxBytes.Limit))
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		e := Unavailable("Request was cancelled or timed out")
+		e.Err = err
+		return e
+	default:
+		return Internal("Internal server error", err)
+	}
+}
+
+// Response is the JSON error envelope returned by every endpoint
+type Response struct {
+	Code      Code   `json:"code"`
+	Message   string `json:"message"`
+	Details   any    `json:"details,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// Problem is the RFC 7807 form of Response, sent to clients that accept
+// application/problem+json
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail"`
+	Instance string `json:"instance,omitempty"`
+	Response
+}
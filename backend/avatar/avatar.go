@@ -0,0 +1,124 @@
+// Package avatar stores profile pictures. Uploads are decoded and
+// re-encoded as PNG, which drops EXIF and any other metadata, cropped to a
+// square and saved at each of Sizes. Users without an upload get a
+// generated identicon instead.
+package avatar
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Sizes are the widths, in pixels, every avatar is stored at
+var Sizes = []int{64, 128, 256}
+
+// DisplaySize is the size stored as the user's avatar_url
+const DisplaySize = 128
+
+var (
+	ErrUnsupported = errors.New("unsupported image format")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+	ErrCrop        = errors.New("crop is outside the image")
+)
+
+// Renderer draws an avatar as a size by size square
+type Renderer func(size int) image.Image
+
+// variantURL matches the URL of one size of a stored avatar
+var variantURL = regexp.MustCompile(`^/static/avatars/avatar_(\d+)_(\d+)\.png$`)
+
+func variant(key string, size int) string {
+	return fmt.Sprintf("avatar_%s_%d.png", key, size)
+}
+
+// Save renders the avatar at every size into uploadDir/avatars and returns
+// the URL of its DisplaySize image
+func Save(uploadDir string, render Renderer) (string, error) {
+	dir := filepath.Join(uploadDir, "avatars")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+
+	key := strconv.FormatInt(time.Now().UnixNano(), 10)
+	for i, size := range Sizes {
+		if err := writePNG(filepath.Join(dir, variant(key, size)), render(size)); err != nil {
+			for _, written := range Sizes[:i] {
+				os.Remove(filepath.Join(dir, variant(key, written)))
+			}
+			return "", err
+		}
+	}
+	return "/static/avatars/" + variant(key, DisplaySize), nil
+}
+
+func writePNG(name string, img image.Image) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		os.Remove(name)
+		return err
+	}
+	return f.Close()
+}
+
+// URLs returns the URL of each size of the avatar stored at url, keyed by
+// size. Avatars from before sizes were introduced use url for every size.
+func URLs(url string) map[string]string {
+	urls := make(map[string]string, len(Sizes))
+	m := variantURL.FindStringSubmatch(url)
+	for _, size := range Sizes {
+		if m == nil {
+			urls[strconv.Itoa(size)] = url
+		} else {
+			urls[strconv.Itoa(size)] = "/static/avatars/" + variant(m[1], size)
+		}
+	}
+	return urls
+}
+
+// DisplayURL maps the URL of any size of a stored avatar to the URL kept in
+// avatar_url. Other URLs are returned unchanged.
+func DisplayURL(url string) string {
+	if m := variantURL.FindStringSubmatch(url); m != nil {
+		return "/static/avatars/" + variant(m[1], DisplaySize)
+	}
+	return url
+}
+
+// Remove deletes the files of the avatar at url from uploadDir. The
+// bundled default picture and other files that were not uploaded as
+// avatars are left alone.
+func Remove(uploadDir, url string) error {
+	if m := variantURL.FindStringSubmatch(url); m != nil {
+		var errs []error
+		for _, size := range Sizes {
+			err := os.Remove(filepath.Join(uploadDir, "avatars", variant(m[1], size)))
+			if err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	// Uploads from before avatars were resized were kept as sent, in uploadDir
+	rel, ok := strings.CutPrefix(url, "/static/")
+	if !ok || strings.Contains(rel, "/") || !strings.HasPrefix(path.Base(rel), "avatar_") || !filepath.IsLocal(rel) {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(uploadDir, rel)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
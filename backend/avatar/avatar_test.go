@@ -0,0 +1,360 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// exifMarker stands in for camera and location details in test uploads
+const exifMarker = "GPS 52.5200N 13.4050E"
+
+// withEXIF inserts an EXIF segment holding orientation, and exifMarker
+// after its IFD, right after the start of a JPEG
+func withEXIF(t *testing.T, jpg []byte, order binary.ByteOrder, orientation int) []byte {
+	t.Helper()
+	tiff := make([]byte, 26)
+	if order == binary.LittleEndian {
+		copy(tiff, "II")
+	} else {
+		copy(tiff, "MM")
+	}
+	order.PutUint16(tiff[2:], 42)
+	order.PutUint32(tiff[4:], 8) // first IFD
+	order.PutUint16(tiff[8:], 1) // one entry
+	order.PutUint16(tiff[10:], 0x0112)
+	order.PutUint16(tiff[12:], 3) // SHORT
+	order.PutUint32(tiff[14:], 1) // one value
+	order.PutUint16(tiff[18:], uint16(orientation))
+	tiff = append(tiff, exifMarker...)
+
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(2+len(segment)))
+	app1 = append(app1, segment...)
+
+	out := slices.Clone(jpg[:2])
+	out = append(out, app1...)
+	return append(out, jpg[2:]...)
+}
+
+// encodeJPEG encodes img at high quality
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// encodePNG encodes img as PNG
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// halves returns a w by h image, red on the left and blue on the right
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		for x := range w {
+			c := color.RGBA{0xFF, 0, 0, 0xFF}
+			if x >= w/2 {
+				c = color.RGBA{0, 0, 0xFF, 0xFF}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	jpg := encodeJPEG(t, halves(8, 4))
+	for orientation := 1; orientation <= 8; orientation++ {
+		for _, order := range []binary.ByteOrder{binary.LittleEndian, binary.BigEndian} {
+			if got := jpegOrientation(withEXIF(t, jpg, order, orientation)); got != orientation {
+				t.Errorf("%v orientation %d read as %d", order, orientation, got)
+			}
+		}
+	}
+	for name, data := range map[string][]byte{
+		"JPEG without EXIF": jpg,
+		"PNG":               encodePNG(t, halves(8, 4)),
+		"truncated JPEG":    withEXIF(t, jpg, binary.BigEndian, 6)[:20],
+		"empty":             nil,
+	} {
+		if got := jpegOrientation(data); got != 1 {
+			t.Errorf("%s: orientation %d, want 1", name, got)
+		}
+	}
+}
+
+func TestOrient(t *testing.T) {
+	// Each pixel of the stored image is labelled by its red channel:
+	//   a b c
+	//   d e f
+	src := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	for i, label := range "abcdef" {
+		src.SetRGBA(i%3, i/3, color.RGBA{uint8(label), 0, 0, 0xFF})
+	}
+	tests := []struct {
+		orientation int
+		want        []string // rows of the upright image
+	}{
+		{0, []string{"abc", "def"}},
+		{1, []string{"abc", "def"}},
+		{2, []string{"cba", "fed"}},
+		{3, []string{"fed", "cba"}},
+		{4, []string{"def", "abc"}},
+		{5, []string{"ad", "be", "cf"}},
+		{6, []string{"da", "eb", "fc"}},
+		{7, []string{"fc", "eb", "da"}},
+		{8, []string{"cf", "be", "ad"}},
+		{9, []string{"abc", "def"}},
+	}
+	for _, tt := range tests {
+		dst := orient(src, tt.orientation)
+		b := dst.Bounds()
+		var got []string
+		for y := b.Min.Y; y < b.Max.Y; y++ {
+			var row strings.Builder
+			for x := b.Min.X; x < b.Max.X; x++ {
+				row.WriteByte(dst.RGBAAt(x, y).R)
+			}
+			got = append(got, row.String())
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("orientation %d = %q, want %q", tt.orientation, got, tt.want)
+		}
+	}
+}
+
+func TestDecodeOrientation(t *testing.T) {
+	isRed := func(c color.RGBA) bool { return c.R > 200 && c.B < 60 }
+	isBlue := func(c color.RGBA) bool { return c.B > 200 && c.R < 60 }
+
+	// The stored image is wider than tall, red on the left. Turning it
+	// upright moves the red half to the side given here.
+	jpg := encodeJPEG(t, halves(64, 32))
+	tests := []struct {
+		orientation int
+		redSide     string
+	}{
+		{1, "left"},
+		{2, "right"},
+		{3, "right"},
+		{4, "left"},
+		{5, "top"},
+		{6, "top"},
+		{7, "bottom"},
+		{8, "bottom"},
+	}
+	for _, tt := range tests {
+		render, err := Decode(withEXIF(t, jpg, binary.BigEndian, tt.orientation), image.Rectangle{})
+		if err != nil {
+			t.Fatalf("orientation %d: %v", tt.orientation, err)
+		}
+		img, ok := render(64).(*image.RGBA)
+		if !ok {
+			t.Fatalf("orientation %d: rendered %T, want *image.RGBA", tt.orientation, render(64))
+		}
+		red, blue := image.Pt(4, 32), image.Pt(59, 32)
+		switch tt.redSide {
+		case "right":
+			red, blue = blue, red
+		case "top":
+			red, blue = image.Pt(32, 4), image.Pt(32, 59)
+		case "bottom":
+			red, blue = image.Pt(32, 59), image.Pt(32, 4)
+		}
+		if c := img.RGBAAt(red.X, red.Y); !isRed(c) {
+			t.Errorf("orientation %d: %v at %v, want red", tt.orientation, c, red)
+		}
+		if c := img.RGBAAt(blue.X, blue.Y); !isBlue(c) {
+			t.Errorf("orientation %d: %v at %v, want blue", tt.orientation, c, blue)
+		}
+	}
+
+	// Crops are in pixels of the upright image: the top square of the
+	// turned image is all red
+	render, err := Decode(withEXIF(t, jpg, binary.BigEndian, 6), image.Rect(0, 0, 32, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := render(64).(*image.RGBA)
+	for _, p := range []image.Point{{4, 4}, {59, 59}} {
+		if c := img.RGBAAt(p.X, p.Y); !isRed(c) {
+			t.Errorf("cropped: %v at %v, want red", c, p)
+		}
+	}
+	if _, err := Decode(withEXIF(t, jpg, binary.BigEndian, 6), image.Rect(0, 40, 32, 72)); !errors.Is(err, ErrCrop) {
+		t.Errorf("crop below the turned image: %v, want ErrCrop", err)
+	}
+}
+
+func TestDecodeRejects(t *testing.T) {
+	gray := func(w, h int) []byte { return encodePNG(t, image.NewGray(image.Rect(0, 0, w, h))) }
+	tests := []struct {
+		name string
+		data []byte
+		crop image.Rectangle
+		want error
+	}{
+		{"largest allowed", gray(maxSide, maxSide), image.Rectangle{}, nil},
+		{"too wide", gray(maxSide+1, 1), image.Rectangle{}, ErrTooLarge},
+		{"too tall", gray(1, maxSide+1), image.Rectangle{}, ErrTooLarge},
+		{"text", []byte("not an image"), image.Rectangle{}, ErrUnsupported},
+		{"truncated PNG", gray(16, 16)[:40], image.Rectangle{}, ErrUnsupported},
+		{"crop not square", gray(16, 16), image.Rect(0, 0, 8, 4), ErrCrop},
+		{"crop outside", gray(16, 16), image.Rect(8, 8, 24, 24), ErrCrop},
+	}
+	for _, tt := range tests {
+		if _, err := Decode(tt.data, tt.crop); !errors.Is(err, tt.want) {
+			t.Errorf("%s: %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+// pngChunks returns the chunk types of a PNG file in order
+func pngChunks(t *testing.T, data []byte) []string {
+	t.Helper()
+	const signature = "\x89PNG\r\n\x1a\n"
+	if !bytes.HasPrefix(data, []byte(signature)) {
+		t.Fatal("not a PNG")
+	}
+	var chunks []string
+	for i := len(signature); i+8 <= len(data); {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		chunks = append(chunks, string(data[i+4:i+8]))
+		i += 12 + length
+	}
+	return chunks
+}
+
+func TestSave(t *testing.T) {
+	upload := withEXIF(t, encodeJPEG(t, halves(300, 200)), binary.LittleEndian, 6)
+	uploaded, err := Decode(upload, image.Rectangle{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, render := range map[string]Renderer{
+		"upload":    uploaded,
+		"identicon": Identicon("ann"),
+	} {
+		uploadDir := t.TempDir()
+		url, err := Save(uploadDir, render)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasSuffix(url, "_"+strconv.Itoa(DisplaySize)+".png") || DisplayURL(url) != url {
+			t.Errorf("%s: saved as %q, want its %d pixel URL", name, url, DisplaySize)
+		}
+
+		urls := URLs(url)
+		if len(urls) != len(Sizes) {
+			t.Errorf("%s: URLs = %v, want one per size", name, urls)
+		}
+		for _, size := range Sizes {
+			sizeURL := urls[strconv.Itoa(size)]
+			if DisplayURL(sizeURL) != url {
+				t.Errorf("%s: DisplayURL(%q) = %q, want %q", name, sizeURL, DisplayURL(sizeURL), url)
+			}
+			file := filepath.Join(uploadDir, filepath.FromSlash(strings.TrimPrefix(sizeURL, "/static/")))
+			data, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("%s: %v", name, err)
+			}
+			cfg, err := png.DecodeConfig(bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("%s: %s: %v", name, file, err)
+			}
+			if cfg.Width != size || cfg.Height != size {
+				t.Errorf("%s: %s is %dx%d, want %dx%d", name, file, cfg.Width, cfg.Height, size, size)
+			}
+
+			// Nothing of the upload's metadata survives re-encoding
+			if chunks := pngChunks(t, data); slices.ContainsFunc(chunks, func(c string) bool {
+				return c != "IHDR" && c != "IDAT" && c != "IEND"
+			}) {
+				t.Errorf("%s: %s has chunks %q, want only IHDR, IDAT and IEND", name, file, chunks)
+			}
+			for _, s := range []string{"Exif", exifMarker} {
+				if bytes.Contains(data, []byte(s)) {
+					t.Errorf("%s: %s contains %q", name, file, s)
+				}
+			}
+		}
+
+		if err := Remove(uploadDir, url); err != nil {
+			t.Fatal(err)
+		}
+		if entries, _ := os.ReadDir(filepath.Join(uploadDir, "avatars")); len(entries) != 0 {
+			t.Errorf("%s: %d files left after Remove", name, len(entries))
+		}
+	}
+}
+
+func TestIdenticon(t *testing.T) {
+	background := color.RGBA{0xF0, 0xF0, 0xF0, 0xFF}
+	pixels := func(render Renderer, size int) *image.RGBA {
+		t.Helper()
+		img, ok := render(size).(*image.RGBA)
+		if !ok {
+			t.Fatalf("rendered %T, want *image.RGBA", render(size))
+		}
+		if b := img.Bounds(); b.Dx() != size || b.Dy() != size {
+			t.Fatalf("rendered %v at size %d", b, size)
+		}
+		return img
+	}
+
+	for _, size := range Sizes {
+		pixels(Identicon("ann"), size)
+	}
+
+	// 120 pixels leave a 100 pixel grid, which splits evenly into cells
+	const size = 120
+	ann := pixels(Identicon("ann"), size)
+	if !bytes.Equal(ann.Pix, pixels(Identicon("ann"), size).Pix) {
+		t.Error("the same seed drew different identicons")
+	}
+	if bytes.Equal(ann.Pix, pixels(Identicon("ben"), size).Pix) {
+		t.Error("different seeds drew the same identicon")
+	}
+
+	colors := map[color.RGBA]int{}
+	for y := range size {
+		for x := range size {
+			c := ann.RGBAAt(x, y)
+			colors[c]++
+			if mirror := ann.RGBAAt(size-1-x, y); mirror != c {
+				t.Fatalf("pixel (%d, %d) is %v but its mirror is %v", x, y, c, mirror)
+			}
+		}
+	}
+	// The margin is background and the cells one opaque colour
+	if len(colors) != 2 || colors[background] == 0 {
+		t.Errorf("colours = %v, want the background and one other", colors)
+	}
+	for _, p := range []image.Point{{0, 0}, {size - 1, size - 1}, {size / 2, 0}} {
+		if c := ann.RGBAAt(p.X, p.Y); c != background {
+			t.Errorf("margin at %v is %v, want the background", p, c)
+		}
+	}
+}
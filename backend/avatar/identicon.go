@@ -0,0 +1,76 @@
+package avatar
+
+import (
+	"crypto/sha256"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+)
+
+// identiconCells is the width of the identicon grid in cells
+const identiconCells = 5
+
+// Identicon draws a symmetric pattern of cells in a colour, both derived
+// from seed, so that every user gets a distinct default avatar
+func Identicon(seed string) Renderer {
+	sum := sha256.Sum256([]byte(seed))
+
+	// The left half and middle column come from the hash; the right half mirrors them
+	var filled [identiconCells][identiconCells]bool
+	bit := 0
+	for x := range (identiconCells + 1) / 2 {
+		for y := range identiconCells {
+			on := sum[bit/8]>>(bit%8)&1 == 1
+			filled[x][y], filled[identiconCells-1-x][y] = on, on
+			bit++
+		}
+	}
+
+	hue := float64(uint16(sum[30])<<8|uint16(sum[31])) / 65536 * 360
+	fg := &image.Uniform{hsl(hue, 0.55, 0.5)}
+	bg := &image.Uniform{color.RGBA{0xF0, 0xF0, 0xF0, 0xFF}}
+
+	return func(size int) image.Image {
+		img := image.NewRGBA(image.Rect(0, 0, size, size))
+		draw.Draw(img, img.Bounds(), bg, image.Point{}, draw.Src)
+
+		margin := size / 12
+		grid := size - 2*margin
+		edge := func(i int) int { return margin + i*grid/identiconCells }
+		for x := range identiconCells {
+			for y := range identiconCells {
+				if filled[x][y] {
+					cell := image.Rect(edge(x), edge(y), edge(x+1), edge(y+1))
+					draw.Draw(img, cell, fg, image.Point{}, draw.Src)
+				}
+			}
+		}
+		return img
+	}
+}
+
+// hsl converts a hue in degrees, saturation and lightness to an opaque colour
+func hsl(h, s, l float64) color.RGBA {
+	c := (1 - math.Abs(2*l-1)) * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := l - c/2
+
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	channel := func(v float64) uint8 { return uint8(math.Round((v + m) * 255)) }
+	return color.RGBA{channel(r), channel(g), channel(b), 0xFF}
+}
@@ -0,0 +1,214 @@
+package avatar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+)
+
+// maxSide bounds the width and height of an upload. Decoding holds the
+// whole image in memory at up to 4 bytes a pixel, so a small compressed
+// file could otherwise claim hundreds of megabytes; at 4096x4096 that is
+// at most 64 MiB. Avatars are stored at 256 pixels or less, so larger
+// images would gain nothing.
+const maxSide = 4096
+
+// Decode reads an uploaded JPEG, PNG or GIF, turns it upright according to
+// its EXIF orientation and crops it to crop. An empty crop selects the
+// largest centred square. crop is in pixels of the upright image and must
+// be a square inside it.
+func Decode(data []byte, crop image.Rectangle) (Renderer, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupported
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width > maxSide || cfg.Height > maxSide {
+		return nil, ErrTooLarge
+	}
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	src := orient(img, jpegOrientation(data))
+
+	b := src.Bounds()
+	if crop.Empty() {
+		side := min(b.Dx(), b.Dy())
+		x, y := (b.Dx()-side)/2, (b.Dy()-side)/2
+		crop = image.Rect(x, y, x+side, y+side)
+	} else if crop.Dx() != crop.Dy() || !crop.In(b) {
+		return nil, ErrCrop
+	}
+
+	return func(size int) image.Image {
+		return resize(src, crop, size)
+	}, nil
+}
+
+// orient returns img as RGBA, turned upright for an EXIF orientation from 1 to 8
+func orient(img image.Image, orientation int) *image.RGBA {
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+	if orientation < 2 || orientation > 8 {
+		return src
+	}
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // mirrored and turned
+				sx, sy = y, x
+			case 6: // needs turning clockwise
+				sx, sy = y, h-1-x
+			case 7: // mirrored and turned the other way
+				sx, sy = w-1-y, h-1-x
+			case 8: // needs turning anticlockwise
+				sx, sy = w-1-y, x
+			}
+			i, j := dst.PixOffset(x, y), src.PixOffset(sx, sy)
+			copy(dst.Pix[i:i+4], src.Pix[j:j+4])
+		}
+	}
+	return dst
+}
+
+// jpegOrientation returns the EXIF orientation tag of a JPEG, or 1 when it
+// has none
+func jpegOrientation(data []byte) int {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		if marker == 0xDA || length < 2 || i+2+length > len(data) {
+			break
+		}
+		segment := data[i+4 : i+2+length]
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+		i += 2 + length
+	}
+	return 1
+}
+
+// tiffOrientation finds the orientation tag (0x0112) in the first IFD of
+// the TIFF structure inside an EXIF segment
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	entries := int(order.Uint16(tiff[ifd:]))
+	for n := range entries {
+		e := ifd + 2 + n*12
+		if e+12 > len(tiff) {
+			break
+		}
+		if order.Uint16(tiff[e:]) == 0x0112 {
+			return int(order.Uint16(tiff[e+8:]))
+		}
+	}
+	return 1
+}
+
+// resize scales the square r of src to size by size, averaging the source
+// pixels each output pixel covers
+func resize(src *image.RGBA, r image.Rectangle, size int) *image.RGBA {
+	xw := weights(r.Min.X, r.Dx(), size)
+	yw := weights(r.Min.Y, r.Dy(), size)
+
+	// Scale rows first, keeping only the source rows inside r
+	rows := make([][4]float64, size*r.Dy())
+	for y := range r.Dy() {
+		for x, ws := range xw {
+			var sum [4]float64
+			for _, w := range ws {
+				p := src.PixOffset(w.index, r.Min.Y+y)
+				for c := range 4 {
+					sum[c] += float64(src.Pix[p+c]) * w.weight
+				}
+			}
+			rows[y*size+x] = sum
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	for y, ws := range yw {
+		for x := range size {
+			var sum [4]float64
+			for _, w := range ws {
+				row := rows[(w.index-r.Min.Y)*size+x]
+				for c := range 4 {
+					sum[c] += row[c] * w.weight
+				}
+			}
+			p := dst.PixOffset(x, y)
+			for c := range 4 {
+				dst.Pix[p+c] = uint8(min(sum[c]+0.5, 255))
+			}
+		}
+	}
+	return dst
+}
+
+type weight struct {
+	index  int
+	weight float64
+}
+
+// weights lists, for each of n output pixels, the source pixels from
+// start to start+length that it covers and how much of each
+func weights(start, length, n int) [][]weight {
+	scale := float64(length) / float64(n)
+	out := make([][]weight, n)
+	for i := range out {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		for s := int(lo); s < length && float64(s) < hi; s++ {
+			covered := min(hi, float64(s+1)) - max(lo, float64(s))
+			if covered > 0 {
+				out[i] = append(out[i], weight{start + s, covered / scale})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,133 @@
+package certs
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writePair generates a certificate for host and writes it and its key to dir
+func writePair(t *testing.T, dir, host string) (certFile, keyFile string) {
+	t.Helper()
+	certPEM, keyPEM, err := GenerateSelfSigned([]string{host, "127.0.0.1"}, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+// served returns the leaf certificate r currently hands out
+func served(t *testing.T, r *Reloader) *x509.Certificate {
+	t.Helper()
+	cert, err := r.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf
+}
+
+func TestGenerateSelfSigned(t *testing.T) {
+	certFile, _ := writePair(t, t.TempDir(), "forum.test")
+	r, err := NewReloader(certFile, filepath.Join(filepath.Dir(certFile), "key.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := served(t, r)
+
+	if err := leaf.VerifyHostname("forum.test"); err != nil {
+		t.Error(err)
+	}
+	if err := leaf.VerifyHostname("127.0.0.1"); err != nil {
+		t.Error(err)
+	}
+	if now := time.Now(); now.Before(leaf.NotBefore) || now.After(leaf.NotAfter) {
+		t.Errorf("certificate is not valid now: %v to %v", leaf.NotBefore, leaf.NotAfter)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "old.test")
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cn := served(t, r).Subject.CommonName; cn != "old.test" {
+		t.Fatalf("serving %q, want old.test", cn)
+	}
+
+	writePair(t, dir, "new.test")
+	if err := r.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	if cn := served(t, r).Subject.CommonName; cn != "new.test" {
+		t.Errorf("after reload serving %q, want new.test", cn)
+	}
+}
+
+func TestReloadKeepsCertificateOnError(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writePair(t, dir, "good.test")
+	r, err := NewReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A key that does not belong to the certificate
+	goodKey, err := os.ReadFile(keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writePair(t, dir, "other.test")
+	if err := os.WriteFile(keyFile, goodKey, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("reloading a mismatched pair succeeded")
+	}
+	if cn := served(t, r).Subject.CommonName; cn != "good.test" {
+		t.Errorf("after a mismatched pair serving %q, want good.test", cn)
+	}
+
+	// Files that are not PEM at all
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("reloading a garbage certificate succeeded")
+	}
+	if cn := served(t, r).Subject.CommonName; cn != "good.test" {
+		t.Errorf("after a garbage certificate serving %q, want good.test", cn)
+	}
+
+	// Missing files
+	if err := os.Remove(certFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Reload(); err == nil {
+		t.Error("reloading a missing certificate succeeded")
+	}
+	if cn := served(t, r).Subject.CommonName; cn != "good.test" {
+		t.Errorf("after a missing certificate serving %q, want good.test", cn)
+	}
+}
+
+func TestNewReloaderRejectsBadPair(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := NewReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")); err == nil {
+		t.Error("NewReloader with missing files succeeded")
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/certs"
+	"forum/config"
+	"forum/sqlite"
+)
+
+const usage = `Usage:
+
+$ forum [serve] [flags]       start the server
+$ forum 'port no'             start the server on a port (legacy form)
+$ forum config show [flags]   print the effective configuration
+$ forum cert generate [flags] write a self-signed certificate for local TLS testing
+$ forum db recount [flags]    recompute the reaction, comment and reply counts
+
+Run "forum serve -h" to list the flags.`
+
+// run dispatches to the requested subcommand, defaulting to serve
+func run(args []string) error {
+	if len(args) == 0 {
+		return serve(args)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(args[1:])
+	case "config":
+		return configCommand(args[1:])
+	case "cert":
+		return certCommand(args[1:])
+	case "db":
+		return dbCommand(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	}
+
+	// Keep supporting the original `go run . 'port no'` invocation
+	if len(args) == 1 {
+		if _, err := strconv.Atoi(args[0]); err == nil {
+			return serve([]string{"-port", args[0]})
+		}
+	}
+	return serve(args)
+}
+
+// configCommand implements `forum config show`
+func configCommand(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		fmt.Println(usage)
+		return errors.New("unknown config subcommand")
+	}
+
+	cfg, err := config.Load("config show", args[1:], os.Stderr)
+	if err != nil {
+		return err
+	}
+	return cfg.Write(os.Stdout)
+}
+
+// certCommand implements `forum cert generate`
+func certCommand(args []string) error {
+	if len(args) == 0 || args[0] != "generate" {
+		fmt.Println(usage)
+		return errors.New("unknown cert subcommand")
+	}
+
+	fs := flag.NewFlagSet("cert generate", flag.ContinueOnError)
+	hosts := fs.String("hosts", "localhost,127.0.0.1,::1", "comma separated host names and IPs to include")
+	certFile := fs.String("cert", "cert.pem", "where to write the certificate")
+	keyFile := fs.String("key", "key.pem", "where to write the private key")
+	validFor := fs.Duration("valid-for", 365*24*time.Hour, "how long the certificate is valid")
+	force := fs.Bool("force", false, "overwrite existing files")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	if !*force {
+		for _, f := range []string{*certFile, *keyFile} {
+			if _, err := os.Stat(f); err == nil {
+				return fmt.Errorf("%s already exists (use -force to overwrite)", f)
+			}
+		}
+	}
+
+	certPEM, keyPEM, err := certs.GenerateSelfSigned(strings.Split(*hosts, ","), *validFor)
+	if err != nil {
+		return fmt.Errorf("failed to generate certificate: %w", err)
+	}
+	if err := os.WriteFile(*certFile, certPEM, 0o644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*keyFile, keyPEM, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Printf("🔐 Wrote %s and %s for %s\n", *certFile, *keyFile, *hosts)
+	fmt.Printf("Start the server with: forum -tls-cert %s -tls-key %s\n", *certFile, *keyFile)
+	return nil
+}
+
+// dbCommand implements `forum db recount`
+func dbCommand(args []string) error {
+	if len(args) == 0 || args[0] != "recount" {
+		fmt.Println(usage)
+		return errors.New("unknown db subcommand")
+	}
+
+	cfg, err := config.Load("db recount", args[1:], os.Stderr)
+	if err != nil {
+		return err
+	}
+	if err := sqlite.InitializeDatabase(cfg.Database.Path, cfg.Database.Schema); err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
+	}
+	defer sqlite.CloseDatabase()
+
+	posts, comments, err := sqlite.Recount(context.Background(), sqlite.DB)
+	if err != nil {
+		return fmt.Errorf("failed to recount: %w", err)
+	}
+	fmt.Printf("🔢 Recounted %s: fixed %d posts and %d comments\n", cfg.Database.Path, posts, comments)
+	return nil
+}
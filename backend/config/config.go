@@ -0,0 +1,678 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/models"
+	"forum/scheduler"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the effective server configuration.
+// Values are resolved in order: defaults, config file, environment, flags.
+type Config struct {
+	Server    ServerConfig    `toml:"server" yaml:"server"`
+	Database  DatabaseConfig  `toml:"database" yaml:"database"`
+	CORS      CORSConfig      `toml:"cors" yaml:"cors"`
+	Uploads   UploadsConfig   `toml:"uploads" yaml:"uploads"`
+	Session   SessionConfig   `toml:"session" yaml:"session"`
+	Accounts  AccountsConfig  `toml:"accounts" yaml:"accounts"`
+	Exports   ExportsConfig   `toml:"exports" yaml:"exports"`
+	TLS       TLSConfig       `toml:"tls" yaml:"tls"`
+	Log       LogConfig       `toml:"log" yaml:"log"`
+	Metrics   MetricsConfig   `toml:"metrics" yaml:"metrics"`
+	Tracing   TracingConfig   `toml:"tracing" yaml:"tracing"`
+	GraphQL   GraphQLConfig   `toml:"graphql" yaml:"graphql"`
+	Reactions ReactionsConfig `toml:"reactions" yaml:"reactions"`
+	Admin     AdminConfig     `toml:"admin" yaml:"admin"`
+	Jobs      JobsConfig      `toml:"jobs" yaml:"jobs"`
+}
+
+type ServerConfig struct {
+	Host              string   `toml:"host" yaml:"host"`
+	Port              int      `toml:"port" yaml:"port"`
+	ReadTimeout       Duration `toml:"read_timeout" yaml:"read_timeout"`
+	ReadHeaderTimeout Duration `toml:"read_header_timeout" yaml:"read_header_timeout"`
+	WriteTimeout      Duration `toml:"write_timeout" yaml:"write_timeout"`
+	IdleTimeout       Duration `toml:"idle_timeout" yaml:"idle_timeout"`
+	// ShutdownDelay keeps serving with /readyz failing before draining starts,
+	// giving load balancers time to stop routing new requests here
+	ShutdownDelay Duration `toml:"shutdown_delay" yaml:"shutdown_delay"`
+	// ShutdownTimeout bounds how long in-flight requests may drain after SIGINT/SIGTERM
+	ShutdownTimeout Duration `toml:"shutdown_timeout" yaml:"shutdown_timeout"`
+}
+
+type DatabaseConfig struct {
+	Path   string `toml:"path" yaml:"path"`
+	Schema string `toml:"schema" yaml:"schema"`
+}
+
+type CORSConfig struct {
+	AllowedOrigin string `toml:"allowed_origin" yaml:"allowed_origin"`
+}
+
+type UploadsConfig struct {
+	Dir      string `toml:"dir" yaml:"dir"`
+	MaxBytes int64  `toml:"max_bytes" yaml:"max_bytes"`
+}
+
+type SessionConfig struct {
+	Lifetime Duration `toml:"lifetime" yaml:"lifetime"`
+}
+
+// AccountsConfig controls account self-service
+type AccountsConfig struct {
+	// DeletionGrace is how long a requested account deletion waits, during
+	// which logging in again cancels it
+	DeletionGrace Duration `toml:"deletion_grace" yaml:"deletion_grace"`
+}
+
+// ExportsConfig controls personal data exports
+type ExportsConfig struct {
+	// Dir holds finished export archives. Keep it outside uploads.dir so
+	// they are only reachable through their download links.
+	Dir string `toml:"dir" yaml:"dir"`
+	// LinkLifetime is how long an archive can be downloaded before it is deleted
+	LinkLifetime Duration `toml:"link_lifetime" yaml:"link_lifetime"`
+	// SyncLimit is the most posts, comments, replies, reactions and saved
+	// posts an account may have for its export to be built during the
+	// request; larger accounts are exported by the data_export job
+	SyncLimit int `toml:"sync_limit" yaml:"sync_limit"`
+}
+
+// TLSConfig enables HTTPS when both CertFile and KeyFile are set
+type TLSConfig struct {
+	CertFile string `toml:"cert_file" yaml:"cert_file"`
+	KeyFile  string `toml:"key_file" yaml:"key_file"`
+	// RedirectPort starts a plain HTTP listener that redirects to HTTPS; 0 disables it
+	RedirectPort          int      `toml:"redirect_port" yaml:"redirect_port"`
+	HSTSMaxAge            Duration `toml:"hsts_max_age" yaml:"hsts_max_age"`
+	HSTSIncludeSubdomains bool     `toml:"hsts_include_subdomains" yaml:"hsts_include_subdomains"`
+}
+
+// Enabled reports whether the server should serve HTTPS
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+type LogConfig struct {
+	Format string `toml:"format" yaml:"format"` // text or json
+	Level  string `toml:"level" yaml:"level"`   // debug, info, warn or error
+}
+
+type MetricsConfig struct {
+	Enabled bool `toml:"enabled" yaml:"enabled"`
+	// Addr serves /metrics on a separate listener such as "127.0.0.1:9090".
+	// When empty, /metrics is served on the main listener behind the admin token.
+	Addr string `toml:"addr" yaml:"addr"`
+}
+
+type TracingConfig struct {
+	Exporter string `toml:"exporter" yaml:"exporter"` // none, stdout or otlp
+	// Endpoint is the OTLP/HTTP collector URL such as "http://localhost:4318".
+	// When empty the standard OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint    string  `toml:"endpoint" yaml:"endpoint"`
+	SampleRatio float64 `toml:"sample_ratio" yaml:"sample_ratio"`
+	ServiceName string  `toml:"service_name" yaml:"service_name"`
+}
+
+// GraphQLConfig bounds the cost of a single /graphql query
+type GraphQLConfig struct {
+	MaxDepth      int `toml:"max_depth" yaml:"max_depth"`
+	MaxComplexity int `toml:"max_complexity" yaml:"max_complexity"`
+}
+
+// ReactionsConfig lists the reactions offered besides like and dislike
+type ReactionsConfig struct {
+	// Types are "name" or "name:emoji" entries, in the order they are shown
+	Types []string `toml:"types" yaml:"types"`
+}
+
+// reactionName is what a configured reaction type may be called
+var reactionName = regexp.MustCompile(`^[a-z][a-z_]{0,31}$`)
+
+// Parsed splits Types into names and emoji
+func (r ReactionsConfig) Parsed() []models.ReactionType {
+	types := make([]models.ReactionType, 0, len(r.Types))
+	for _, t := range r.Types {
+		name, emoji, _ := strings.Cut(t, ":")
+		types = append(types, models.ReactionType{Name: strings.TrimSpace(name), Emoji: strings.TrimSpace(emoji)})
+	}
+	return types
+}
+
+type AdminConfig struct {
+	// Token is the bearer token for /api/admin; the admin API is disabled when empty
+	Token string `toml:"token" yaml:"token"`
+}
+
+// JobsConfig holds cron specs for the background jobs
+type JobsConfig struct {
+	Jitter            Duration `toml:"jitter" yaml:"jitter"`
+	SessionPurge      string   `toml:"session_purge" yaml:"session_purge"`
+	UploadGC          string   `toml:"upload_gc" yaml:"upload_gc"`
+	TrendingRecompute string   `toml:"trending_recompute" yaml:"trending_recompute"`
+	DBOptimize        string   `toml:"db_optimize" yaml:"db_optimize"`
+	AccountDeletion   string   `toml:"account_deletion" yaml:"account_deletion"`
+	DataExport        string   `toml:"data_export" yaml:"data_export"`
+}
+
+// Duration wraps time.Duration so it can be written as "24h" in config files
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Port:              8080,
+			ReadTimeout:       Duration{30 * time.Second},
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			ShutdownTimeout:   Duration{15 * time.Second},
+		},
+		Database:  DatabaseConfig{Path: "forum.db", Schema: "schema.sql"},
+		CORS:      CORSConfig{AllowedOrigin: "http://localhost:8000"},
+		Uploads:   UploadsConfig{Dir: "static", MaxBytes: 10 << 20},
+		Session:   SessionConfig{Lifetime: Duration{24 * time.Hour}},
+		Accounts:  AccountsConfig{DeletionGrace: Duration{14 * 24 * time.Hour}},
+		Exports:   ExportsConfig{Dir: "exports", LinkLifetime: Duration{24 * time.Hour}, SyncLimit: 1000},
+		TLS:       TLSConfig{HSTSMaxAge: Duration{365 * 24 * time.Hour}},
+		Log:       LogConfig{Format: "text", Level: "info"},
+		Metrics:   MetricsConfig{Enabled: true},
+		Tracing:   TracingConfig{Exporter: "none", SampleRatio: 1, ServiceName: "forum"},
+		GraphQL:   GraphQLConfig{MaxDepth: 8, MaxComplexity: 10000},
+		Reactions: ReactionsConfig{Types: []string{"thanks:🙏", "insightful:💡", "funny:😂", "confused:😕"}},
+		Jobs: JobsConfig{
+			Jitter:            Duration{30 * time.Second},
+			SessionPurge:      "0 0 * * *",
+			UploadGC:          "30 3 * * *",
+			TrendingRecompute: "*/15 * * * *",
+			DBOptimize:        "0 4 * * 0",
+			AccountDeletion:   "45 * * * *",
+			DataExport:        "* * * * *",
+		},
+	}
+}
+
+var current = Default()
+
+// Current returns the configuration the server was started with
+func Current() *Config {
+	return current
+}
+
+// Set replaces the configuration returned by Current
+func Set(c *Config) {
+	current = c
+}
+
+// Addr returns the listen address for the HTTP server
+func (c *Config) Addr() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.Server.Port))
+}
+
+// RedirectAddr returns the listen address for the HTTP to HTTPS redirect listener
+func (c *Config) RedirectAddr() string {
+	return net.JoinHostPort(c.Server.Host, strconv.Itoa(c.TLS.RedirectPort))
+}
+
+// setting describes a single value that can be overridden by env or flag
+type setting struct {
+	flag  string
+	env   []string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"host", []string{"FORUM_HOST"}, "interface to listen on (empty for all)", func(c *Config, v string) error {
+		c.Server.Host = v
+		return nil
+	}},
+	{"port", []string{"FORUM_PORT", "PORT"}, "port to listen on", func(c *Config, v string) error {
+		return parseInt(v, &c.Server.Port)
+	}},
+	{"read-timeout", []string{"FORUM_READ_TIMEOUT"}, "maximum duration for reading an entire request", func(c *Config, v string) error {
+		return c.Server.ReadTimeout.UnmarshalText([]byte(v))
+	}},
+	{"read-header-timeout", []string{"FORUM_READ_HEADER_TIMEOUT"}, "maximum duration for reading request headers", func(c *Config, v string) error {
+		return c.Server.ReadHeaderTimeout.UnmarshalText([]byte(v))
+	}},
+	{"write-timeout", []string{"FORUM_WRITE_TIMEOUT"}, "maximum duration before timing out writes of a response", func(c *Config, v string) error {
+		return c.Server.WriteTimeout.UnmarshalText([]byte(v))
+	}},
+	{"idle-timeout", []string{"FORUM_IDLE_TIMEOUT"}, "how long keep-alive connections may stay idle", func(c *Config, v string) error {
+		return c.Server.IdleTimeout.UnmarshalText([]byte(v))
+	}},
+	{"shutdown-delay", []string{"FORUM_SHUTDOWN_DELAY"}, "how long to fail /readyz before draining on shutdown", func(c *Config, v string) error {
+		return c.Server.ShutdownDelay.UnmarshalText([]byte(v))
+	}},
+	{"shutdown-timeout", []string{"FORUM_SHUTDOWN_TIMEOUT"}, "how long in-flight requests may drain on shutdown", func(c *Config, v string) error {
+		return c.Server.ShutdownTimeout.UnmarshalText([]byte(v))
+	}},
+	{"db", []string{"FORUM_DB_PATH", "DATABASE_URL"}, "path to the SQLite database file", func(c *Config, v string) error {
+		c.Database.Path = v
+		return nil
+	}},
+	{"schema", []string{"FORUM_SCHEMA_PATH"}, "path to schema.sql", func(c *Config, v string) error {
+		c.Database.Schema = v
+		return nil
+	}},
+	{"cors-origin", []string{"FORUM_CORS_ORIGIN", "FRONTEND_ORIGIN"}, "origin allowed to make credentialed requests", func(c *Config, v string) error {
+		c.CORS.AllowedOrigin = v
+		return nil
+	}},
+	{"upload-dir", []string{"FORUM_UPLOAD_DIR"}, "directory for uploaded and static files", func(c *Config, v string) error {
+		c.Uploads.Dir = v
+		return nil
+	}},
+	{"upload-max-bytes", []string{"FORUM_UPLOAD_MAX_BYTES"}, "maximum size of a multipart upload in bytes", func(c *Config, v string) error {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		c.Uploads.MaxBytes = n
+		return nil
+	}},
+	{"session-lifetime", []string{"FORUM_SESSION_LIFETIME"}, "how long a login session stays valid (e.g. 24h)", func(c *Config, v string) error {
+		return c.Session.Lifetime.UnmarshalText([]byte(v))
+	}},
+	{"account-deletion-grace", []string{"FORUM_ACCOUNT_DELETION_GRACE"}, "how long a requested account deletion waits before it is carried out", func(c *Config, v string) error {
+		return c.Accounts.DeletionGrace.UnmarshalText([]byte(v))
+	}},
+	{"export-dir", []string{"FORUM_EXPORT_DIR"}, "directory for personal data export archives", func(c *Config, v string) error {
+		c.Exports.Dir = v
+		return nil
+	}},
+	{"export-link-lifetime", []string{"FORUM_EXPORT_LINK_LIFETIME"}, "how long a data export download link stays valid", func(c *Config, v string) error {
+		return c.Exports.LinkLifetime.UnmarshalText([]byte(v))
+	}},
+	{"export-sync-limit", []string{"FORUM_EXPORT_SYNC_LIMIT"}, "largest account, in items, whose data export is built during the request", func(c *Config, v string) error {
+		return parseInt(v, &c.Exports.SyncLimit)
+	}},
+	{"tls-cert", []string{"FORUM_TLS_CERT"}, "TLS certificate file (enables HTTPS with -tls-key)", func(c *Config, v string) error {
+		c.TLS.CertFile = v
+		return nil
+	}},
+	{"tls-key", []string{"FORUM_TLS_KEY"}, "TLS private key file", func(c *Config, v string) error {
+		c.TLS.KeyFile = v
+		return nil
+	}},
+	{"tls-redirect-port", []string{"FORUM_TLS_REDIRECT_PORT"}, "port for an HTTP listener redirecting to HTTPS (0 disables)", func(c *Config, v string) error {
+		return parseInt(v, &c.TLS.RedirectPort)
+	}},
+	{"hsts-max-age", []string{"FORUM_HSTS_MAX_AGE"}, "Strict-Transport-Security max-age sent over HTTPS (0 disables)", func(c *Config, v string) error {
+		return c.TLS.HSTSMaxAge.UnmarshalText([]byte(v))
+	}},
+	{"log-format", []string{"FORUM_LOG_FORMAT"}, "log output format: text or json", func(c *Config, v string) error {
+		c.Log.Format = v
+		return nil
+	}},
+	{"log-level", []string{"FORUM_LOG_LEVEL"}, "minimum log level: debug, info, warn or error", func(c *Config, v string) error {
+		c.Log.Level = v
+		return nil
+	}},
+	{"metrics", []string{"FORUM_METRICS_ENABLED"}, "expose Prometheus metrics (true or false)", func(c *Config, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		c.Metrics.Enabled = b
+		return nil
+	}},
+	{"metrics-addr", []string{"FORUM_METRICS_ADDR"}, "separate listen address for /metrics (empty serves it behind the admin token)", func(c *Config, v string) error {
+		c.Metrics.Addr = v
+		return nil
+	}},
+	{"tracing-exporter", []string{"FORUM_TRACING_EXPORTER"}, "trace exporter: none, stdout or otlp", func(c *Config, v string) error {
+		c.Tracing.Exporter = v
+		return nil
+	}},
+	{"tracing-endpoint", []string{"FORUM_TRACING_ENDPOINT"}, "OTLP/HTTP collector URL (e.g. http://localhost:4318)", func(c *Config, v string) error {
+		c.Tracing.Endpoint = v
+		return nil
+	}},
+	{"tracing-sample-ratio", []string{"FORUM_TRACING_SAMPLE_RATIO"}, "fraction of new traces to sample, from 0 to 1", func(c *Config, v string) error {
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return err
+		}
+		c.Tracing.SampleRatio = f
+		return nil
+	}},
+	{"tracing-service-name", []string{"FORUM_TRACING_SERVICE_NAME", "OTEL_SERVICE_NAME"}, "service.name reported on spans", func(c *Config, v string) error {
+		c.Tracing.ServiceName = v
+		return nil
+	}},
+	{"graphql-max-depth", []string{"FORUM_GRAPHQL_MAX_DEPTH"}, "deepest field nesting a GraphQL query may use", func(c *Config, v string) error {
+		return parseInt(v, &c.GraphQL.MaxDepth)
+	}},
+	{"graphql-max-complexity", []string{"FORUM_GRAPHQL_MAX_COMPLEXITY"}, "highest estimated cost a GraphQL query may have", func(c *Config, v string) error {
+		return parseInt(v, &c.GraphQL.MaxComplexity)
+	}},
+	{"reactions", []string{"FORUM_REACTIONS"}, "comma-separated name:emoji reactions offered besides like and dislike", func(c *Config, v string) error {
+		c.Reactions.Types = nil
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				c.Reactions.Types = append(c.Reactions.Types, t)
+			}
+		}
+		return nil
+	}},
+	{"admin-token", []string{"FORUM_ADMIN_TOKEN"}, "bearer token for the admin API (disabled when empty)", func(c *Config, v string) error {
+		c.Admin.Token = v
+		return nil
+	}},
+	{"job-jitter", []string{"FORUM_JOB_JITTER"}, "maximum random delay added to each job run", func(c *Config, v string) error {
+		return c.Jobs.Jitter.UnmarshalText([]byte(v))
+	}},
+	{"job-session-purge", []string{"FORUM_JOB_SESSION_PURGE"}, "cron spec for purging expired sessions", func(c *Config, v string) error {
+		c.Jobs.SessionPurge = v
+		return nil
+	}},
+	{"job-upload-gc", []string{"FORUM_JOB_UPLOAD_GC"}, "cron spec for deleting orphaned uploads", func(c *Config, v string) error {
+		c.Jobs.UploadGC = v
+		return nil
+	}},
+	{"job-trending-recompute", []string{"FORUM_JOB_TRENDING_RECOMPUTE"}, "cron spec for recomputing trending scores", func(c *Config, v string) error {
+		c.Jobs.TrendingRecompute = v
+		return nil
+	}},
+	{"job-db-optimize", []string{"FORUM_JOB_DB_OPTIMIZE"}, "cron spec for optimizing and vacuuming the database", func(c *Config, v string) error {
+		c.Jobs.DBOptimize = v
+		return nil
+	}},
+	{"job-account-deletion", []string{"FORUM_JOB_ACCOUNT_DELETION"}, "cron spec for carrying out account deletions past their grace period", func(c *Config, v string) error {
+		c.Jobs.AccountDeletion = v
+		return nil
+	}},
+	{"job-data-export", []string{"FORUM_JOB_DATA_EXPORT"}, "cron spec for building pending data exports and deleting expired ones", func(c *Config, v string) error {
+		c.Jobs.DataExport = v
+		return nil
+	}},
+}
+
+func parseInt(v string, dst *int) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	*dst = n
+	return nil
+}
+
+// Load resolves the configuration for the named command from a config file,
+// the environment and the given command-line arguments, then validates it.
+// The config file is taken from -config or FORUM_CONFIG.
+func Load(name string, args []string, output io.Writer) (*Config, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(output)
+
+	configPath := os.Getenv("FORUM_CONFIG")
+	fs.StringVar(&configPath, "config", configPath, "path to a TOML or YAML config file")
+
+	// Flags are recorded and applied last so they win over file and env values
+	var overrides []func(c *Config) error
+	for _, s := range settings {
+		fs.Func(s.flag, s.usage, func(v string) error {
+			overrides = append(overrides, func(c *Config) error {
+				if err := s.set(c, v); err != nil {
+					return fmt.Errorf("flag -%s: %w", s.flag, err)
+				}
+				return nil
+			})
+			return nil
+		})
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments: %s", strings.Join(fs.Args(), " "))
+	}
+
+	cfg := Default()
+	if configPath != "" {
+		if err := loadFile(cfg, configPath); err != nil {
+			return nil, err
+		}
+	}
+	if err := applyEnv(cfg, os.Getenv); err != nil {
+		return nil, err
+	}
+	for _, apply := range overrides {
+		if err := apply(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile decodes a TOML or YAML file on top of cfg, picking the format by extension
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".toml":
+		if _, err := toml.Decode(string(data), cfg); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(data, cfg); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("unsupported config file format %q (use .toml, .yaml or .yml)", filepath.Ext(path))
+	}
+	return nil
+}
+
+// applyEnv overrides cfg with any settings present in the environment.
+// The first variable listed for a setting takes priority over its aliases.
+func applyEnv(cfg *Config, getenv func(string) string) error {
+	for _, s := range settings {
+		for _, key := range s.env {
+			v := getenv(key)
+			if v == "" {
+				continue
+			}
+			if err := s.set(cfg, v); err != nil {
+				return fmt.Errorf("env %s: %w", key, err)
+			}
+			break
+		}
+	}
+	return nil
+}
+
+// Validate reports every invalid value in the configuration
+func (c *Config) Validate() error {
+	var errs []error
+
+	p := c.Server.Port
+	if !(p > 1023 && p < 65536 && p != 3306 && p != 3389) {
+		errs = append(errs, fmt.Errorf("server.port: %d must be greater than 1023, at most 65535 and not 3306/3389", p))
+	}
+	for _, t := range []struct {
+		name string
+		d    Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.read_header_timeout", c.Server.ReadHeaderTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_timeout", c.Server.ShutdownTimeout},
+	} {
+		if t.d.Duration <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s must be positive", t.name, t.d))
+		}
+	}
+	if c.Server.ShutdownDelay.Duration < 0 {
+		errs = append(errs, fmt.Errorf("server.shutdown_delay: %s must not be negative", c.Server.ShutdownDelay))
+	}
+	if c.Database.Path == "" {
+		errs = append(errs, errors.New("database.path: must not be empty"))
+	}
+	if c.Database.Schema == "" {
+		errs = append(errs, errors.New("database.schema: must not be empty"))
+	} else if _, err := os.Stat(c.Database.Schema); err != nil {
+		errs = append(errs, fmt.Errorf("database.schema: %w", err))
+	}
+	if u, err := url.Parse(c.CORS.AllowedOrigin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		errs = append(errs, fmt.Errorf("cors.allowed_origin: %q must be an http(s) origin", c.CORS.AllowedOrigin))
+	}
+	if c.Uploads.Dir == "" {
+		errs = append(errs, errors.New("uploads.dir: must not be empty"))
+	}
+	if c.Uploads.MaxBytes <= 0 {
+		errs = append(errs, fmt.Errorf("uploads.max_bytes: %d must be positive", c.Uploads.MaxBytes))
+	}
+	if c.Session.Lifetime.Duration < time.Minute {
+		errs = append(errs, fmt.Errorf("session.lifetime: %s must be at least 1m", c.Session.Lifetime))
+	}
+	if c.Accounts.DeletionGrace.Duration < 0 {
+		errs = append(errs, fmt.Errorf("accounts.deletion_grace: %s must not be negative", c.Accounts.DeletionGrace))
+	}
+	if c.Exports.Dir == "" {
+		errs = append(errs, errors.New("exports.dir: must not be empty"))
+	}
+	if c.Exports.LinkLifetime.Duration < time.Minute {
+		errs = append(errs, fmt.Errorf("exports.link_lifetime: %s must be at least 1m", c.Exports.LinkLifetime))
+	}
+	if c.Exports.SyncLimit < 0 {
+		errs = append(errs, fmt.Errorf("exports.sync_limit: %d must not be negative", c.Exports.SyncLimit))
+	}
+
+	if (c.TLS.CertFile == "") != (c.TLS.KeyFile == "") {
+		errs = append(errs, errors.New("tls: cert_file and key_file must be set together"))
+	}
+	for _, f := range []struct{ name, path string }{
+		{"tls.cert_file", c.TLS.CertFile},
+		{"tls.key_file", c.TLS.KeyFile},
+	} {
+		if f.path == "" {
+			continue
+		}
+		if _, err := os.Stat(f.path); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.name, err))
+		}
+	}
+	if rp := c.TLS.RedirectPort; rp != 0 {
+		if !c.TLS.Enabled() {
+			errs = append(errs, errors.New("tls.redirect_port: requires tls.cert_file and tls.key_file"))
+		}
+		if rp < 1 || rp > 65535 || rp == c.Server.Port {
+			errs = append(errs, fmt.Errorf("tls.redirect_port: %d must be a valid port different from server.port", rp))
+		}
+	}
+	if c.TLS.HSTSMaxAge.Duration < 0 {
+		errs = append(errs, fmt.Errorf("tls.hsts_max_age: %s must not be negative", c.TLS.HSTSMaxAge))
+	}
+	if c.Log.Format != "text" && c.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log.format: %q must be text or json", c.Log.Format))
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		errs = append(errs, fmt.Errorf("log.level: %q must be debug, info, warn or error", c.Log.Level))
+	}
+	if c.Metrics.Addr != "" {
+		if _, _, err := net.SplitHostPort(c.Metrics.Addr); err != nil {
+			errs = append(errs, fmt.Errorf("metrics.addr: %w", err))
+		} else if c.Metrics.Addr == c.Addr() {
+			errs = append(errs, errors.New("metrics.addr: must differ from the main listen address"))
+		}
+	}
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("tracing.exporter: %q must be none, stdout or otlp", c.Tracing.Exporter))
+	}
+	if c.Tracing.Endpoint != "" {
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("tracing.endpoint: %q must be an http(s) URL", c.Tracing.Endpoint))
+		}
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		errs = append(errs, fmt.Errorf("tracing.sample_ratio: %v must be between 0 and 1", c.Tracing.SampleRatio))
+	}
+	if c.GraphQL.MaxDepth < 1 {
+		errs = append(errs, fmt.Errorf("graphql.max_depth: %d must be at least 1", c.GraphQL.MaxDepth))
+	}
+	if c.GraphQL.MaxComplexity < 1 {
+		errs = append(errs, fmt.Errorf("graphql.max_complexity: %d must be at least 1", c.GraphQL.MaxComplexity))
+	}
+	seen := map[string]bool{"like": true, "dislike": true}
+	for _, t := range c.Reactions.Parsed() {
+		switch {
+		case !reactionName.MatchString(t.Name):
+			errs = append(errs, fmt.Errorf("reactions.types: %q must be lowercase letters and underscores, at most 32 characters", t.Name))
+		case seen[t.Name]:
+			errs = append(errs, fmt.Errorf("reactions.types: %q is listed twice or is built in", t.Name))
+		}
+		seen[t.Name] = true
+	}
+	if c.Jobs.Jitter.Duration < 0 {
+		errs = append(errs, fmt.Errorf("jobs.jitter: %s must not be negative", c.Jobs.Jitter))
+	}
+	for _, j := range []struct{ name, spec string }{
+		{"jobs.session_purge", c.Jobs.SessionPurge},
+		{"jobs.upload_gc", c.Jobs.UploadGC},
+		{"jobs.trending_recompute", c.Jobs.TrendingRecompute},
+		{"jobs.db_optimize", c.Jobs.DBOptimize},
+		{"jobs.account_deletion", c.Jobs.AccountDeletion},
+		{"jobs.data_export", c.Jobs.DataExport},
+	} {
+		if _, err := scheduler.Parse(j.spec); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", j.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Write prints the configuration as TOML with secrets masked
+func (c *Config) Write(w io.Writer) error {
+	redacted := *c
+	if redacted.Admin.Token != "" {
+		redacted.Admin.Token = "********"
+	}
+	return toml.NewEncoder(w).Encode(redacted)
+}
@@ -0,0 +1,317 @@
+package config
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clearEnv unsets every variable Load reads so the host environment cannot
+// leak into a test
+func clearEnv(t *testing.T) {
+	t.Helper()
+	t.Setenv("FORUM_CONFIG", "")
+	for _, s := range settings {
+		for _, key := range s.env {
+			t.Setenv(key, "")
+		}
+	}
+}
+
+// writeFile writes a config file named name and returns its path
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// load runs Load with the repository schema, which Validate requires to exist
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load("forum", append([]string{"-schema", "../schema.sql"}, args...), io.Discard)
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	clearEnv(t)
+	path := writeFile(t, "forum.toml", `
+[server]
+port = 9001
+read_timeout = "10s"
+
+[log]
+level = "debug"
+format = "json"
+`)
+
+	cfg, err := load(t, "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9001 || cfg.Log.Level != "debug" || cfg.Log.Format != "json" {
+		t.Errorf("file: port %d, log %s/%s, want 9001, debug/json", cfg.Server.Port, cfg.Log.Level, cfg.Log.Format)
+	}
+	// Values the file leaves out keep their defaults
+	if cfg.Server.WriteTimeout != Default().Server.WriteTimeout {
+		t.Errorf("write_timeout = %s, want the default %s", cfg.Server.WriteTimeout, Default().Server.WriteTimeout)
+	}
+
+	// The environment wins over the file, and flags over both
+	t.Setenv("FORUM_CONFIG", path)
+	t.Setenv("FORUM_PORT", "9002")
+	t.Setenv("FORUM_LOG_LEVEL", "warn")
+	cfg, err = load(t, "-port", "9003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Server.Port != 9003 {
+		t.Errorf("port = %d, want the flag's 9003", cfg.Server.Port)
+	}
+	if cfg.Log.Level != "warn" {
+		t.Errorf("log.level = %q, want the environment's warn", cfg.Log.Level)
+	}
+	if cfg.Log.Format != "json" || cfg.Server.ReadTimeout.Duration != 10*time.Second {
+		t.Errorf("log.format = %q, read_timeout = %s, want the file's json and 10s", cfg.Log.Format, cfg.Server.ReadTimeout)
+	}
+}
+
+func TestLoadEnvAliases(t *testing.T) {
+	tests := []struct {
+		env  map[string]string
+		want func(*Config) string
+		text string
+	}{
+		{map[string]string{"PORT": "9100"}, func(c *Config) string { return c.Addr() }, ":9100"},
+		{map[string]string{"PORT": "9100", "FORUM_PORT": "9200"}, func(c *Config) string { return c.Addr() }, ":9200"},
+		{map[string]string{"DATABASE_URL": "alias.db"}, func(c *Config) string { return c.Database.Path }, "alias.db"},
+		{map[string]string{"DATABASE_URL": "alias.db", "FORUM_DB_PATH": "forum.db"}, func(c *Config) string { return c.Database.Path }, "forum.db"},
+		{map[string]string{"FRONTEND_ORIGIN": "https://example.com"}, func(c *Config) string { return c.CORS.AllowedOrigin }, "https://example.com"},
+		{map[string]string{"OTEL_SERVICE_NAME": "otel"}, func(c *Config) string { return c.Tracing.ServiceName }, "otel"},
+		{map[string]string{"OTEL_SERVICE_NAME": "otel", "FORUM_TRACING_SERVICE_NAME": "forum-api"}, func(c *Config) string { return c.Tracing.ServiceName }, "forum-api"},
+	}
+	for _, tt := range tests {
+		clearEnv(t)
+		for k, v := range tt.env {
+			t.Setenv(k, v)
+		}
+		cfg, err := load(t)
+		if err != nil {
+			t.Errorf("%v: %v", tt.env, err)
+			continue
+		}
+		if got := tt.want(cfg); got != tt.text {
+			t.Errorf("%v: got %q, want %q", tt.env, got, tt.text)
+		}
+	}
+}
+
+func TestLoadFileFormats(t *testing.T) {
+	tests := []struct {
+		name, content string
+	}{
+		{"forum.toml", `
+[server]
+port = 9300
+shutdown_timeout = "1m30s"
+
+[uploads]
+max_bytes = 2048
+
+[reactions]
+types = ["thanks:🙏", "wow"]
+`},
+		{"forum.yaml", `
+server:
+  port: 9300
+  shutdown_timeout: 1m30s
+uploads:
+  max_bytes: 2048
+reactions:
+  types: ["thanks:🙏", "wow"]
+`},
+		{"forum.yml", `
+server: {port: 9300, shutdown_timeout: 1m30s}
+uploads: {max_bytes: 2048}
+reactions: {types: ["thanks:🙏", wow]}
+`},
+	}
+	for _, tt := range tests {
+		clearEnv(t)
+		cfg, err := load(t, "-config", writeFile(t, tt.name, tt.content))
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if cfg.Server.Port != 9300 || cfg.Server.ShutdownTimeout.Duration != 90*time.Second || cfg.Uploads.MaxBytes != 2048 {
+			t.Errorf("%s: port %d, shutdown_timeout %s, max_bytes %d", tt.name, cfg.Server.Port, cfg.Server.ShutdownTimeout, cfg.Uploads.MaxBytes)
+		}
+		if want := []string{"thanks:🙏", "wow"}; !slices.Equal(cfg.Reactions.Types, want) {
+			t.Errorf("%s: reactions = %q, want %q", tt.name, cfg.Reactions.Types, want)
+		}
+	}
+
+	clearEnv(t)
+	for _, tt := range []struct {
+		name, content, want string
+	}{
+		{"forum.json", `{}`, "unsupported config file format"},
+		{"forum.toml", "[server\nport = 1", "failed to parse"},
+		{"forum.yaml", "server: [", "failed to parse"},
+	} {
+		_, err := load(t, "-config", writeFile(t, tt.name, tt.content))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: error %v, want one containing %q", tt.name, err, tt.want)
+		}
+	}
+	if _, err := load(t, "-config", filepath.Join(t.TempDir(), "missing.toml")); err == nil {
+		t.Error("missing config file loaded, want an error")
+	}
+}
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		text string
+		want time.Duration
+	}{
+		{"0s", 0},
+		{"90s", 90 * time.Second},
+		{"1m30s", 90 * time.Second},
+		{"24h", 24 * time.Hour},
+		{"336h", 14 * 24 * time.Hour},
+		{"1.5h", 90 * time.Minute},
+		{"-5m", -5 * time.Minute},
+	}
+	for _, tt := range tests {
+		var d Duration
+		if err := d.UnmarshalText([]byte(tt.text)); err != nil {
+			t.Errorf("UnmarshalText(%q): %v", tt.text, err)
+			continue
+		}
+		if d.Duration != tt.want {
+			t.Errorf("UnmarshalText(%q) = %s, want %s", tt.text, d, tt.want)
+		}
+		// Marshalling gives back text that parses to the same duration
+		text, _ := d.MarshalText()
+		var again Duration
+		if err := again.UnmarshalText(text); err != nil || again != d {
+			t.Errorf("round trip of %q through %q = %s, %v", tt.text, text, again, err)
+		}
+	}
+
+	for _, text := range []string{"", "24", "1d", "soon", "5 m"} {
+		var d Duration
+		if err := d.UnmarshalText([]byte(text)); err == nil {
+			t.Errorf("UnmarshalText(%q) = %s, want an error", text, d)
+		}
+	}
+
+	clearEnv(t)
+	t.Setenv("FORUM_SESSION_LIFETIME", "1d")
+	if _, err := load(t); err == nil || !strings.Contains(err.Error(), "env FORUM_SESSION_LIFETIME") {
+		t.Errorf("FORUM_SESSION_LIFETIME=1d: error %v, want one naming the variable", err)
+	}
+	clearEnv(t)
+	if _, err := load(t, "-idle-timeout", "forever"); err == nil || !strings.Contains(err.Error(), "flag -idle-timeout") {
+		t.Errorf("-idle-timeout forever: error %v, want one naming the flag", err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cfg := Default()
+	cfg.Database.Schema = "../schema.sql"
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+
+	cfg.Server.Port = 80
+	cfg.Server.ReadTimeout = Duration{}
+	cfg.CORS.AllowedOrigin = "localhost:8000"
+	cfg.Uploads.MaxBytes = 0
+	cfg.Session.Lifetime = Duration{time.Second}
+	cfg.TLS.CertFile = "cert.pem"
+	cfg.Log.Format = "xml"
+	cfg.Tracing.SampleRatio = 2
+	cfg.Reactions.Types = []string{"like", "Wow", "thanks", "thanks:🙏"}
+	cfg.Jobs.UploadGC = "every night"
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("invalid config passed validation")
+	}
+	// Every rejected value is reported at once, one per line
+	want := []string{
+		"server.port: 80",
+		"server.read_timeout: 0s must be positive",
+		"cors.allowed_origin:",
+		"uploads.max_bytes: 0 must be positive",
+		"session.lifetime: 1s must be at least 1m",
+		"tls: cert_file and key_file must be set together",
+		"tls.cert_file:",
+		"log.format:",
+		"tracing.sample_ratio: 2",
+		`reactions.types: "like" is listed twice or is built in`,
+		`reactions.types: "Wow" must be lowercase`,
+		`reactions.types: "thanks" is listed twice or is built in`,
+		"jobs.upload_gc:",
+	}
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != len(want) {
+		t.Errorf("got %d errors, want %d:\n%v", len(lines), len(want), err)
+	}
+	for _, w := range want {
+		if !slices.ContainsFunc(lines, func(line string) bool { return strings.HasPrefix(line, w) }) {
+			t.Errorf("no error starting %q in:\n%v", w, err)
+		}
+	}
+
+	// Load validates too
+	clearEnv(t)
+	if _, err := load(t, "-port", "80", "-log-format", "xml"); err == nil || strings.Count(err.Error(), "\n") != 1 {
+		t.Errorf("Load with two invalid flags: error %v, want both reported", err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	cfg := Default()
+	cfg.Admin.Token = "s3cret-admin-token"
+
+	var buf bytes.Buffer
+	if err := cfg.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if strings.Contains(out, "s3cret-admin-token") {
+		t.Errorf("Write printed the admin token:\n%s", out)
+	}
+	if !strings.Contains(out, `token = "********"`) {
+		t.Errorf("Write did not mask the admin token:\n%s", out)
+	}
+	if cfg.Admin.Token != "s3cret-admin-token" {
+		t.Errorf("Write changed the config's token to %q", cfg.Admin.Token)
+	}
+
+	// The output is a config file that loads back to the same values
+	clearEnv(t)
+	cfg.Admin.Token = ""
+	buf.Reset()
+	if err := cfg.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "********") {
+		t.Errorf("Write masked an empty token:\n%s", buf.String())
+	}
+	loaded, err := load(t, "-config", writeFile(t, "forum.toml", buf.String()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Session.Lifetime != cfg.Session.Lifetime || loaded.Jobs != cfg.Jobs || loaded.Server != cfg.Server {
+		t.Errorf("Write output loaded as %+v, want %+v", loaded, cfg)
+	}
+}
@@ -0,0 +1,281 @@
+// Package export builds the ZIP archive a user downloads to get a copy of
+// their personal data: JSON files for machines, an HTML index for people,
+// and the images they uploaded.
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"forum/avatar"
+	"forum/models"
+	"forum/sqlite"
+)
+
+// pageSize is how many rows each query fetches while collecting a list
+const pageSize = 500
+
+// Options locates the files an export refers to
+type Options struct {
+	// UploadDir is where /static/ URLs are served from
+	UploadDir string
+	// SessionLifetime dates when each exported session expires
+	SessionLifetime time.Duration
+}
+
+// Session is a login session as it appears in an export. The session ID
+// is a credential, so it is left out.
+type Session struct {
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// data is everything an export contains about one user
+type data struct {
+	Profile    *models.User
+	Posts      []models.Post
+	Revisions  []models.PostRevision
+	Comments   []models.UserComment
+	Replies    []models.UserComment
+	Reactions  []models.UserReaction
+	SavedPosts []models.SavedPost
+	Sessions   []Session
+	Images     []string // archive paths under images/
+	Generated  time.Time
+}
+
+// ArchivePath is where the archive for the export with the given ID is kept in dir
+func ArchivePath(dir, id string) string {
+	return filepath.Join(dir, id+".zip")
+}
+
+// Write collects userID's data and writes it to w as a ZIP archive
+func Write(ctx context.Context, db *sql.DB, userID string, w io.Writer, opts Options) error {
+	d, err := collect(ctx, db, userID, opts)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(w)
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", d.Profile},
+		{"posts.json", d.Posts},
+		{"post_revisions.json", d.Revisions},
+		{"comments.json", d.Comments},
+		{"replies.json", d.Replies},
+		{"reactions.json", d.Reactions},
+		{"saved_posts.json", d.SavedPosts},
+		{"sessions.json", d.Sessions},
+	}
+	for _, f := range files {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: f.name, Method: zip.Deflate, Modified: d.Generated})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.v); err != nil {
+			return err
+		}
+	}
+
+	d.Images, err = writeImages(ctx, zw, d, opts.UploadDir)
+	if err != nil {
+		return err
+	}
+
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: "index.html", Method: zip.Deflate, Modified: d.Generated})
+	if err != nil {
+		return err
+	}
+	if err := indexTemplate.Execute(fw, d); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// WriteFile writes userID's export to file. The archive appears there only
+// once it is complete.
+func WriteFile(ctx context.Context, db *sql.DB, userID, file string, opts Options) error {
+	if err := os.MkdirAll(filepath.Dir(file), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".export-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := Write(ctx, db, userID, tmp, opts); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func collect(ctx context.Context, db *sql.DB, userID string, opts Options) (*data, error) {
+	d := &data{Generated: time.Now().UTC()}
+
+	var err error
+	d.Profile, err = sqlite.GetUserByID(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	d.Posts, err = all(func(page int) ([]models.Post, error) {
+		posts, _, err := sqlite.GetPosts(ctx, db, sqlite.PostListOptions{AuthorID: userID, Page: page, Limit: pageSize})
+		return posts, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.Revisions, err = all(func(page int) ([]models.PostRevision, error) {
+		return sqlite.GetUserPostRevisions(ctx, db, userID, page, pageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	written, err := all(func(page int) ([]models.UserComment, error) {
+		return sqlite.GetUserComments(ctx, db, userID, page, pageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.Comments, d.Replies = []models.UserComment{}, []models.UserComment{}
+	for _, c := range written {
+		if c.Type == "reply" {
+			d.Replies = append(d.Replies, c)
+		} else {
+			d.Comments = append(d.Comments, c)
+		}
+	}
+
+	d.Reactions, err = all(func(page int) ([]models.UserReaction, error) {
+		return sqlite.GetUserReactions(ctx, db, userID, "", "", page, pageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d.SavedPosts, err = all(func(page int) ([]models.SavedPost, error) {
+		return sqlite.GetSavedPosts(ctx, db, userID, "", page, pageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := sqlite.GetSessionTimes(ctx, db, userID)
+	if err != nil {
+		return nil, err
+	}
+	d.Sessions = make([]Session, len(created))
+	for i, t := range created {
+		d.Sessions[i] = Session{CreatedAt: t, ExpiresAt: t.Add(opts.SessionLifetime)}
+	}
+	return d, nil
+}
+
+// all calls fetch for successive pages until one comes back short
+func all[T any](fetch func(page int) ([]T, error)) ([]T, error) {
+	items := []T{}
+	for page := 1; ; page++ {
+		batch, err := fetch(page)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, batch...)
+		if len(batch) < pageSize {
+			return items, nil
+		}
+	}
+}
+
+// writeImages copies the user's avatar and post images into the archive
+// and returns their paths in it. Images that no longer exist on disk, and
+// the bundled default avatar, are skipped.
+func writeImages(ctx context.Context, zw *zip.Writer, d *data, uploadDir string) ([]string, error) {
+	var urls []string
+	for _, size := range avatar.Sizes {
+		urls = append(urls, avatar.URLs(d.Profile.AvatarURL)[strconv.Itoa(size)])
+	}
+	for _, p := range d.Posts {
+		if p.ImageURL != nil {
+			urls = append(urls, *p.ImageURL)
+		}
+	}
+
+	images := []string{}
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		file, ok := uploadPath(uploadDir, url)
+		if !ok || seen[url] {
+			continue
+		}
+		seen[url] = true
+		name := "images/" + path.Base(url)
+		err := copyFile(zw, name, file)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, name)
+	}
+	return images, nil
+}
+
+// uploadPath maps a /static/ URL of an uploaded avatar or post image to its
+// file in uploadDir
+func uploadPath(uploadDir, url string) (string, bool) {
+	rel, ok := strings.CutPrefix(url, "/static/")
+	if !ok || !filepath.IsLocal(filepath.FromSlash(rel)) {
+		return "", false
+	}
+	base := path.Base(rel)
+	if !strings.HasPrefix(base, "avatar_") && !strings.HasPrefix(base, "post_") {
+		return "", false
+	}
+	return filepath.Join(uploadDir, filepath.FromSlash(rel)), true
+}
+
+func copyFile(zw *zip.Writer, name, file string) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Images are already compressed
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: info.ModTime()})
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, f)
+	return err
+}
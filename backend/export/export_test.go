@@ -0,0 +1,267 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+)
+
+// writeUpload creates the uploaded file a /static/ URL points at
+func writeUpload(t *testing.T, uploadDir, url, content string) {
+	t.Helper()
+	file := filepath.Join(uploadDir, filepath.FromSlash(strings.TrimPrefix(url, "/static/")))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readArchive returns the names of the files in a ZIP archive, in order,
+// and their contents
+func readArchive(t *testing.T, b []byte) ([]string, map[string][]byte) {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, f.Name)
+		files[f.Name] = content
+	}
+	return names, files
+}
+
+// decode unmarshals the JSON file name of an archive into v
+func decode(t *testing.T, files map[string][]byte, name string, v any) {
+	t.Helper()
+	if err := json.Unmarshal(files[name], v); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	ctx := context.Background()
+	if err := sqlite.InitializeDatabase(filepath.Join(t.TempDir(), "forum.db"), "../schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sqlite.CloseDatabase)
+	db := sqlite.DB
+
+	const avatarURL = "/static/avatars/avatar_7_128.png"
+	if _, err := db.Exec(`
+		INSERT INTO users (id, username, email, password_hash, avatar_url, bio) VALUES
+			('u1', 'ann', 'ann@example.com', 'ann-password-hash', ?, 'Hello'),
+			('u2', 'ben', 'ben@example.com', 'ben-password-hash', '', '')
+	`, avatarURL); err != nil {
+		t.Fatal(err)
+	}
+	uploadDir := t.TempDir()
+	// The 256px avatar is missing on disk and left out
+	writeUpload(t, uploadDir, "/static/avatars/avatar_7_64.png", "avatar 64")
+	writeUpload(t, uploadDir, avatarURL, "avatar 128")
+	writeUpload(t, uploadDir, "/static/pictures/post_1.png", "post image")
+
+	mine, err := sqlite.CreatePost(ctx, db, "u1", nil, "first title", "first content", "/static/pictures/post_1.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlite.CreatePost(ctx, db, "u1", nil, "unedited", "content", ""); err != nil {
+		t.Fatal(err)
+	}
+	theirs, err := sqlite.CreatePost(ctx, db, "u2", nil, "their title", "their content", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, edit := range []struct {
+		id             int
+		title, content string
+	}{
+		{mine.ID, "second title", "first content"},
+		// Saving the same title and content again records no revision
+		{mine.ID, "second title", "first content"},
+		{mine.ID, "second title", "final content"},
+		{theirs.ID, "their edit", "their content"},
+	} {
+		if err := sqlite.UpdatePost(ctx, db, edit.id, edit.title, edit.content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	comment, err := sqlite.CreateComment(ctx, db, "u1", theirs.ID, "my comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlite.CreateReplyComment(ctx, db, "u1", comment.ID, "my reply"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sqlite.CreateComment(ctx, db, "u2", mine.ID, "their comment"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.ToggleLike(ctx, db, "u1", "post", theirs.ID, "like"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.ToggleLike(ctx, db, "u2", "post", mine.ID, "dislike"); err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlite.SavePost(ctx, db, "u1", theirs.ID, "reading"); err != nil {
+		t.Fatal(err)
+	}
+	sessionID, err := sqlite.CreateSession(ctx, db, "u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := Write(ctx, db, "u1", &buf, Options{UploadDir: uploadDir, SessionLifetime: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	names, files := readArchive(t, buf.Bytes())
+
+	want := []string{
+		"profile.json", "posts.json", "post_revisions.json", "comments.json", "replies.json",
+		"reactions.json", "saved_posts.json", "sessions.json",
+		"images/avatar_7_64.png", "images/avatar_7_128.png", "images/post_1.png", "index.html",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("files = %q, want %q", names, want)
+	}
+	for name, content := range map[string]string{
+		"images/avatar_7_64.png":  "avatar 64",
+		"images/avatar_7_128.png": "avatar 128",
+		"images/post_1.png":       "post image",
+	} {
+		if string(files[name]) != content {
+			t.Errorf("%s = %q, want %q", name, files[name], content)
+		}
+	}
+
+	var profile models.User
+	decode(t, files, "profile.json", &profile)
+	if profile.ID != "u1" || profile.Username != "ann" || profile.Email != "ann@example.com" || profile.Bio != "Hello" || profile.AvatarURL != avatarURL {
+		t.Errorf("profile = %+v", profile)
+	}
+
+	var posts []models.Post
+	decode(t, files, "posts.json", &posts)
+	var titles []string
+	for _, p := range posts {
+		titles = append(titles, p.Title+": "+p.Content)
+	}
+	if want := []string{"unedited: content", "second title: final content"}; !slices.Equal(titles, want) {
+		t.Errorf("posts = %q, want %q", titles, want)
+	}
+
+	var revisions []models.PostRevision
+	decode(t, files, "post_revisions.json", &revisions)
+	var versions []string
+	for _, r := range revisions {
+		if r.PostID != mine.ID || r.PostTitle != "second title" {
+			t.Errorf("revision of post %d %q, want post %d %q", r.PostID, r.PostTitle, mine.ID, "second title")
+		}
+		if r.CreatedAt.IsZero() || r.ReplacedAt.Before(r.CreatedAt) {
+			t.Errorf("revision saved %v and replaced %v", r.CreatedAt, r.ReplacedAt)
+		}
+		versions = append(versions, r.Title+": "+r.Content)
+	}
+	if want := []string{"first title: first content", "second title: first content"}; !slices.Equal(versions, want) {
+		t.Errorf("revisions = %q, want %q", versions, want)
+	}
+
+	var comments, replies []models.UserComment
+	decode(t, files, "comments.json", &comments)
+	decode(t, files, "replies.json", &replies)
+	if len(comments) != 1 || comments[0].Content != "my comment" || comments[0].PostTitle != "their edit" {
+		t.Errorf("comments = %+v", comments)
+	}
+	if len(replies) != 1 || replies[0].Content != "my reply" || replies[0].Type != "reply" {
+		t.Errorf("replies = %+v", replies)
+	}
+
+	var reactions []models.UserReaction
+	decode(t, files, "reactions.json", &reactions)
+	if len(reactions) != 1 || reactions[0].Type != "like" || reactions[0].TargetID != theirs.ID {
+		t.Errorf("reactions = %+v", reactions)
+	}
+
+	var saved []models.SavedPost
+	decode(t, files, "saved_posts.json", &saved)
+	if len(saved) != 1 || saved[0].Post.ID != theirs.ID || saved[0].Collection != "reading" {
+		t.Errorf("saved posts = %+v", saved)
+	}
+
+	var sessions []map[string]any
+	decode(t, files, "sessions.json", &sessions)
+	if len(sessions) != 1 || len(sessions[0]) != 2 || sessions[0]["created_at"] == nil || sessions[0]["expires_at"] == nil {
+		t.Errorf("sessions = %v, want created_at and expires_at only", sessions)
+	}
+
+	index := string(files["index.html"])
+	for _, s := range []string{"Post revisions (2)", "first title", "final content", "my reply", `href="images/post_1.png"`} {
+		if !strings.Contains(index, s) {
+			t.Errorf("index.html does not contain %q", s)
+		}
+	}
+
+	// Neither credentials nor anyone else's writing leak into any file
+	for name, content := range files {
+		for _, s := range []string{sessionID, "ann-password-hash", "their comment", "ben@example.com"} {
+			if bytes.Contains(content, []byte(s)) {
+				t.Errorf("%s contains %q", name, s)
+			}
+		}
+	}
+}
+
+func TestUploadPath(t *testing.T) {
+	uploadDir := filepath.Join("data", "uploads")
+	tests := []struct {
+		url  string
+		want string // "" when rejected
+	}{
+		{"/static/pictures/post_1.png", filepath.Join(uploadDir, "pictures", "post_1.png")},
+		{"/static/avatars/avatar_7_128.png", filepath.Join(uploadDir, "avatars", "avatar_7_128.png")},
+		{"/static/avatar_old.png", filepath.Join(uploadDir, "avatar_old.png")},
+
+		{"/static/../post_1.png", ""},
+		{"/static/../etc/avatar_x.png", ""},
+		{"/static/pictures/../../post_1.png", ""},
+		{"/static//etc/post_1.png", ""},
+		{"/static/", ""},
+		{"/static/pictures/icon1.png", ""},
+		{"/static/pictures/default.png", ""},
+		{"/uploads/post_1.png", ""},
+		{"static/pictures/post_1.png", ""},
+		{"https://example.com/static/pictures/post_1.png", ""},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		got, ok := uploadPath(uploadDir, tt.url)
+		if ok != (tt.want != "") || got != tt.want {
+			t.Errorf("uploadPath(%q) = %q, %v, want %q", tt.url, got, ok, tt.want)
+		}
+	}
+}
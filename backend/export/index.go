@@ -0,0 +1,102 @@
+package export
+
+import (
+	"html/template"
+	"time"
+)
+
+// indexTemplate renders index.html, a readable summary of the export that
+// links to its JSON files and images
+var indexTemplate = template.Must(template.New("index").Funcs(template.FuncMap{
+	"date": func(t time.Time) string { return t.UTC().Format("2 Jan 2006 15:04 UTC") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Forum data export for {{.Profile.Username}}</title>
+<style>
+body { font-family: sans-serif; max-width: 60rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.4; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 1rem; }
+th, td { border: 1px solid #ccc; padding: 0.3rem 0.5rem; text-align: left; vertical-align: top; }
+.content { white-space: pre-wrap; }
+img { max-width: 12rem; max-height: 12rem; margin: 0.25rem; }
+</style>
+</head>
+<body>
+<h1>Your forum data</h1>
+<p>Exported {{date .Generated}}. Each section below is also in the linked JSON file.</p>
+
+<h2>Profile</h2>
+<p><a href="profile.json">profile.json</a></p>
+<table>
+<tr><th>Username</th><td>{{.Profile.Username}}</td></tr>
+<tr><th>Email</th><td>{{.Profile.Email}}</td></tr>
+<tr><th>Bio</th><td class="content">{{.Profile.Bio}}</td></tr>
+<tr><th>Avatar</th><td>{{.Profile.AvatarURL}}</td></tr>
+<tr><th>Joined</th><td>{{date .Profile.CreatedAt}}</td></tr>
+</table>
+
+<h2>Posts ({{len .Posts}})</h2>
+<p><a href="posts.json">posts.json</a></p>
+{{range .Posts}}
+<h3>{{.Title}}</h3>
+<p><small>{{date .CreatedAt}}{{if .UpdatedAt.After .CreatedAt}}, edited {{date .UpdatedAt}}{{end}}</small></p>
+<div class="content">{{.Content}}</div>
+{{end}}
+
+<h2>Post revisions ({{len .Revisions}})</h2>
+<p><a href="post_revisions.json">post_revisions.json</a>. Each edit keeps the version it replaced.</p>
+{{if .Revisions}}
+<table>
+<tr><th>Post</th><th>Title</th><th>Content</th><th>Saved</th><th>Replaced</th></tr>
+{{range .Revisions}}<tr><td>{{.PostTitle}}</td><td>{{.Title}}</td><td class="content">{{.Content}}</td><td>{{date .CreatedAt}}</td><td>{{date .ReplacedAt}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Comments ({{len .Comments}})</h2>
+<p><a href="comments.json">comments.json</a></p>
+{{template "comments" .Comments}}
+
+<h2>Replies ({{len .Replies}})</h2>
+<p><a href="replies.json">replies.json</a></p>
+{{template "comments" .Replies}}
+
+<h2>Reactions ({{len .Reactions}})</h2>
+<p><a href="reactions.json">reactions.json</a></p>
+{{if .Reactions}}
+<table>
+<tr><th>Reaction</th><th>On</th><th>Post</th><th>Date</th></tr>
+{{range .Reactions}}<tr><td>{{.Type}}</td><td>{{.TargetType}}</td><td>{{.PostTitle}}</td><td>{{date .CreatedAt}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Saved posts ({{len .SavedPosts}})</h2>
+<p><a href="saved_posts.json">saved_posts.json</a></p>
+{{if .SavedPosts}}
+<table>
+<tr><th>Post</th><th>Collection</th><th>Saved</th></tr>
+{{range .SavedPosts}}<tr><td>{{.Post.Title}}</td><td>{{.Collection}}</td><td>{{date .SavedAt}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Sessions ({{len .Sessions}})</h2>
+<p><a href="sessions.json">sessions.json</a></p>
+{{if .Sessions}}
+<table>
+<tr><th>Logged in</th><th>Expires</th></tr>
+{{range .Sessions}}<tr><td>{{date .CreatedAt}}</td><td>{{date .ExpiresAt}}</td></tr>
+{{end}}</table>
+{{end}}
+
+<h2>Images ({{len .Images}})</h2>
+{{range .Images}}<a href="{{.}}"><img src="{{.}}" alt="{{.}}"></a>
+{{end}}
+</body>
+</html>
+{{define "comments"}}{{if .}}
+<table>
+<tr><th>Post</th><th>Comment</th><th>Date</th></tr>
+{{range .}}<tr><td>{{.PostTitle}}</td><td class="content">{{.Content}}</td><td>{{date .CreatedAt}}</td></tr>
+{{end}}</table>
+{{end}}{{end}}
+`))
@@ -0,0 +1,278 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"forum/config"
+	"forum/sqlite"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// openDB creates a database with posts posts, each with comments comments
+// by other users, and each comment with one reply
+func openDB(t *testing.T, posts, comments int) *sql.DB {
+	t.Helper()
+	if err := sqlite.InitializeDatabase(filepath.Join(t.TempDir(), "forum.db"), "../schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sqlite.CloseDatabase)
+	db := sqlite.DB
+
+	exec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	users := posts + comments
+	for u := range users {
+		exec(`INSERT INTO users (id, username, email, password_hash) VALUES (?, ?, ?, '')`,
+			fmt.Sprint("user-", u), fmt.Sprint("user", u), fmt.Sprint("user", u, "@example.com"))
+	}
+	exec(`INSERT INTO categories (name) VALUES ('general')`)
+	for p := range posts {
+		res, err := db.Exec(`INSERT INTO posts (user_id, title, content) VALUES (?, ?, 'content')`, fmt.Sprint("user-", p), fmt.Sprint("post ", p))
+		if err != nil {
+			t.Fatal(err)
+		}
+		postID, _ := res.LastInsertId()
+		exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, 1)`, postID)
+		exec(`INSERT INTO likes (user_id, target_type, post_id, type) VALUES ('user-0', 'post', ?, 'like')`, postID)
+		for c := range comments {
+			res, err := db.Exec(`INSERT INTO comments (user_id, post_id, content) VALUES (?, ?, 'comment')`, fmt.Sprint("user-", (p+c+1)%users), postID)
+			if err != nil {
+				t.Fatal(err)
+			}
+			commentID, _ := res.LastInsertId()
+			exec(`INSERT INTO replycomments (user_id, parent_comment_id, content) VALUES (?, ?, 'reply')`, fmt.Sprint("user-", c%users), commentID)
+		}
+	}
+	return db
+}
+
+// setLimits installs a config with the given GraphQL limits for the test
+func setLimits(t *testing.T, maxDepth, maxComplexity int) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.GraphQL.MaxDepth = maxDepth
+	cfg.GraphQL.MaxComplexity = maxComplexity
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+}
+
+var (
+	recorderOnce sync.Once
+	recorder     *tracetest.SpanRecorder
+)
+
+// sqliteCalls returns how many sqlite calls the test binary has made since
+// its first call, counted from the span each one ends. otel hands the
+// global provider to existing tracers only once, so every test shares one
+// recorder.
+func sqliteCalls() int {
+	recorderOnce.Do(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	})
+	n := 0
+	for _, span := range recorder.Ended() {
+		if strings.HasPrefix(span.Name(), "sqlite.") {
+			n++
+		}
+	}
+	return n
+}
+
+func run(t *testing.T, db *sql.DB, query string, vars map[string]any) ([]string, any) {
+	t.Helper()
+	return runAs(t, db, "", query, vars)
+}
+
+// runAs executes query with viewer as the logged in user
+func runAs(t *testing.T, db *sql.DB, viewer, query string, vars map[string]any) ([]string, any) {
+	t.Helper()
+	ctx := withLoaders(context.Background(), newLoaders(db, viewer))
+	result := execute(ctx, request{Query: query, Variables: vars})
+	var errs []string
+	for _, err := range result.Errors {
+		errs = append(errs, err.Message)
+	}
+	return errs, result.Data
+}
+
+func TestLimitsRejectBeforeExecution(t *testing.T) {
+	db := openDB(t, 2, 2)
+
+	tests := []struct {
+		name          string
+		maxDepth      int
+		maxComplexity int
+		query         string
+		vars          map[string]any
+		want          string
+	}{
+		{
+			name:     "too deep",
+			maxDepth: 3, maxComplexity: 10000,
+			query: `{ posts { comments { replies { author { username } } } } }`,
+			want:  "query depth 5 exceeds the limit of 3",
+		},
+		{
+			name:     "too deep through a fragment",
+			maxDepth: 3, maxComplexity: 10000,
+			query: `{ posts { ...deep } } fragment deep on Post { comments { author { username } } }`,
+			want:  "query depth 4 exceeds the limit of 3",
+		},
+		{
+			name:     "too costly",
+			maxDepth: 10, maxComplexity: 100,
+			// 1 + 50 * (1 + 10 * (1 + 1))
+			query: `{ posts(limit: 50) { comments { id content } } }`,
+			want:  "query complexity 1051 exceeds the limit of 100",
+		},
+		{
+			name:     "too costly through a variable",
+			maxDepth: 10, maxComplexity: 100,
+			query: `query($n: Int) { posts(limit: $n) { id title } }`,
+			vars:  map[string]any{"n": float64(50)},
+			want:  "query complexity 101 exceeds the limit of 100",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setLimits(t, tt.maxDepth, tt.maxComplexity)
+			before := sqliteCalls()
+			errs, data := run(t, db, tt.query, tt.vars)
+			if len(errs) != 1 || errs[0] != tt.want {
+				t.Errorf("errors = %q, want [%q]", errs, tt.want)
+			}
+			if data != nil {
+				t.Errorf("data = %v, want none", data)
+			}
+			if n := sqliteCalls() - before; n != 0 {
+				t.Errorf("rejected query ran %d sqlite calls", n)
+			}
+		})
+	}
+
+	t.Run("within limits", func(t *testing.T) {
+		setLimits(t, 5, 10000)
+		errs, _ := run(t, db, `{ posts { comments { replies { author { username } } } } }`, nil)
+		if len(errs) != 0 {
+			t.Errorf("errors = %q, want none", errs)
+		}
+	})
+	t.Run("introspection is free", func(t *testing.T) {
+		setLimits(t, 2, 5)
+		errs, _ := run(t, db, `{ __schema { types { name fields { name type { name } } } } }`, nil)
+		if len(errs) != 0 {
+			t.Errorf("errors = %q, want none", errs)
+		}
+	})
+}
+
+// TestQueriesAreBatched checks that the number of sqlite calls for a nested
+// query is bounded, however many posts and comments it returns
+func TestQueriesAreBatched(t *testing.T) {
+	const query = `{
+		posts(limit: 20) {
+			title
+			author { username }
+			categories { name }
+			reactions { likes dislikes viewerReaction }
+			comments {
+				content
+				author { username }
+				reactions { likes }
+				replies { content author { username } reactions { likes } }
+			}
+		}
+	}`
+	setLimits(t, 10, 100000)
+
+	calls := func(posts, comments int) int {
+		db := openDB(t, posts, comments)
+		before := sqliteCalls()
+		errs, data := run(t, db, query, nil)
+		if len(errs) != 0 {
+			t.Fatalf("errors = %q", errs)
+		}
+		got := data.(map[string]any)["posts"].([]any)
+		if len(got) != posts {
+			t.Fatalf("got %d posts, want %d", len(got), posts)
+		}
+		return sqliteCalls() - before
+	}
+
+	// The posts, then at most one call per loader and depth: users at up to
+	// three depths, categories, comments, replies and three reaction counts.
+	// Users already loaded at one depth can save a call at the next.
+	for _, size := range []struct{ posts, comments int }{{2, 1}, {20, 5}} {
+		if n := calls(size.posts, size.comments); n > 10 {
+			t.Errorf("%d sqlite calls for %d posts with %d comments each, want at most 10", n, size.posts, size.comments)
+		}
+	}
+}
+
+func TestReactionCounts(t *testing.T) {
+	db := openDB(t, 1, 1)
+	for _, q := range []string{
+		`INSERT INTO reaction_types (name, emoji, position) VALUES ('thanks', '🙏', 2), ('heart', '❤️', 5)`,
+		`INSERT INTO reaction_types (name, emoji, position, enabled) VALUES ('meh', '😐', 3, 0)`,
+		`INSERT INTO likes (user_id, target_type, post_id, type) VALUES
+			('user-0', 'post', 1, 'thanks'), ('user-1', 'post', 1, 'thanks'),
+			('user-1', 'post', 1, 'heart'), ('user-1', 'post', 1, 'meh')`,
+		`INSERT INTO likes (user_id, target_type, comment_id, type) VALUES
+			('user-0', 'comment', 1, 'dislike'), ('user-0', 'comment', 1, 'heart')`,
+		`INSERT INTO likes (user_id, target_type, reply_id, type) VALUES
+			('user-0', 'reply', 1, 'like'), ('user-1', 'reply', 1, 'like'), ('user-1', 'reply', 1, 'thanks')`,
+	} {
+		if _, err := db.Exec(q); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setLimits(t, 10, 100000)
+
+	const query = `{
+		posts {
+			reactions { ...r }
+			comments { reactions { ...r } replies { reactions { ...r } } }
+		}
+	}
+	fragment r on Reaction { likes dislikes counts { name emoji count } viewerReaction viewerReactions }`
+
+	tests := []struct {
+		viewer string
+		want   string
+	}{
+		{"user-0", `{"posts":[{"comments":[{"reactions":{"counts":[{"count":1,"emoji":"👎","name":"dislike"},{"count":1,"emoji":"❤️","name":"heart"}],"dislikes":1,"likes":0,"viewerReaction":"dislike","viewerReactions":["dislike","heart"]},` +
+			`"replies":[{"reactions":{"counts":[{"count":2,"emoji":"👍","name":"like"},{"count":1,"emoji":"🙏","name":"thanks"}],"dislikes":0,"likes":2,"viewerReaction":"like","viewerReactions":["like"]}}]}],` +
+			`"reactions":{"counts":[{"count":1,"emoji":"👍","name":"like"},{"count":2,"emoji":"🙏","name":"thanks"},{"count":1,"emoji":"❤️","name":"heart"}],"dislikes":0,"likes":1,"viewerReaction":"like","viewerReactions":["like","thanks"]}}]}`},
+		{"", `{"posts":[{"comments":[{"reactions":{"counts":[{"count":1,"emoji":"👎","name":"dislike"},{"count":1,"emoji":"❤️","name":"heart"}],"dislikes":1,"likes":0,"viewerReaction":null,"viewerReactions":[]},` +
+			`"replies":[{"reactions":{"counts":[{"count":2,"emoji":"👍","name":"like"},{"count":1,"emoji":"🙏","name":"thanks"}],"dislikes":0,"likes":2,"viewerReaction":null,"viewerReactions":[]}}]}],` +
+			`"reactions":{"counts":[{"count":1,"emoji":"👍","name":"like"},{"count":2,"emoji":"🙏","name":"thanks"},{"count":1,"emoji":"❤️","name":"heart"}],"dislikes":0,"likes":1,"viewerReaction":null,"viewerReactions":[]}}]}`},
+	}
+	for _, tt := range tests {
+		errs, data := runAs(t, db, tt.viewer, query, nil)
+		if len(errs) != 0 {
+			t.Fatalf("errors = %q", errs)
+		}
+		got, err := json.Marshal(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != tt.want {
+			t.Errorf("as %q:\n got %s\nwant %s", tt.viewer, got, tt.want)
+		}
+	}
+}
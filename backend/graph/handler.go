@@ -0,0 +1,80 @@
+// Package graph serves the forum's posts, comments, replies, categories,
+// users and reactions as a read-only GraphQL API.
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/utils"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// Handler serves GraphQL queries sent as a JSON POST body or as GET query
+// parameters. The session cookie, when present, identifies the viewer.
+func Handler(db *sql.DB) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if r.Method == http.MethodGet {
+			q := r.URL.Query()
+			req.Query, req.OperationName = q.Get("query"), q.Get("operationName")
+			if v := q.Get("variables"); v != "" {
+				if err := json.Unmarshal([]byte(v), &req.Variables); err != nil {
+					utils.SendError(w, r, apierror.BadRequest("variables must be a JSON object"))
+					return
+				}
+			}
+		} else if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			utils.SendError(w, r, apierror.BadRequest("Invalid GraphQL request body"))
+			return
+		}
+		if req.Query == "" {
+			utils.SendError(w, r, apierror.BadRequest("query is required"))
+			return
+		}
+
+		// Anonymous requests are allowed; they just have no viewer
+		viewer, _ := utils.GetUserIDFromSession(db, r)
+		ctx := withLoaders(r.Context(), newLoaders(db, viewer))
+
+		utils.SendJSONResponse(w, execute(ctx, req), http.StatusOK)
+	})
+}
+
+func execute(ctx context.Context, req request) *graphql.Result {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if v := graphql.ValidateDocument(&schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+	limits := config.Current().GraphQL
+	if err := checkLimits(doc, req.Variables, limits.MaxDepth, limits.MaxComplexity); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       ctx,
+	})
+}
@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// defaultListSize is the number of items a list field without a limit
+// argument is assumed to return when estimating a query's cost
+const defaultListSize = 10
+
+// checkLimits rejects a document with an operation nested deeper than
+// maxDepth or costing more than maxComplexity. Every field costs 1, and the
+// selections under a list field are multiplied by its limit argument or by
+// defaultListSize. Introspection fields are free.
+func checkLimits(doc *ast.Document, vars map[string]any, maxDepth, maxComplexity int) error {
+	w := costWalker{fragments: make(map[string]*ast.FragmentDefinition), vars: vars}
+	for _, def := range doc.Definitions {
+		if frag, ok := def.(*ast.FragmentDefinition); ok {
+			w.fragments[frag.Name.Value] = frag
+		}
+	}
+
+	for _, def := range doc.Definitions {
+		op, ok := def.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		depth, cost := w.selectionSet(schema.QueryType(), op.SelectionSet, 1)
+		if depth > maxDepth {
+			return fmt.Errorf("query depth %d exceeds the limit of %d", depth, maxDepth)
+		}
+		if cost > maxComplexity {
+			return fmt.Errorf("query complexity %d exceeds the limit of %d", cost, maxComplexity)
+		}
+	}
+	return nil
+}
+
+type costWalker struct {
+	fragments map[string]*ast.FragmentDefinition
+	vars      map[string]any
+}
+
+// selectionSet returns the deepest field depth and the total cost of set,
+// whose fields belong to parent and sit at depth
+func (w *costWalker) selectionSet(parent *graphql.Object, set *ast.SelectionSet, depth int) (maxDepth, cost int) {
+	if set == nil {
+		return 0, 0
+	}
+	for _, sel := range set.Selections {
+		var d, c int
+		switch s := sel.(type) {
+		case *ast.Field:
+			d, c = w.field(parent, s, depth)
+		case *ast.InlineFragment:
+			d, c = w.selectionSet(parent, s.SelectionSet, depth)
+		case *ast.FragmentSpread:
+			if frag, ok := w.fragments[s.Name.Value]; ok {
+				d, c = w.selectionSet(parent, frag.SelectionSet, depth)
+			}
+		}
+		maxDepth = max(maxDepth, d)
+		cost += c
+	}
+	return maxDepth, cost
+}
+
+func (w *costWalker) field(parent *graphql.Object, f *ast.Field, depth int) (maxDepth, cost int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+	def, ok := parent.Fields()[f.Name.Value]
+	if !ok {
+		return depth, 1
+	}
+
+	typ, list := def.Type, false
+	for {
+		if nn, ok := typ.(*graphql.NonNull); ok {
+			typ = nn.OfType
+		} else if l, ok := typ.(*graphql.List); ok {
+			typ, list = l.OfType, true
+		} else {
+			break
+		}
+	}
+	obj, ok := typ.(*graphql.Object)
+	if !ok {
+		return depth, 1
+	}
+
+	childDepth, childCost := w.selectionSet(obj, f.SelectionSet, depth+1)
+	if list {
+		childCost *= w.listSize(f, def)
+	}
+	return max(depth, childDepth), 1 + childCost
+}
+
+// listSize returns the limit argument of f, its default, or defaultListSize
+func (w *costWalker) listSize(f *ast.Field, def *graphql.FieldDefinition) int {
+	for _, arg := range f.Arguments {
+		if arg.Name.Value != "limit" {
+			continue
+		}
+		switch v := arg.Value.(type) {
+		case *ast.IntValue:
+			if n, err := strconv.Atoi(v.Value); err == nil && n > 0 {
+				return n
+			}
+		case *ast.Variable:
+			switch n := w.vars[v.Name.Value].(type) {
+			case float64:
+				if n >= 1 {
+					return int(n)
+				}
+			case int:
+				if n >= 1 {
+					return n
+				}
+			}
+		}
+	}
+	for _, arg := range def.Args {
+		if n, ok := arg.DefaultValue.(int); ok && arg.Name() == "limit" && n > 0 {
+			return n
+		}
+	}
+	return defaultListSize
+}
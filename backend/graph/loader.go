@@ -0,0 +1,148 @@
+package graph
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+
+	"forum/logging"
+	"forum/models"
+	"forum/sqlite"
+)
+
+// errInternal is what clients see when a resolver hits a database error;
+// the cause is logged instead
+var errInternal = errors.New("internal error")
+
+// internalError logs err and returns errInternal
+func internalError(ctx context.Context, err error) error {
+	logging.FromContext(ctx).Error("graphql resolver failed", "err", err)
+	return errInternal
+}
+
+// loader batches the keys requested by one level of a query into a single
+// fetch. graphql-go resolves every field at a depth before it calls the
+// thunks those fields returned, so each loader runs one query per depth
+// rather than one per object.
+type loader[K comparable, V any] struct {
+	name    string
+	fetch   func(ctx context.Context, keys []K) (map[K]V, error)
+	mu      sync.Mutex
+	pending map[K]struct{}
+	done    map[K]result[V]
+}
+
+type result[V any] struct {
+	value V
+	err   error
+}
+
+func newLoader[K comparable, V any](name string, fetch func(context.Context, []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		name:    name,
+		fetch:   fetch,
+		pending: make(map[K]struct{}),
+		done:    make(map[K]result[V]),
+	}
+}
+
+// Load queues key for the next batch and returns a thunk yielding its
+// value. The first thunk called runs the batch for every queued key.
+// Keys the fetch does not return yield the zero value.
+func (l *loader[K, V]) Load(ctx context.Context, key K) func() (V, error) {
+	l.mu.Lock()
+	if _, ok := l.done[key]; !ok {
+		l.pending[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (V, error) {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if _, ok := l.done[key]; !ok {
+			l.run(ctx)
+		}
+		r := l.done[key]
+		return r.value, r.err
+	}
+}
+
+func (l *loader[K, V]) run(ctx context.Context) {
+	keys := make([]K, 0, len(l.pending))
+	for k := range l.pending {
+		keys = append(keys, k)
+	}
+	clear(l.pending)
+
+	values, err := l.fetch(ctx, keys)
+	if err != nil {
+		logging.FromContext(ctx).Error("graphql batch load failed", "loader", l.name, "keys", len(keys), "err", err)
+		err = errInternal
+	}
+	for _, k := range keys {
+		l.done[k] = result[V]{value: values[k], err: err}
+	}
+}
+
+// loaders holds the per-request loaders and the logged in user, if any
+type loaders struct {
+	db     *sql.DB
+	viewer string
+
+	users            *loader[string, models.User]
+	categories       *loader[int, models.Category]
+	comments         *loader[int, []models.Comment]
+	replies          *loader[int, []models.ReplyComment]
+	postReactions    *loader[int, models.ReactionCounts]
+	commentReactions *loader[int, models.ReactionCounts]
+	replyReactions   *loader[int, models.ReactionCounts]
+	reactionTypes    *loader[struct{}, []models.ReactionType]
+}
+
+func newLoaders(db *sql.DB, viewer string) *loaders {
+	return &loaders{
+		db:     db,
+		viewer: viewer,
+		users: newLoader("users", func(ctx context.Context, ids []string) (map[string]models.User, error) {
+			return sqlite.GetUsersByIDs(ctx, db, ids)
+		}),
+		categories: newLoader("categories", func(ctx context.Context, ids []int) (map[int]models.Category, error) {
+			return sqlite.GetCategoriesByIDs(ctx, db, ids)
+		}),
+		comments: newLoader("comments", func(ctx context.Context, postIDs []int) (map[int][]models.Comment, error) {
+			return sqlite.GetCommentsByPostIDs(ctx, db, postIDs)
+		}),
+		replies: newLoader("replies", func(ctx context.Context, commentIDs []int) (map[int][]models.ReplyComment, error) {
+			return sqlite.GetRepliesByCommentIDs(ctx, db, commentIDs)
+		}),
+		// The same summaries as the REST reactions embed, with the viewer's own
+		postReactions: newLoader("post_reactions", func(ctx context.Context, postIDs []int) (map[int]models.ReactionCounts, error) {
+			batch, err := sqlite.GetReactionSummaries(ctx, db, viewer, postIDs, nil, nil)
+			return batch.Posts, err
+		}),
+		commentReactions: newLoader("comment_reactions", func(ctx context.Context, commentIDs []int) (map[int]models.ReactionCounts, error) {
+			batch, err := sqlite.GetReactionSummaries(ctx, db, viewer, nil, commentIDs, nil)
+			return batch.Comments, err
+		}),
+		replyReactions: newLoader("reply_reactions", func(ctx context.Context, replyIDs []int) (map[int]models.ReactionCounts, error) {
+			batch, err := sqlite.GetReactionSummaries(ctx, db, viewer, nil, nil, replyIDs)
+			return batch.Replies, err
+		}),
+		// One key, so the types are read at most once per request
+		reactionTypes: newLoader("reaction_types", func(ctx context.Context, _ []struct{}) (map[struct{}][]models.ReactionType, error) {
+			types, err := sqlite.GetReactionTypes(ctx, db)
+			return map[struct{}][]models.ReactionType{{}: types}, err
+		}),
+	}
+}
+
+type loadersKey struct{}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
@@ -0,0 +1,349 @@
+package graph
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"forum/models"
+	"forum/sqlite"
+
+	"github.com/graphql-go/graphql"
+)
+
+// maxPostsLimit caps the posts(limit:) argument
+const maxPostsLimit = 100
+
+// reactionCount is the source value of the ReactionCount type
+type reactionCount struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// field resolves a field from its parent's Go value
+func field[T any](f func(T) any) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (any, error) {
+		return f(p.Source.(T)), nil
+	}
+}
+
+// thunk adapts a loader thunk to the signature graphql-go defers
+func thunk[V any](load func() (V, error), convert func(V) any) func() (any, error) {
+	return func() (any, error) {
+		v, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return convert(v), nil
+	}
+}
+
+// authorField resolves a User from the parent's user ID
+func authorField[T any](userID func(T) string) *graphql.Field {
+	return &graphql.Field{
+		Type: userType,
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			l := loadersFrom(p.Context)
+			return thunk(l.users.Load(p.Context, userID(p.Source.(T))), optionalUser), nil
+		},
+	}
+}
+
+// optionalUser maps a user the loader did not find to null
+func optionalUser(u models.User) any {
+	if u.ID == "" {
+		return nil
+	}
+	return u
+}
+
+// reactionsField resolves the Reaction summary of a post, comment or reply
+func reactionsField[T any](id func(T) int, counts func(*loaders) *loader[int, models.ReactionCounts]) *graphql.Field {
+	return &graphql.Field{
+		Type: graphql.NewNonNull(reactionType),
+		Resolve: func(p graphql.ResolveParams) (any, error) {
+			l := loadersFrom(p.Context)
+			return thunk(counts(l).Load(p.Context, id(p.Source.(T))), func(c models.ReactionCounts) any { return c }), nil
+		},
+	}
+}
+
+func parseID(v any) (int, error) {
+	id, err := strconv.Atoi(fmt.Sprint(v))
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid id %q", v)
+	}
+	return id, nil
+}
+
+var userType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "User",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"username":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"avatarUrl": &graphql.Field{Type: graphql.String, Resolve: field(func(u models.User) any { return u.AvatarURL })},
+		"createdAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(u models.User) any { return u.CreatedAt })},
+		"email": &graphql.Field{
+			Type:        graphql.String,
+			Description: "Only visible to the user themselves",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				u := p.Source.(models.User)
+				if loadersFrom(p.Context).viewer != u.ID {
+					return nil, nil
+				}
+				return u.Email, nil
+			},
+		},
+	},
+})
+
+var categoryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Category",
+	Fields: graphql.Fields{
+		"id":   &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"name": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+	},
+})
+
+var reactionCountType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "ReactionCount",
+	Description: "How many times one reaction type was given",
+	Fields: graphql.Fields{
+		"name":  &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"emoji": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"count": &graphql.Field{Type: graphql.NewNonNull(graphql.Int)},
+	},
+})
+
+var reactionType = graphql.NewObject(graphql.ObjectConfig{
+	Name:        "Reaction",
+	Description: "Reactions on a post, comment or reply",
+	Fields: graphql.Fields{
+		"likes":    &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(c models.ReactionCounts) any { return c.Likes })},
+		"dislikes": &graphql.Field{Type: graphql.NewNonNull(graphql.Int), Resolve: field(func(c models.ReactionCounts) any { return c.Dislikes })},
+		"counts": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(reactionCountType))),
+			Description: "Each enabled reaction type given at least once, in display order",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				counts := p.Source.(models.ReactionCounts).Counts
+				return thunk(l.reactionTypes.Load(p.Context, struct{}{}), func(types []models.ReactionType) any {
+					list := []reactionCount{}
+					for _, t := range types {
+						if n := counts[t.Name]; n > 0 {
+							list = append(list, reactionCount{Name: t.Name, Emoji: t.Emoji, Count: n})
+						}
+					}
+					return list
+				}), nil
+			},
+		},
+		"viewerReaction": &graphql.Field{
+			Type:        graphql.String,
+			Description: `"like" or "dislike" if the logged in user reacted, otherwise null`,
+			Resolve: field(func(c models.ReactionCounts) any {
+				if c.ViewerReaction == "" {
+					return nil
+				}
+				return c.ViewerReaction
+			}),
+		},
+		"viewerReactions": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String))),
+			Description: "Every reaction type the logged in user gave, empty when logged out",
+			Resolve: field(func(c models.ReactionCounts) any {
+				if c.ViewerReactions == nil {
+					return []string{}
+				}
+				return c.ViewerReactions
+			}),
+		},
+	},
+})
+
+var replyType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Reply",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"content":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(r models.ReplyComment) any { return r.CreatedAt })},
+		"updatedAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(r models.ReplyComment) any { return r.UpdatedAt })},
+		"author":    authorField(func(r models.ReplyComment) string { return r.UserID }),
+		"reactions": reactionsField(
+			func(r models.ReplyComment) int { return r.ID },
+			func(l *loaders) *loader[int, models.ReactionCounts] { return l.replyReactions },
+		),
+	},
+})
+
+var commentType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Comment",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"content":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"createdAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(c models.Comment) any { return c.CreatedAt })},
+		"updatedAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(c models.Comment) any { return c.UpdatedAt })},
+		"author":    authorField(func(c models.Comment) string { return c.UserID }),
+		"replies": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(replyType))),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				return thunk(l.replies.Load(p.Context, p.Source.(models.Comment).ID), func(r []models.ReplyComment) any {
+					if r == nil {
+						return []models.ReplyComment{}
+					}
+					return r
+				}), nil
+			},
+		},
+		"reactions": reactionsField(
+			func(c models.Comment) int { return c.ID },
+			func(l *loaders) *loader[int, models.ReactionCounts] { return l.commentReactions },
+		),
+	},
+})
+
+var postType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Post",
+	Fields: graphql.Fields{
+		"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+		"title":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"content":   &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+		"imageUrl":  &graphql.Field{Type: graphql.String, Resolve: field(func(p models.Post) any { return p.ImageURL })},
+		"createdAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(p models.Post) any { return p.CreatedAt })},
+		"updatedAt": &graphql.Field{Type: graphql.DateTime, Resolve: field(func(p models.Post) any { return p.UpdatedAt })},
+		"author":    authorField(func(p models.Post) string { return p.UserID }),
+		"categories": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				ids := p.Source.(models.Post).CategoryIDs
+				loads := make([]func() (models.Category, error), len(ids))
+				for i, id := range ids {
+					loads[i] = l.categories.Load(p.Context, id)
+				}
+				return func() (any, error) {
+					categories := make([]models.Category, 0, len(loads))
+					for _, load := range loads {
+						c, err := load()
+						if err != nil {
+							return nil, err
+						}
+						if c.ID != 0 {
+							categories = append(categories, c)
+						}
+					}
+					return categories, nil
+				}, nil
+			},
+		},
+		"comments": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(commentType))),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				return thunk(l.comments.Load(p.Context, p.Source.(models.Post).ID), func(c []models.Comment) any {
+					if c == nil {
+						return []models.Comment{}
+					}
+					return c
+				}), nil
+			},
+		},
+		"reactions": reactionsField(
+			func(p models.Post) int { return p.ID },
+			func(l *loaders) *loader[int, models.ReactionCounts] { return l.postReactions },
+		),
+	},
+})
+
+var queryType = graphql.NewObject(graphql.ObjectConfig{
+	Name: "Query",
+	Fields: graphql.Fields{
+		"me": &graphql.Field{
+			Type:        userType,
+			Description: "The logged in user, or null",
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				if l.viewer == "" {
+					return nil, nil
+				}
+				return thunk(l.users.Load(p.Context, l.viewer), optionalUser), nil
+			},
+		},
+		"user": &graphql.Field{
+			Type: userType,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				l := loadersFrom(p.Context)
+				return thunk(l.users.Load(p.Context, p.Args["id"].(string)), optionalUser), nil
+			},
+		},
+		"post": &graphql.Field{
+			Type: postType,
+			Args: graphql.FieldConfigArgument{"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)}},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				id, err := parseID(p.Args["id"])
+				if err != nil {
+					return nil, err
+				}
+				post, err := sqlite.GetPost(p.Context, loadersFrom(p.Context).db, id)
+				if errors.Is(err, sql.ErrNoRows) {
+					return nil, nil
+				}
+				if err != nil {
+					return nil, internalError(p.Context, err)
+				}
+				return post, nil
+			},
+		},
+		"posts": &graphql.Field{
+			Type:        graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(postType))),
+			Description: "Posts, newest first unless sort says otherwise",
+			Args: graphql.FieldConfigArgument{
+				"page":  &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 1},
+				"limit": &graphql.ArgumentConfig{Type: graphql.Int, DefaultValue: 10, Description: fmt.Sprintf("Page size, at most %d", maxPostsLimit)},
+				"sort":  &graphql.ArgumentConfig{Type: graphql.String, DefaultValue: "new", Description: "new, oldest, top, controversial or active"},
+			},
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				page, limit, sort := p.Args["page"].(int), p.Args["limit"].(int), p.Args["sort"].(string)
+				if page < 1 {
+					return nil, errors.New("page must be at least 1")
+				}
+				if limit < 1 || limit > maxPostsLimit {
+					return nil, fmt.Errorf("limit must be between 1 and %d", maxPostsLimit)
+				}
+				if _, ok := sqlite.PostSorts[sort]; !ok {
+					return nil, errors.New("sort must be one of new, oldest, top, controversial or active")
+				}
+				posts, _, err := sqlite.GetPosts(p.Context, loadersFrom(p.Context).db, sqlite.PostListOptions{Sort: sort, Page: page, Limit: limit})
+				if err != nil {
+					return nil, internalError(p.Context, err)
+				}
+				return posts, nil
+			},
+		},
+		"categories": &graphql.Field{
+			Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(categoryType))),
+			Resolve: func(p graphql.ResolveParams) (any, error) {
+				categories, err := sqlite.GetCategories(p.Context, loadersFrom(p.Context).db)
+				if err != nil {
+					return nil, internalError(p.Context, err)
+				}
+				if categories == nil {
+					categories = []models.Category{}
+				}
+				return categories, nil
+			},
+		},
+	},
+})
+
+var schema = func() graphql.Schema {
+	s, err := graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+	if err != nil {
+		panic(fmt.Sprintf("graph: invalid schema: %v", err))
+	}
+	return s
+}()
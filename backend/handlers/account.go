@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/validation"
+)
+
+// ChangePassword replaces the logged in user's password after checking the
+// current one, and logs them out of every other session
+func ChangePassword(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		CurrentPassword string `json:"current_password" validate:"required"`
+		NewPassword     string `json:"new_password" validate:"required,password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+	if err := validation.Struct(&request); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(r.Context(), db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+	if !utils.CheckPasswordHash(request.CurrentPassword, user.PasswordHash) {
+		utils.SendError(w, r, apierror.Forbidden("Current password is incorrect"))
+		return
+	}
+
+	hashedPassword, err := utils.HashPassword(request.NewPassword)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Error hashing password", err))
+		return
+	}
+	if err := sqlite.UpdatePassword(r.Context(), db, userID, hashedPassword); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to change password", err))
+		return
+	}
+
+	// RequireAuth succeeded, so the cookie is present
+	sessionCookie, _ := r.Cookie("session_id")
+	revoked, err := sqlite.DeleteOtherSessions(r.Context(), db, userID, sessionCookie.Value)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to revoke other sessions", err))
+		return
+	}
+
+	utils.SendJSONResponse(w, map[string]any{
+		"message":          "Password changed",
+		"revoked_sessions": revoked,
+	}, http.StatusOK)
+}
+
+// DeleteMe schedules the logged in user's account for deletion after the
+// configured grace period and logs them out everywhere. Logging in again
+// before then cancels it. In "anonymize" mode their posts, comments and
+// replies stay up under a placeholder user; in "delete" mode they go too.
+func DeleteMe(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Password string `json:"password" validate:"required"`
+		Mode     string `json:"mode" validate:"required,oneof=anonymize delete"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+	if err := validation.Struct(&request); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	user, err := sqlite.GetUserByID(r.Context(), db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+	if !utils.CheckPasswordHash(request.Password, user.PasswordHash) {
+		utils.SendError(w, r, apierror.Forbidden("Password is incorrect"))
+		return
+	}
+
+	now := time.Now()
+	if err := sqlite.RequestAccountDeletion(r.Context(), db, userID, request.Mode, now); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to schedule account deletion", err))
+		return
+	}
+
+	// Clear session cookie; the sessions themselves are already gone
+	http.SetCookie(w, &http.Cookie{
+		Name:   "session_id",
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	utils.SendJSONResponse(w, map[string]any{
+		"message":      "Account scheduled for deletion. Log in again before then to cancel.",
+		"mode":         request.Mode,
+		"delete_after": now.Add(config.Current().Accounts.DeletionGrace.Duration).UTC(),
+	}, http.StatusAccepted)
+}
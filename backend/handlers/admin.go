@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"forum/apierror"
+	"forum/scheduler"
+	"forum/utils"
+)
+
+// GetJobs lists scheduled background jobs with their next and last run
+func GetJobs(jobs *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		statuses, err := jobs.Status(r.Context())
+		if err != nil {
+			utils.SendError(w, r, apierror.Internal("Failed to fetch job status", err))
+			return
+		}
+		utils.SendJSONResponse(w, statuses, http.StatusOK)
+	}
+}
+
+// GetJobHistory returns the recent runs of one job
+func GetJobHistory(jobs *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+		if err != nil || limit < 1 || limit > 100 {
+			limit = 20
+		}
+
+		runs, err := jobs.History(r.Context(), r.URL.Query().Get("name"), limit)
+		if errors.Is(err, scheduler.ErrUnknownJob) {
+			utils.SendError(w, r, apierror.NotFound("Unknown job"))
+			return
+		}
+		if err != nil {
+			utils.SendError(w, r, apierror.Internal("Failed to fetch job history", err))
+			return
+		}
+		utils.SendJSONResponse(w, runs, http.StatusOK)
+	}
+}
+
+// RunJob triggers a job immediately
+func RunJob(jobs *scheduler.Scheduler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		err := jobs.RunNow(r.URL.Query().Get("name"))
+		switch {
+		case errors.Is(err, scheduler.ErrUnknownJob):
+			utils.SendError(w, r, apierror.NotFound("Unknown job"))
+		case errors.Is(err, scheduler.ErrAlreadyRunning):
+			utils.SendError(w, r, apierror.Conflict("Job is already running"))
+		case err != nil:
+			utils.SendError(w, r, apierror.Unavailable("Scheduler is not running"))
+		default:
+			utils.SendJSONResponse(w, map[string]string{"message": "Job started"}, http.StatusAccepted)
+		}
+	}
+}
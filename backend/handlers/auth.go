@@ -3,164 +3,131 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"io"
-	"log"
+	"errors"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strings"
 	"time"
 
+	"forum/apierror"
+	"forum/avatar"
+	"forum/config"
+	"forum/logging"
+	"forum/metrics"
 	"forum/sqlite"
 	"forum/utils"
+	"forum/validation"
 )
 
 func RegisterUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Parse multipart form data (e.g., image + text)
-	err := r.ParseMultipartForm(10 << 20) // 10 MB max
-	if err != nil {
-		utils.SendJSONError(w, "Error parsing form data", http.StatusBadRequest)
+	if err := parseMultipartForm(w, r, "Error parsing form data"); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
-	username := r.FormValue("username")
-	email := r.FormValue("email")
-	password := r.FormValue("password")
-
-	if username == "" || email == "" || password == "" {
-		utils.SendJSONError(w, "Missing required fields", http.StatusBadRequest)
+	input := struct {
+		Username string `json:"username" validate:"required,username"`
+		Email    string `json:"email" validate:"required,email"`
+		Password string `json:"password" validate:"required,password"`
+	}{
+		Username: strings.TrimSpace(r.FormValue("username")),
+		Email:    strings.TrimSpace(r.FormValue("email")),
+		Password: r.FormValue("password"),
+	}
+	if err := validation.Struct(&input); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
+	username, email, password := input.Username, input.Email, input.Password
 
-	// Handle avatar upload
-	var avatarURL string
-
-	file, handler, err := r.FormFile("avatar")
+	// Uploaded avatars are resized and re-encoded; without one the user
+	// gets an identicon
+	render, err := decodeAvatarUpload(r)
 	if err != nil {
-		log.Printf("No avatar uploaded or failed to read: %v\n", err)
-		avatarURL = "/static/profiles/default.png"
-	} else {
-		defer file.Close()
-
-		// Ensure the static directory exists
-		staticDir := "static"
-		if _, err := os.Stat(staticDir); os.IsNotExist(err) {
-			if err := os.MkdirAll(staticDir, 0o755); err != nil {
-				utils.SendJSONError(w, "Failed to create static directory", http.StatusInternalServerError)
-				return
-			}
-		}
-
-		// Sanitize and build a safe filename
-		safeFilename := filepath.Base(handler.Filename)
-		avatarFilename := fmt.Sprintf("avatar_%d_%s", time.Now().UnixNano(), safeFilename)
-		avatarPath := filepath.Join(staticDir, avatarFilename)
-
-		// Create destination file
-		dst, err := os.Create(avatarPath)
-		if err != nil {
-			log.Printf("Error creating file: %v\n", err)
-			utils.SendJSONError(w, "Failed to save avatar", http.StatusInternalServerError)
-			return
-		}
-		defer dst.Close()
-
-		// Optionally check MIME type (optional and basic)
-		buf := make([]byte, 512)
-		_, err = file.Read(buf)
-		if err != nil {
-			utils.SendJSONError(w, "Error reading avatar data", http.StatusBadRequest)
-			return
-		}
-		filetype := http.DetectContentType(buf)
-		if filetype != "image/jpeg" && filetype != "image/png" && filetype != "image/gif" {
-			utils.SendJSONError(w, "Unsupported image format (use JPG, PNG, or GIF)", http.StatusBadRequest)
-			return
-		}
-
-		// Reset file pointer before copying
-		file.Seek(0, io.SeekStart)
-
-		// Save the file
-		_, err = io.Copy(dst, file)
-		if err != nil {
-			log.Printf("Error saving avatar: %v\n", err)
-			utils.SendJSONError(w, "Error saving avatar", http.StatusInternalServerError)
-			return
-		}
-
-		avatarURL = "/" + avatarPath
-		log.Printf("Avatar uploaded successfully: %s\n", avatarURL)
+		utils.SendError(w, r, err)
+		return
+	}
+	if render == nil {
+		logging.FromContext(r.Context()).Debug("no avatar uploaded, using identicon")
+		render = avatar.Identicon(username)
 	}
 
 	// Hash password
 	hashedPassword, err := utils.HashPassword(password)
 	if err != nil {
-		utils.SendJSONError(w, "Error hashing password", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Error hashing password", err))
+		return
+	}
+
+	uploadDir := config.Current().Uploads.Dir
+	avatarURL, err := avatar.Save(uploadDir, render)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to save avatar", err))
 		return
 	}
 
 	// Save user to DB
-	err = sqlite.CreateUser(db, username, email, hashedPassword, avatarURL)
+	err = sqlite.CreateUser(r.Context(), db, username, email, hashedPassword, avatarURL)
 	if err != nil {
+		avatar.Remove(uploadDir, avatarURL)
 		if sqlite.IsUniqueConstraintError(err) {
-			utils.SendJSONError(w, "Username or email already exists", http.StatusConflict)
+			utils.SendError(w, r, apierror.Conflict("Username or email already exists"))
 		} else {
-			utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+			utils.SendError(w, r, apierror.Internal("Failed to create user", err))
 		}
 		return
 	}
 
+	metrics.Registrations.Inc()
 	utils.SendJSONResponse(w, map[string]string{"message": "User registered successfully"}, http.StatusCreated)
 }
 
 func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var credentials struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email    string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
 	}
 
 	err := json.NewDecoder(r.Body).Decode(&credentials)
 	if err != nil {
-		http.Error(w, "Invalid credentials format", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("Invalid credentials format"))
 		return
 	}
-	if credentials.Password == "" {
-		utils.SendJSONError(w, "Password cannot be empty", http.StatusBadRequest)
+	if err := validation.Struct(&credentials); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
 	// Get user from DB
-	user, err := sqlite.GetUserByEmail(db, credentials.Email)
+	user, err := sqlite.GetUserByEmail(r.Context(), db, credentials.Email)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			utils.SendJSONError(w, "Invalid email or password", http.StatusUnauthorized)
+			metrics.Logins.WithLabelValues("failure").Inc()
+			utils.SendError(w, r, apierror.Unauthorized("Invalid email or password"))
 			return
 		}
-		utils.SendJSONError(w, "Database error", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to look up user", err))
 		return
 	}
 
 	// Validate password
 	if !utils.CheckPasswordHash(credentials.Password, user.PasswordHash) {
-		utils.SendJSONError(w, "Invalid email or password", http.StatusUnauthorized)
+		metrics.Logins.WithLabelValues("failure").Inc()
+		utils.SendError(w, r, apierror.Unauthorized("Invalid email or password"))
+		return
+	}
+
+	// Logging in during the grace period keeps the account
+	cancelled, err := sqlite.CancelAccountDeletion(r.Context(), db, user.ID)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to cancel account deletion", err))
 		return
 	}
 
 	// Create session in database
-	sessionID, err := sqlite.CreateSession(db, user.ID)
+	sessionID, err := sqlite.CreateSession(r.Context(), db, user.ID)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to create session", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to create session", err))
 		return
 	}
 
@@ -169,26 +136,34 @@ func LoginUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 		Name:     "session_id",
 		Value:    sessionID,
 		Path:     "/",
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  time.Now().Add(config.Current().Session.Lifetime.Duration),
 		HttpOnly: true,
+		Secure:   config.Current().TLS.Enabled(),
 	})
 
+	metrics.Logins.WithLabelValues("success").Inc()
+	if cancelled {
+		utils.SendJSONResponse(w, map[string]string{"message": "Logged in; account deletion cancelled"}, http.StatusOK)
+		return
+	}
 	utils.SendJSONResponse(w, map[string]string{"message": "Logged in"}, http.StatusOK)
 }
 
 func GetUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	userID, err := utils.GetUserIDFromSession(db, r)
-	// log.Printf("errr: %v\n", err)
 
 	if err != nil {
-		utils.SendJSONError(w, "Unauthorized1", http.StatusUnauthorized)
-
+		utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
 		return
 	}
 
-	user, err := sqlite.GetUserByID(db, userID)
+	user, err := sqlite.GetUserByID(r.Context(), db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
 	if err != nil {
-		utils.SendJSONError(w, "User not found", http.StatusNotFound)
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
 		return
 	}
 
@@ -196,22 +171,17 @@ func GetUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 }
 
 func LogoutUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Get session cookie
 	sessionCookie, err := r.Cookie("session_id")
 	if err != nil {
-		utils.SendJSONError(w, "No active session", http.StatusUnauthorized)
+		utils.SendError(w, r, apierror.Unauthorized("No active session"))
 		return
 	}
 
 	// Remove session from database
-	err = sqlite.DeleteSession(db, sessionCookie.Value)
+	err = sqlite.DeleteSession(r.Context(), db, sessionCookie.Value)
 	if err != nil && err != sql.ErrNoRows {
-		utils.SendJSONError(w, "Failed to log out", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to log out", err))
 		return
 	}
 
@@ -228,20 +198,30 @@ func LogoutUser(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 func RequireAuth(db *sql.DB, w http.ResponseWriter, r *http.Request) (string, bool) {
 	userID, err := utils.GetUserIDFromSession(db, r)
-	// log.Printf("checking more errors: %v\n", err)
 
 	if err != nil || userID == "" {
-		http.Error(w, "Unauthorized2", http.StatusUnauthorized)
+		utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
 		return "", false
 	}
 	return userID, true
 }
 
+// GetOwner returns the public profile of the user_id query parameter
 func GetOwner(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	userId := r.URL.Query().Get("user_id")
-	user, err := sqlite.GetUserByID(db, userId)
+	user, err := sqlite.GetUserByID(r.Context(), db, userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
 	if err != nil {
-		utils.SendJSONError(w, "Wrong User Id", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
 	}
-	utils.SendJSONResponse(w, user, http.StatusOK)
+	profile, err := sqlite.GetProfile(r.Context(), db, user.Username)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch profile", err))
+		return
+	}
+	utils.SendJSONResponse(w, profile, http.StatusOK)
 }
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"image"
+	"io"
+	"net/http"
+	"strconv"
+
+	"forum/apierror"
+	"forum/avatar"
+	"forum/config"
+	"forum/logging"
+	"forum/metrics"
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/validation"
+)
+
+// UpdateAvatar replaces the logged in user's avatar with an uploaded image,
+// optionally cropped, and deletes the old one's files
+func UpdateAvatar(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	if err := parseMultipartForm(w, r, "Error parsing form data"); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	render, err := decodeAvatarUpload(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	if render == nil {
+		utils.SendError(w, r, validation.Errors{"avatar": "is required"}.Err())
+		return
+	}
+
+	user, ok := fetchUser(db, w, r, userID)
+	if !ok {
+		return
+	}
+	replaceAvatar(db, w, r, user, render)
+}
+
+// DeleteAvatar replaces the logged in user's avatar with an identicon
+// generated from their username, and deletes the old one's files
+func DeleteAvatar(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	user, ok := fetchUser(db, w, r, userID)
+	if !ok {
+		return
+	}
+	replaceAvatar(db, w, r, user, avatar.Identicon(user.Username))
+}
+
+// fetchUser loads userID, responding with an error when that fails
+func fetchUser(db *sql.DB, w http.ResponseWriter, r *http.Request, userID string) (*models.User, bool) {
+	user, err := sqlite.GetUserByID(r.Context(), db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return nil, false
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return nil, false
+	}
+	return user, true
+}
+
+// replaceAvatar saves render as user's avatar, deletes their old one and
+// responds with the new URLs
+func replaceAvatar(db *sql.DB, w http.ResponseWriter, r *http.Request, user *models.User, render avatar.Renderer) {
+	uploadDir := config.Current().Uploads.Dir
+	avatarURL, err := avatar.Save(uploadDir, render)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to save avatar", err))
+		return
+	}
+	if err := sqlite.UpdateAvatar(r.Context(), db, user.ID, avatarURL); err != nil {
+		avatar.Remove(uploadDir, avatarURL)
+		utils.SendError(w, r, apierror.Internal("Failed to update avatar", err))
+		return
+	}
+	if err := avatar.Remove(uploadDir, user.AvatarURL); err != nil {
+		logging.FromContext(r.Context()).Warn("failed to remove old avatar", "url", user.AvatarURL, "err", err)
+	}
+
+	utils.SendJSONResponse(w, map[string]any{
+		"avatar_url":  avatarURL,
+		"avatar_urls": avatar.URLs(avatarURL),
+	}, http.StatusOK)
+}
+
+// decodeAvatarUpload decodes the "avatar" file of a parsed multipart form,
+// cropped to the square given by the optional crop_x, crop_y and crop_size
+// fields. It returns a nil Renderer when no file was sent.
+func decodeAvatarUpload(r *http.Request) (avatar.Renderer, error) {
+	file, _, err := r.FormFile("avatar")
+	if errors.Is(err, http.ErrMissingFile) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, apierror.BadRequest("Error reading avatar data")
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, apierror.BadRequest("Error reading avatar data")
+	}
+	metrics.UploadBytes.WithLabelValues("avatar").Add(float64(len(data)))
+
+	var crop image.Rectangle
+	if r.FormValue("crop_size") != "" || r.FormValue("crop_x") != "" || r.FormValue("crop_y") != "" {
+		errs := validation.Errors{}
+		value := func(field string, least int) int {
+			v := r.FormValue(field)
+			if v == "" {
+				errs.Add(field, "is required")
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < least {
+				errs.Add(field, "must be a whole number of at least "+strconv.Itoa(least))
+			}
+			return n
+		}
+		x, y, size := value("crop_x", 0), value("crop_y", 0), value("crop_size", 1)
+		if err := errs.Err(); err != nil {
+			return nil, err
+		}
+		crop = image.Rect(x, y, x+size, y+size)
+	}
+
+	render, err := avatar.Decode(data, crop)
+	switch {
+	case errors.Is(err, avatar.ErrUnsupported):
+		return nil, apierror.UnsupportedMediaType("Unsupported image format (use JPG, PNG, or GIF)")
+	case errors.Is(err, avatar.ErrTooLarge):
+		return nil, validation.Errors{"avatar": "is too large; use an image of at most 4096x4096 pixels"}.Err()
+	case errors.Is(err, avatar.ErrCrop):
+		return nil, validation.Errors{"crop_size": "must select a square inside the image"}.Err()
+	case err != nil:
+		return nil, apierror.BadRequest("Error reading avatar data")
+	}
+	return render, nil
+}
@@ -5,27 +5,28 @@ import (
 	"encoding/json"
 	"net/http"
 
+	"forum/apierror"
 	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
+	"forum/validation"
 )
 
 func CreateCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var category models.Category
 	err := json.NewDecoder(r.Body).Decode(&category)
 	if err != nil {
-		http.Error(w, "Invalid category data", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("Invalid category data"))
+		return
+	}
+	if err := validation.Struct(&category); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
-	err = sqlite.CreateCategory(db, category.Name)
+	err = sqlite.CreateCategory(r.Context(), db, category.Name)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to create category", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to create category", err))
 		return
 	}
 
@@ -33,14 +34,9 @@ func CreateCategory(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 }
 
 func GetCategories(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	categories, err := sqlite.GetCategories(db)
+	categories, err := sqlite.GetCategories(r.Context(), db)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to fetch categories", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to fetch categories", err))
 		return
 	}
 
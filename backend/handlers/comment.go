@@ -3,94 +3,130 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"net/http"
 
+	"forum/apierror"
+	"forum/metrics"
 	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
+	"forum/validation"
 )
 
 // CreateComment creates a new comment
 func CreateComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var comment models.Comment
 	err := json.NewDecoder(r.Body).Decode(&comment)
 	if err != nil {
-		http.Error(w, "Invalid comment data", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("Invalid comment data"))
+		return
+	}
+	if id, err := pathID(r); err != nil {
+		utils.SendError(w, r, err)
 		return
+	} else if id != 0 {
+		comment.PostID = id
 	}
 
 	// Validate user session
 	userID, ok := RequireAuth(db, w, r)
-	if !ok || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !ok {
 		return
 	}
 	comment.UserID = userID
 
-	// Validate input: post_id must be set for a top-level comment
-	if comment.PostID == 0 {
-		http.Error(w, "Missing post_id", http.StatusBadRequest)
+	// post_id must be set for a top-level comment
+	errs := validation.Errors{}
+	errs.Var("post_id", comment.PostID, "required")
+	errs.Struct(&comment)
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
 	// Create top-level comment
-	comm, err := sqlite.CreateComment(db, comment.UserID, comment.PostID, comment.Content)
+	comm, err := sqlite.CreateComment(r.Context(), db, comment.UserID, comment.PostID, comment.Content)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to create comment", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to create comment", err))
 		return
 	}
 
+	metrics.Comments.WithLabelValues("comment").Inc()
 	utils.SendJSONResponse(w, comm, http.StatusCreated)
 }
 
 func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var reply models.ReplyComment
 	err := json.NewDecoder(r.Body).Decode(&reply)
 	if err != nil {
-		http.Error(w, "Invalid reply data", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("Invalid reply data"))
+		return
+	}
+	if id, err := pathID(r); err != nil {
+		utils.SendError(w, r, err)
 		return
+	} else if id != 0 {
+		reply.ParentCommentID = id
 	}
 
 	// Validate user session
 	userID, ok := RequireAuth(db, w, r)
-	if !ok || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !ok {
 		return
 	}
 	reply.UserID = userID
 
-	// Ensure parent_comment_id is provided
-	if reply.ParentCommentID == 0 {
-		http.Error(w, "Missing parent_comment_id", http.StatusBadRequest)
+	errs := validation.Errors{}
+	errs.Var("parent_comment_id", reply.ParentCommentID, "required")
+	errs.Struct(&reply)
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
 	// post_id should not be included for replies
 	if r.FormValue("post_id") != "" {
-		http.Error(w, "post_id not allowed for replies", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("post_id not allowed for replies"))
 		return
 	}
 
 	// Create the reply
-	createdReply, err := sqlite.CreateReplyComment(db, reply.UserID, reply.ParentCommentID, reply.Content)
+	createdReply, err := sqlite.CreateReplyComment(r.Context(), db, reply.UserID, reply.ParentCommentID, reply.Content)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to create reply", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to create reply", err))
 		return
 	}
 
+	metrics.Comments.WithLabelValues("reply").Inc()
 	utils.SendJSONResponse(w, createdReply, http.StatusCreated)
 }
 
+// GetCommentReplies lists the replies to the comment in the {id} path segment
+func GetCommentReplies(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	commentID, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	replies, err := sqlite.GetCommentReplies(r.Context(), db, commentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("Comment not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch replies", err))
+		return
+	}
+	if err := embedReplyReactions(db, r, replies); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+
+	utils.SendJSONResponse(w, replies, http.StatusOK)
+}
+
 // GetComments fetches comments for a post
 // func GetReplComments(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 // 	if r.Method != http.MethodGet {
@@ -111,7 +147,7 @@ func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 // 	}
 
 // 	// Fetch all comments for the post (flat list)
-// 	comments, err := sqlite.GetPostComments(db, postID)
+// 	comments, err := sqlite.GetPostComments(r.Context(), db, postID)
 // 	if err != nil {
 // 		utils.SendJSONError(w, "Failed to fetch comments", http.StatusInternalServerError)
 // 		return
@@ -122,36 +158,49 @@ func CreateReplComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 // DeleteComment deletes a comment
 func DeleteComment(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var request struct {
 		CommentID int `json:"comment_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request data", http.StatusBadRequest)
+	// v1 routes carry the ID in the path and need no body
+	if err := decodeOptionalJSON(r, &request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
 		return
 	}
+	if id, err := pathID(r); err != nil {
+		utils.SendError(w, r, err)
+		return
+	} else if id != 0 {
+		request.CommentID = id
+	}
 
 	// Validate user session and check if the user is the author of the comment
 	userID, err := utils.GetUserIDFromSession(db, r)
 	if err != nil || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Var("comment_id", request.CommentID, "required")
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
-	isAuthor, err := utils.IsAuthor(db, userID, request.CommentID, false)
-	if err != nil || !isAuthor {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	isAuthor, err := utils.IsAuthor(r.Context(), db, userID, request.CommentID, false)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to check comment author", err))
+		return
+	}
+	if !isAuthor {
+		utils.SendError(w, r, apierror.Forbidden("You can only delete your own comments"))
 		return
 	}
 
 	// Delete comment from database
-	err = sqlite.DeleteComment(db, request.CommentID)
+	err = sqlite.DeleteComment(r.Context(), db, request.CommentID)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to delete comment", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to delete comment", err))
 		return
 	}
 
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/export"
+	"forum/models"
+	"forum/scheduler"
+	"forum/sqlite"
+	"forum/utils"
+
+	"github.com/google/uuid"
+)
+
+// RequestDataExport returns a download link for an archive of the logged in
+// user's data. Small accounts are exported during the request; larger ones
+// are queued for the data_export job, which this starts at once, and the
+// client polls until the export is ready. An unexpired export is reused.
+func RequestDataExport(jobs *scheduler.Scheduler) func(*sql.DB, http.ResponseWriter, *http.Request) {
+	return func(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+		userID, ok := RequireAuth(db, w, r)
+		if !ok {
+			return
+		}
+		cfg := config.Current()
+		now := time.Now()
+
+		latest, err := sqlite.GetLatestDataExport(r.Context(), db, userID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			utils.SendError(w, r, apierror.Internal("Failed to fetch data export", err))
+			return
+		}
+		if err == nil {
+			switch {
+			case latest.Status == "pending":
+				sendDataExport(w, latest, http.StatusAccepted)
+				return
+			case latest.Status == "ready" && latest.ExpiresAt != nil && latest.ExpiresAt.After(now):
+				sendDataExport(w, latest, http.StatusOK)
+				return
+			}
+		}
+
+		items, err := sqlite.CountUserItems(r.Context(), db, userID)
+		if err != nil {
+			utils.SendError(w, r, apierror.Internal("Failed to size data export", err))
+			return
+		}
+
+		e := models.DataExport{ID: uuid.New().String(), UserID: userID, Status: "pending", RequestedAt: now}
+		if items > cfg.Exports.SyncLimit {
+			if err := sqlite.CreateDataExport(r.Context(), db, e); err != nil {
+				utils.SendError(w, r, apierror.Internal("Failed to queue data export", err))
+				return
+			}
+			// If the job is already running, its next run picks this up
+			if err := jobs.RunNow("data_export"); err != nil && !errors.Is(err, scheduler.ErrAlreadyRunning) {
+				slog.Warn("failed to start data export job", "err", err)
+			}
+			sendDataExport(w, e, http.StatusAccepted)
+			return
+		}
+
+		archive := export.ArchivePath(cfg.Exports.Dir, e.ID)
+		opts := export.Options{UploadDir: cfg.Uploads.Dir, SessionLifetime: cfg.Session.Lifetime.Duration}
+		if err := export.WriteFile(r.Context(), db, userID, archive, opts); err != nil {
+			utils.SendError(w, r, apierror.Internal("Failed to build data export", err))
+			return
+		}
+		expiresAt := now.Add(cfg.Exports.LinkLifetime.Duration)
+		e.Status, e.ExpiresAt = "ready", &expiresAt
+		if err := sqlite.CreateDataExport(r.Context(), db, e); err != nil {
+			os.Remove(archive)
+			utils.SendError(w, r, apierror.Internal("Failed to save data export", err))
+			return
+		}
+		sendDataExport(w, e, http.StatusOK)
+	}
+}
+
+// sendDataExport responds with the export's status, and its download link once ready
+func sendDataExport(w http.ResponseWriter, e models.DataExport, status int) {
+	if e.Status == "ready" {
+		e.DownloadURL = "/api/v1/exports/" + e.ID
+	}
+	utils.SendJSONResponse(w, e, status)
+}
+
+// DownloadDataExport serves a finished export archive to the user it
+// belongs to, until it expires. Other users get the same 404 as for a
+// missing export, so a leaked link reveals nothing.
+func DownloadDataExport(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+	e, err := sqlite.GetDataExport(r.Context(), db, r.PathValue("id"))
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && (e.UserID != userID || e.Status != "ready" || e.ExpiresAt == nil || !e.ExpiresAt.After(time.Now()))) {
+		utils.SendError(w, r, apierror.NotFound("Data export not found or expired"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch data export", err))
+		return
+	}
+
+	f, err := os.Open(export.ArchivePath(config.Current().Exports.Dir, e.ID))
+	if errors.Is(err, os.ErrNotExist) {
+		utils.SendError(w, r, apierror.NotFound("Data export not found or expired"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to open data export", err))
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to open data export", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="forum-data-export-`+e.RequestedAt.UTC().Format("2006-01-02")+`.zip"`)
+	w.Header().Set("Cache-Control", "private, no-store")
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"time"
+
+	"forum/apierror"
+	"forum/health"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/version"
+)
+
+// Healthz reports that the process is alive. It does not touch the database.
+func Healthz(w http.ResponseWriter, r *http.Request) {
+	utils.SendJSONResponse(w, map[string]string{"status": "ok"}, http.StatusOK)
+}
+
+// Readyz reports whether the server is ready to receive traffic
+func Readyz(checker *health.Checker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		checks, ok := checker.Check(ctx)
+		status, code := "ok", http.StatusOK
+		if !ok {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+		utils.SendJSONResponse(w, map[string]any{"status": status, "checks": checks}, code)
+	}
+}
+
+// GetVersion returns the build information and schema version
+func GetVersion(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	applied, err := sqlite.GetSchemaVersion(r.Context(), db)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to read schema version", err))
+		return
+	}
+
+	utils.SendJSONResponse(w, struct {
+		version.Info
+		SchemaVersion        int `json:"schema_version"`
+		AppliedSchemaVersion int `json:"applied_schema_version"`
+	}{version.Get(), sqlite.SchemaVersion, applied}, http.StatusOK)
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/utils"
+	"forum/validation"
+)
+
+// NotFound answers requests that match no route
+func NotFound(w http.ResponseWriter, r *http.Request) {
+	utils.SendError(w, r, apierror.NotFound("No route for "+r.URL.Path))
+}
+
+// pathID parses the {id} segment of /api/v1 routes. It returns 0 on legacy
+// routes, which carry the ID in the body or query string instead.
+func pathID(r *http.Request) (int, error) {
+	v := r.PathValue("id")
+	if v == "" {
+		return 0, nil
+	}
+	id, err := strconv.Atoi(v)
+	if err != nil || id < 1 {
+		return 0, apierror.BadRequest("Invalid id in path")
+	}
+	return id, nil
+}
+
+// decodeOptionalJSON decodes the request body into v. An empty body leaves
+// v unchanged, for v1 routes that take everything from the path.
+func decodeOptionalJSON(r *http.Request, v any) error {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// parseMultipartForm reads a multipart body of at most uploads.max_bytes.
+// A larger body fails with its *http.MaxBytesError, which SendError answers
+// with 413; any other parse error becomes a 400 with message.
+func parseMultipartForm(w http.ResponseWriter, r *http.Request, message string) error {
+	maxBytes := config.Current().Uploads.MaxBytes
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		if errors.As(err, new(*http.MaxBytesError)) {
+			return err
+		}
+		return apierror.BadRequest(message)
+	}
+	return nil
+}
+
+// timeWindows maps the window query parameter to how far back posts may
+// have been created. "all" has no limit.
+var timeWindows = map[string]time.Duration{
+	"day":   24 * time.Hour,
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+	"all":   0,
+}
+
+// windowStart reads the window query parameter and returns the earliest
+// creation time it allows, or the zero time for "all" and no window.
+// Unknown windows are added to errs.
+func windowStart(errs validation.Errors, r *http.Request) time.Time {
+	window := r.URL.Query().Get("window")
+	if window == "" {
+		return time.Time{}
+	}
+	errs.Var("window", window, "oneof=day week month all")
+	if d := timeWindows[window]; d > 0 {
+		return time.Now().Add(-d)
+	}
+	return time.Time{}
+}
+
+// deref returns the string s points to, or "" for nil
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -3,107 +3,377 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
 	"strconv"
 
+	"forum/apierror"
+	"forum/metrics"
+	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
+	"forum/validation"
 )
 
-// ToggleLike handles liking/disliking a post or comment
+// ToggleLike handles reacting to a post, comment or reply named in the body
 func ToggleLike(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+	toggleReaction(db, w, r, "")
+}
+
+// TogglePostReaction reacts to the post in the {id} path segment
+func TogglePostReaction(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	toggleReaction(db, w, r, "post")
+}
+
+// ToggleCommentReaction reacts to the comment in the {id} path segment
+func ToggleCommentReaction(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	toggleReaction(db, w, r, "comment")
+}
+
+// ToggleReplyReaction reacts to the reply in the {id} path segment
+func ToggleReplyReaction(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	toggleReaction(db, w, r, "reply")
+}
+
+// reactionTargetNames names each reaction target in error messages
+var reactionTargetNames = map[string]string{
+	"post":    "Post",
+	"comment": "Comment",
+	"reply":   "Reply",
+}
 
+// toggleReaction takes the target from the path when target is "post",
+// "comment" or "reply", and from the post_id, comment_id or reply_id body
+// field otherwise
+func toggleReaction(db *sql.DB, w http.ResponseWriter, r *http.Request, target string) {
 	// Define the request struct
 	var request struct {
 		PostID    *int   `json:"post_id,omitempty"`
 		CommentID *int   `json:"comment_id,omitempty"`
-		Type      string `json:"type"` // like or dislike
+		ReplyID   *int   `json:"reply_id,omitempty"`
+		Type      string `json:"type" validate:"required,max=32"`
 	}
 
 	// Decode request body
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request data", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
 		return
 	}
 
+	var targetID int
+	if target != "" {
+		id, err := pathID(r)
+		if err != nil {
+			utils.SendError(w, r, err)
+			return
+		}
+		targetID = id
+	}
+
 	// Validate user session
 	userID, ok := RequireAuth(db, w, r)
-	if !ok || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !ok {
 		return
 	}
 
-	// Ensure exactly one of PostID or CommentID is provided
-	if (request.PostID != nil && request.CommentID != nil) || (request.PostID == nil && request.CommentID == nil) {
-		http.Error(w, "Must provide either post_id or comment_id, but not both", http.StatusBadRequest)
+	// On the legacy route exactly one of PostID, CommentID or ReplyID must be provided
+	errs := validation.Errors{}
+	if target == "" {
+		given := 0
+		for name, id := range map[string]*int{"post": request.PostID, "comment": request.CommentID, "reply": request.ReplyID} {
+			if id != nil {
+				target, targetID = name, *id
+				given++
+			}
+		}
+		if given != 1 {
+			errs.Add("post_id", "or comment_id or reply_id is required, but only one")
+		}
+	}
+	errs.Struct(&request)
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
-	// Validate type
-	if request.Type != "like" && request.Type != "dislike" {
-		http.Error(w, "Invalid type. Must be 'like' or 'dislike'", http.StatusBadRequest)
+	err := sqlite.ToggleLike(r.Context(), db, userID, target, targetID, request.Type)
+	if errors.Is(err, sqlite.ErrUnknownReaction) {
+		errs.Add("type", "must be an enabled reaction type")
+		utils.SendError(w, r, errs.Err())
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound(reactionTargetNames[target]+" not found"))
 		return
 	}
-
-	// Call the updated toggle function with type
-	err := sqlite.ToggleLike(db, userID, request.PostID, request.CommentID, request.Type)
 	if err != nil {
-		utils.SendJSONError(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to toggle reaction", err))
 		return
 	}
 
+	metrics.Reactions.WithLabelValues(request.Type).Inc()
 	utils.SendJSONResponse(w, map[string]string{"message": "Reaction toggled successfully"}, http.StatusOK)
 }
 
-// GetReactions returns the total number of likes and dislikes for a post or comment
+// GetReactions returns the number of each reaction type on a post, comment or reply
 func GetReactions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	query := r.URL.Query()
-	postIDStr := query.Get("post_id")
-	commentIDStr := query.Get("comment_id")
-
-	var (
-		postID    *int
-		commentID *int
-		err       error
-	)
-
-	if postIDStr != "" {
-		var id int
-		if id, err = strconv.Atoi(postIDStr); err != nil {
-			http.Error(w, "Invalid post_id", http.StatusBadRequest)
-			return
+	for _, target := range []string{"post", "comment", "reply"} {
+		v := query.Get(target + "_id")
+		if v == "" {
+			continue
 		}
-		postID = &id
-	} else if commentIDStr != "" {
-		var id int
-		if id, err = strconv.Atoi(commentIDStr); err != nil {
-			http.Error(w, "Invalid comment_id", http.StatusBadRequest)
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			utils.SendError(w, r, apierror.BadRequest("Invalid "+target+"_id"))
 			return
 		}
-		commentID = &id
-	} else {
-		http.Error(w, "Must provide post_id or comment_id", http.StatusBadRequest)
+		sendReactionCounts(db, w, r, target, id)
+		return
+	}
+	utils.SendError(w, r, apierror.BadRequest("Must provide post_id, comment_id or reply_id"))
+}
+
+// GetPostReactions returns the reaction counts for the post in the {id} path segment
+func GetPostReactions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getTargetReactions(db, w, r, "post")
+}
+
+// GetCommentReactions returns the reaction counts for the comment in the {id} path segment
+func GetCommentReactions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getTargetReactions(db, w, r, "comment")
+}
+
+// GetReplyReactions returns the reaction counts for the reply in the {id} path segment
+func GetReplyReactions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getTargetReactions(db, w, r, "reply")
+}
+
+func getTargetReactions(db *sql.DB, w http.ResponseWriter, r *http.Request, target string) {
+	id, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	sendReactionCounts(db, w, r, target, id)
+}
+
+// sendReactionCounts responds with the counts by type and the viewer's own
+// reactions. likes and dislikes are kept for clients that predate counts.
+func sendReactionCounts(db *sql.DB, w http.ResponseWriter, r *http.Request, target string, id int) {
+	err := sqlite.CheckReactionTarget(r.Context(), db, target, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound(reactionTargetNames[target]+" not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
 		return
 	}
 
-	likes, dislikes, err := sqlite.CountLikesAndDislikes(db, postID, commentID)
+	var postIDs, commentIDs, replyIDs []int
+	switch target {
+	case "post":
+		postIDs = []int{id}
+	case "comment":
+		commentIDs = []int{id}
+	default:
+		replyIDs = []int{id}
+	}
+	batch, err := sqlite.GetReactionSummaries(r.Context(), db, viewerID(db, r), postIDs, commentIDs, replyIDs)
 	if err != nil {
-		utils.SendJSONError(w, fmt.Sprintf("Database error: %v", err), http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
 		return
 	}
 
-	utils.SendJSONResponse(w, map[string]int{
-		"likes":    likes,
-		"dislikes": dislikes,
-	}, http.StatusOK)
+	counts := batch.Replies[id]
+	switch target {
+	case "post":
+		counts = batch.Posts[id]
+	case "comment":
+		counts = batch.Comments[id]
+	}
+	utils.SendJSONResponse(w, counts, http.StatusOK)
+}
+
+// GetReactionTypes returns the reaction types users can give, in display order
+func GetReactionTypes(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	types, err := sqlite.GetReactionTypes(r.Context(), db)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch reaction types", err))
+		return
+	}
+	utils.SendJSONResponse(w, types, http.StatusOK)
+}
+
+// maxReactionListLimit caps the page size of the reaction listings
+const maxReactionListLimit = 100
+
+// GetPostReactors lists the users who reacted to the post in the {id} path segment
+func GetPostReactors(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getReactors(db, w, r, "post")
+}
+
+// GetCommentReactors lists the users who reacted to the comment in the {id} path segment
+func GetCommentReactors(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getReactors(db, w, r, "comment")
+}
+
+// GetReplyReactors lists the users who reacted to the reply in the {id} path segment
+func GetReplyReactors(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	getReactors(db, w, r, "reply")
+}
+
+func getReactors(db *sql.DB, w http.ResponseWriter, r *http.Request, target string) {
+	id, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	reactionType := r.URL.Query().Get("type")
+	errs := validation.Errors{}
+	errs.Var("type", reactionType, "max=32")
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	limit = min(limit, maxReactionListLimit)
+
+	reactors, err := sqlite.GetReactors(r.Context(), db, target, id, reactionType, page, limit)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound(reactionTargetNames[target]+" not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch reactions", err))
+		return
+	}
+	utils.SendJSONResponse(w, reactors, http.StatusOK)
+}
+
+// GetMyReactions lists everything the logged in user has reacted to, most
+// recent first
+func GetMyReactions(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	target, reactionType := query.Get("target"), query.Get("type")
+	errs := validation.Errors{}
+	if target != "" {
+		errs.Var("target", target, "oneof=post comment reply")
+	}
+	errs.Var("type", reactionType, "max=32")
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	limit = min(limit, maxReactionListLimit)
+
+	reactions, err := sqlite.GetUserReactions(r.Context(), db, userID, target, reactionType, page, limit)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch reactions", err))
+		return
+	}
+	utils.SendJSONResponse(w, reactions, http.StatusOK)
+}
+
+// GetReactionsBatch returns the counts and the viewer's own reactions for
+// every post, comment and reply in the body, so a page needs one request
+// instead of one per reaction button
+func GetReactionsBatch(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		PostIDs    []int `json:"post_ids" validate:"max=100"`
+		CommentIDs []int `json:"comment_ids" validate:"max=100"`
+		ReplyIDs   []int `json:"reply_ids" validate:"max=100"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+	if err := validation.Struct(&request); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	batch, err := sqlite.GetReactionSummaries(r.Context(), db, viewerID(db, r), request.PostIDs, request.CommentIDs, request.ReplyIDs)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+	utils.SendJSONResponse(w, batch, http.StatusOK)
+}
+
+// embedPostReactions fills in Reactions on each post with one query
+func embedPostReactions(db *sql.DB, r *http.Request, posts []models.Post) error {
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	batch, err := sqlite.GetReactionSummaries(r.Context(), db, viewerID(db, r), ids, nil, nil)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		c := batch.Posts[posts[i].ID]
+		posts[i].Reactions = &c
+	}
+	return nil
+}
+
+// embedCommentReactions fills in Reactions on each comment and each of
+// their replies with one query
+func embedCommentReactions(db *sql.DB, r *http.Request, comments []models.Comment) error {
+	var commentIDs, replyIDs []int
+	for _, c := range comments {
+		commentIDs = append(commentIDs, c.ID)
+		for _, reply := range c.Replies {
+			replyIDs = append(replyIDs, reply.ID)
+		}
+	}
+	batch, err := sqlite.GetReactionSummaries(r.Context(), db, viewerID(db, r), nil, commentIDs, replyIDs)
+	if err != nil {
+		return err
+	}
+	for i := range comments {
+		c := batch.Comments[comments[i].ID]
+		comments[i].Reactions = &c
+		for j := range comments[i].Replies {
+			rc := batch.Replies[comments[i].Replies[j].ID]
+			comments[i].Replies[j].Reactions = &rc
+		}
+	}
+	return nil
+}
+
+// embedReplyReactions fills in Reactions on each reply with one query
+func embedReplyReactions(db *sql.DB, r *http.Request, replies []models.ReplyComment) error {
+	ids := make([]int, len(replies))
+	for i, reply := range replies {
+		ids[i] = reply.ID
+	}
+	batch, err := sqlite.GetReactionSummaries(r.Context(), db, viewerID(db, r), nil, nil, ids)
+	if err != nil {
+		return err
+	}
+	for i := range replies {
+		c := batch.Replies[replies[i].ID]
+		replies[i].Reactions = &c
+	}
+	return nil
+}
+
+// viewerID returns the logged in user's ID, or "" for anonymous requests
+func viewerID(db *sql.DB, r *http.Request) string {
+	userID, err := utils.GetUserIDFromSession(db, r)
+	if err != nil {
+		return ""
+	}
+	return userID
 }
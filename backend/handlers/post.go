@@ -3,31 +3,29 @@ package handlers
 import (
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"time"
 
+	"forum/apierror"
+	"forum/config"
+	"forum/metrics"
 	"forum/models"
 	"forum/sqlite"
 	"forum/utils"
+	"forum/validation"
 )
 
 // CreatePost creates a new post
 func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	// Parse multipart form
-	err := r.ParseMultipartForm(10 << 20) // 10 MB limit
-	if err != nil {
-		http.Error(w, "Could not parse form data", http.StatusBadRequest)
+	if err := parseMultipartForm(w, r, "Could not parse form data"); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
@@ -39,8 +37,19 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 	// Validate user session
 	userID, ok := RequireAuth(db, w, r)
-	if !ok || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	if !ok {
+		return
+	}
+
+	// Validate input before storing any upload
+	errs := validation.Errors{}
+	errs.Struct(models.Post{Title: title, Content: content})
+	errs.Var("category_names", categoryNames, "max=10")
+	for i, name := range categoryNames {
+		errs.Var(fmt.Sprintf("category_names[%d]", i), name, "required,max=50")
+	}
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
@@ -52,111 +61,214 @@ func CreatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 
 		ext := filepath.Ext(header.Filename)
 		filename := fmt.Sprintf("post_%s_%d%s", userID, time.Now().UnixNano(), ext)
-		dstPath := filepath.Join("static/pictures", filename)
+		dstPath := filepath.Join(config.Current().Uploads.Dir, "pictures", filename)
 
 		dst, err := os.Create(dstPath)
 		if err != nil {
-			http.Error(w, "Unable to save image", http.StatusInternalServerError)
+			utils.SendError(w, r, apierror.Internal("Unable to save image", err))
 			return
 		}
 		defer dst.Close()
 
-		if _, err := io.Copy(dst, file); err != nil {
-			http.Error(w, "Failed to write image", http.StatusInternalServerError)
+		written, err := io.Copy(dst, file)
+		if err != nil {
+			utils.SendError(w, r, apierror.Internal("Failed to write image", err))
 			return
 		}
+		metrics.UploadBytes.WithLabelValues("post_image").Add(float64(written))
 
-		imageURL = "/" + dstPath
+		imageURL = "/static/pictures/" + filename
 	}
 
 	// Get category IDs by resolving category names
-	categoryIDs, err := sqlite.GetOrCreateCategoryIDs(db, categoryNames)
+	categoryIDs, err := sqlite.GetOrCreateCategoryIDs(r.Context(), db, categoryNames)
 	if err != nil {
-		http.Error(w, "Failed to resolve categories", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to resolve categories", err))
 		return
 	}
 
 	// Create the post with categories
-	post, err := sqlite.CreatePost(db, userID, categoryIDs, title, content, imageURL)
+	post, err := sqlite.CreatePost(r.Context(), db, userID, categoryIDs, title, content, imageURL)
 	if err != nil {
-		log.Println("Error creating post:", err)
-		utils.SendJSONError(w, "Failed to create post", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to create post", err))
 		return
 	}
 
+	metrics.Posts.Inc()
+
 	// Send response
 	utils.SendJSONResponse(w, post, http.StatusCreated)
 }
 
-// GetPosts fetches posts (with optional filters)
-func GetPosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
+// maxPostListLimit caps the page size of the post listing
+const maxPostListLimit = 100
 
+// GetPosts fetches one page of posts in the order given by the sort query
+// parameter. The X-Next-Cursor header, when present, continues the listing
+// via the cursor parameter without repeating posts.
+func GetPosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 	// Extract pagination parameters from the URL query
 	page, limit := utils.GetPaginationParams(r)
+	opts := sqlite.PostListOptions{Sort: r.URL.Query().Get("sort"), Page: page, Limit: min(limit, maxPostListLimit)}
 
-	// Fetch posts with pagination
-	posts, err := sqlite.GetPosts(db, page, limit)
-	if err != nil {
-		fmt.Println("THE ERROR IS HERE")
-		utils.SendJSONError(w, "Failed to fetch posts", http.StatusInternalServerError)
+	errs := validation.Errors{}
+	if opts.Sort != "" {
+		errs.Var("sort", opts.Sort, "oneof=new oldest top controversial active")
+	}
+	opts.Since = windowStart(errs, r)
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
-
-	var fullPosts []models.Post
-
-	for _, post := range posts {
-		userInfo, err := sqlite.GetUserByID(db, post.UserID)
+	if c := r.URL.Query().Get("cursor"); c != "" {
+		cursor, err := sqlite.ParsePostCursor(c)
 		if err != nil {
-			utils.SendJSONError(w, "Failed to fetch post user information", http.StatusInternalServerError)
+			utils.SendError(w, r, apierror.BadRequest("Invalid cursor"))
 			return
 		}
-		post.ProfileAvatar = userInfo.AvatarURL 
-		fullPosts = append(fullPosts, post)
+		opts.After = &cursor
+	}
+
+	// Fetch posts with pagination
+	posts, next, err := sqlite.GetPosts(r.Context(), db, opts)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch posts", err))
+		return
+	}
+	if next != nil {
+		w.Header().Set("X-Next-Cursor", next.String())
+	}
+
+	if err := embedPostReactions(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+	if err := embedSavedFlags(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to check saved posts", err))
+		return
 	}
 
-	utils.SendJSONResponse(w, fullPosts, http.StatusOK)
+	utils.SendJSONResponse(w, posts, http.StatusOK)
 }
 
-// UpdatePost updates an existing post
-func UpdatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPut {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+// GetPost fetches a single post by the {id} path segment
+func GetPost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	postID, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	post, err := sqlite.GetPost(r.Context(), db, postID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("Post not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch post", err))
 		return
 	}
 
-	var post models.Post
-	err := json.NewDecoder(r.Body).Decode(&post)
+	author, err := sqlite.GetUserByID(r.Context(), db, post.UserID)
 	if err != nil {
-		http.Error(w, "Invalid post data", http.StatusBadRequest)
+		utils.SendError(w, r, apierror.Internal("Failed to fetch post user information", err))
+		return
+	}
+	post.Username = author.Username
+	post.ProfileAvatar = author.AvatarURL
+	if post.CategoryIDs == nil {
+		post.CategoryIDs = []int{}
+	}
+
+	posts := []models.Post{post}
+	if err := embedPostReactions(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
 		return
 	}
+	if err := embedSavedFlags(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to check saved posts", err))
+		return
+	}
+
+	utils.SendJSONResponse(w, posts[0], http.StatusOK)
+}
+
+// UpdatePost replaces the title and content of a post
+func UpdatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	updatePost(db, w, r, false)
+}
+
+// PatchPost changes only the post fields present in the request body
+func PatchPost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	updatePost(db, w, r, true)
+}
+
+func updatePost(db *sql.DB, w http.ResponseWriter, r *http.Request, partial bool) {
+	var request struct {
+		ID      int     `json:"id"`
+		Title   *string `json:"title"`
+		Content *string `json:"content"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid post data"))
+		return
+	}
+	if id, err := pathID(r); err != nil {
+		utils.SendError(w, r, err)
+		return
+	} else if id != 0 {
+		request.ID = id
+	}
 
 	// Validate user session
 	userID, err := utils.GetUserIDFromSession(db, r)
 	if err != nil || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Var("id", request.ID, "required")
+	if partial && request.Title == nil && request.Content == nil {
+		errs.Add("title", "or content is required")
+	}
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
 	// Ensure the post belongs to the user
-	existingPostData, err := sqlite.GetPost(db, post.ID)
+	post, err := sqlite.GetPost(r.Context(), db, request.ID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("Post not found"))
+		return
+	}
 	if err != nil {
-		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to read post data", err))
 		return
 	}
 
-	if existingPostData.UserID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+	if post.UserID != userID {
+		utils.SendError(w, r, apierror.Forbidden("You can only edit your own posts"))
 		return
 	}
 
-	err = sqlite.UpdatePost(db, post.ID, post.Title, post.Content)
+	// A full update treats missing fields as empty, so validation rejects them
+	if request.Title != nil || !partial {
+		post.Title = deref(request.Title)
+	}
+	if request.Content != nil || !partial {
+		post.Content = deref(request.Content)
+	}
+	if err := validation.Struct(&post); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	err = sqlite.UpdatePost(r.Context(), db, post.ID, post.Title, post.Content)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to update post", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to update post", err))
 		return
 	}
 
@@ -164,41 +276,54 @@ func UpdatePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 }
 
 func DeletePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodDelete {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
 	var request struct {
 		PostID int `json:"post_id"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-		http.Error(w, "Invalid request data", http.StatusBadRequest)
+	// v1 routes carry the ID in the path and need no body
+	if err := decodeOptionalJSON(r, &request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+	if id, err := pathID(r); err != nil {
+		utils.SendError(w, r, err)
 		return
+	} else if id != 0 {
+		request.PostID = id
 	}
 
 	// Validate user session
 	userID, err := utils.GetUserIDFromSession(db, r)
 	if err != nil || userID == "" {
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
+		return
+	}
+
+	errs := validation.Errors{}
+	errs.Var("post_id", request.PostID, "required")
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
 		return
 	}
 
 	// Ensure the post belongs to the user
-	existingPostData, err := sqlite.GetPost(db, request.PostID)
+	existingPostData, err := sqlite.GetPost(r.Context(), db, request.PostID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("Post not found"))
+		return
+	}
 	if err != nil {
-		utils.SendJSONError(w, "Failed to read post data", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to read post data", err))
 		return
 	}
 
 	if existingPostData.UserID != userID {
-		http.Error(w, "Forbidden", http.StatusForbidden)
+		utils.SendError(w, r, apierror.Forbidden("You can only delete your own posts"))
 		return
 	}
 
-	err = sqlite.DeletePost(db, request.PostID)
+	err = sqlite.DeletePost(r.Context(), db, request.PostID)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to delete post", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to delete post", err))
 		return
 	}
 
@@ -206,41 +331,48 @@ func DeletePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
 }
 
 func GetPostComments(db *sql.DB, w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	postIDStr := r.URL.Query().Get("post_id")
-	if postIDStr == "" {
-		http.Error(w, "Missing post_id parameter", http.StatusBadRequest)
-		return
-	}
-	postID, err := strconv.Atoi(postIDStr)
+	postID, err := pathID(r)
 	if err != nil {
-		http.Error(w, "Invalid post_id parameter", http.StatusBadRequest)
+		utils.SendError(w, r, err)
 		return
 	}
-	comments, err := sqlite.GetPostComments(db, postID)
+	if postID == 0 {
+		postIDStr := r.URL.Query().Get("post_id")
+		if postIDStr == "" {
+			utils.SendError(w, r, apierror.BadRequest("Missing post_id parameter"))
+			return
+		}
+		postID, err = strconv.Atoi(postIDStr)
+		if err != nil {
+			utils.SendError(w, r, apierror.BadRequest("Invalid post_id parameter"))
+			return
+		}
+	}
+	comments, err := sqlite.GetPostComments(r.Context(), db, postID)
 	if err != nil {
-		utils.SendJSONError(w, "Failed to fetch comments", http.StatusInternalServerError)
+		utils.SendError(w, r, apierror.Internal("Failed to fetch comments", err))
 		return
 	}
 
 	var fullComments []models.Comment
 
 	for _, comment := range comments {
-		userInfo, err := sqlite.GetUserByID(db, comment.UserID)
+		userInfo, err := sqlite.GetUserByID(r.Context(), db, comment.UserID)
 		if err != nil {
-			utils.SendJSONError(w, "Failed to fetch comment user information", http.StatusInternalServerError)
+			utils.SendError(w, r, apierror.Internal("Failed to fetch comment user information", err))
 			return
 		}
-		comment.UserName =userInfo.Username
+		comment.UserName = userInfo.Username
 		comment.ProfileAvatar = userInfo.AvatarURL
 
 		fullComments = append(fullComments, comment)
 
 	}
 
+	if err := embedCommentReactions(db, r, fullComments); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+
 	utils.SendJSONResponse(w, fullComments, http.StatusOK)
 }
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"forum/apierror"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/validation"
+)
+
+// maxProfileListLimit caps the page size of the profile post and comment tabs
+const maxProfileListLimit = 100
+
+// GetProfile returns the public profile of the user in the {username} path
+// segment
+func GetProfile(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	profile, err := sqlite.GetProfile(r.Context(), db, r.PathValue("username"))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch profile", err))
+		return
+	}
+	utils.SendJSONResponse(w, profile, http.StatusOK)
+}
+
+// GetProfilePosts lists the posts of the user in the {username} path
+// segment, newest first
+func GetProfilePosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	author, err := sqlite.GetUserByUsername(r.Context(), db, r.PathValue("username"))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	opts := sqlite.PostListOptions{AuthorID: author.ID, Page: page, Limit: min(limit, maxProfileListLimit)}
+	posts, _, err := sqlite.GetPosts(r.Context(), db, opts)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch posts", err))
+		return
+	}
+
+	if err := embedPostReactions(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+	if err := embedSavedFlags(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to check saved posts", err))
+		return
+	}
+	utils.SendJSONResponse(w, posts, http.StatusOK)
+}
+
+// GetProfileComments lists the comments and replies of the user in the
+// {username} path segment, newest first
+func GetProfileComments(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	author, err := sqlite.GetUserByUsername(r.Context(), db, r.PathValue("username"))
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	comments, err := sqlite.GetUserComments(r.Context(), db, author.ID, page, min(limit, maxProfileListLimit))
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch comments", err))
+		return
+	}
+	utils.SendJSONResponse(w, comments, http.StatusOK)
+}
+
+// UpdateMe changes the username, email and bio of the logged in user, only
+// those present in the body. Changing the email is confirmed with the
+// current password, so a stolen session cannot take over the account's
+// login address. No message is sent to either address.
+func UpdateMe(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Username        *string `json:"username"`
+		Email           *string `json:"email"`
+		Bio             *string `json:"bio"`
+		CurrentPassword string  `json:"current_password"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+
+	user, err := sqlite.GetUserByID(r.Context(), db, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+
+	// Only the fields being set are validated, so a stored value that
+	// predates a rule does not block changing another field
+	errs := validation.Errors{}
+	emailChanged := false
+	if request.Username != nil {
+		user.Username = strings.TrimSpace(*request.Username)
+		errs.Var("username", user.Username, "required,username")
+	}
+	if request.Email != nil {
+		email := strings.TrimSpace(*request.Email)
+		emailChanged = !strings.EqualFold(email, user.Email)
+		user.Email = email
+		errs.Var("email", user.Email, "required,email")
+	}
+	if request.Bio != nil {
+		user.Bio = strings.TrimSpace(*request.Bio)
+		errs.Var("bio", user.Bio, "max=500")
+	}
+	if request.Username == nil && request.Email == nil && request.Bio == nil {
+		errs.Add("username", "or email or bio is required")
+	}
+	if emailChanged && request.CurrentPassword == "" {
+		errs.Add("current_password", "is required to change email")
+	}
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	if emailChanged && !utils.CheckPasswordHash(request.CurrentPassword, user.PasswordHash) {
+		utils.SendError(w, r, apierror.Forbidden("Current password is incorrect"))
+		return
+	}
+
+	if err := sqlite.UpdateUser(r.Context(), db, *user); err != nil {
+		if sqlite.IsUniqueConstraintError(err) {
+			utils.SendError(w, r, apierror.Conflict("Username or email already exists"))
+		} else {
+			utils.SendError(w, r, apierror.Internal("Failed to update user", err))
+		}
+		return
+	}
+
+	user, err = sqlite.GetUserByID(r.Context(), db, userID)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch user", err))
+		return
+	}
+	utils.SendJSONResponse(w, user, http.StatusOK)
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"forum/apierror"
+	"forum/models"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/validation"
+)
+
+// maxSavedListLimit caps the page size of the saved posts listing
+const maxSavedListLimit = 100
+
+// SavePost bookmarks the post in the {id} path segment for the logged in
+// user, filed under the optional collection in the body. Saving an already
+// saved post moves it to that collection.
+func SavePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	postID, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	var request struct {
+		Collection string `json:"collection" validate:"max=50"`
+	}
+	if err := decodeOptionalJSON(r, &request); err != nil {
+		utils.SendError(w, r, apierror.BadRequest("Invalid request data"))
+		return
+	}
+	if err := validation.Struct(&request); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	err = sqlite.SavePost(r.Context(), db, userID, postID, request.Collection)
+	if errors.Is(err, sql.ErrNoRows) {
+		utils.SendError(w, r, apierror.NotFound("Post not found"))
+		return
+	}
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to save post", err))
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Post saved"}, http.StatusOK)
+}
+
+// UnsavePost removes the post in the {id} path segment from the logged in
+// user's saved posts
+func UnsavePost(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	postID, err := pathID(r)
+	if err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	if err := sqlite.UnsavePost(r.Context(), db, userID, postID); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to unsave post", err))
+		return
+	}
+	utils.SendJSONResponse(w, map[string]string{"message": "Post unsaved"}, http.StatusOK)
+}
+
+// GetSavedPosts lists the logged in user's saved posts, most recently saved
+// first, optionally narrowed to one collection
+func GetSavedPosts(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	collection := r.URL.Query().Get("collection")
+	errs := validation.Errors{}
+	errs.Var("collection", collection, "max=50")
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	limit = min(limit, maxSavedListLimit)
+
+	saved, err := sqlite.GetSavedPosts(r.Context(), db, userID, collection, page, limit)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch saved posts", err))
+		return
+	}
+
+	posts := make([]models.Post, len(saved))
+	for i, s := range saved {
+		posts[i] = s.Post
+	}
+	if err := embedPostReactions(db, r, posts); err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to count reactions", err))
+		return
+	}
+	for i := range saved {
+		saved[i].Post = posts[i]
+	}
+	utils.SendJSONResponse(w, saved, http.StatusOK)
+}
+
+// GetSavedCollections lists the logged in user's saved post collections
+func GetSavedCollections(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	userID, ok := RequireAuth(db, w, r)
+	if !ok {
+		return
+	}
+
+	collections, err := sqlite.GetSavedCollections(r.Context(), db, userID)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch collections", err))
+		return
+	}
+	utils.SendJSONResponse(w, collections, http.StatusOK)
+}
+
+// embedSavedFlags sets IsSaved on each post the viewer saved. Anonymous
+// requests leave every flag false.
+func embedSavedFlags(db *sql.DB, r *http.Request, posts []models.Post) error {
+	userID := viewerID(db, r)
+	if userID == "" || len(posts) == 0 {
+		return nil
+	}
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.ID
+	}
+	saved, err := sqlite.GetSavedPostIDs(r.Context(), db, userID, ids)
+	if err != nil {
+		return err
+	}
+	for i := range posts {
+		posts[i].IsSaved = saved[posts[i].ID]
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+
+	"forum/apierror"
+	"forum/sqlite"
+	"forum/utils"
+	"forum/validation"
+)
+
+// maxTrendingLimit caps the page size of the trending endpoints
+const maxTrendingLimit = 100
+
+// GetTrending returns posts created within the window, hottest first
+func GetTrending(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	errs := validation.Errors{}
+	since := windowStart(errs, r)
+	if err := errs.Err(); err != nil {
+		utils.SendError(w, r, err)
+		return
+	}
+
+	page, limit := utils.GetPaginationParams(r)
+	limit = min(limit, maxTrendingLimit)
+
+	trends, err := sqlite.GetTrendingPosts(r.Context(), db, since, page, limit)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch trending posts", err))
+		return
+	}
+	utils.SendJSONResponse(w, trends, http.StatusOK)
+}
+
+// GetTrendingCategories returns the categories whose posts are hottest
+func GetTrendingCategories(db *sql.DB, w http.ResponseWriter, r *http.Request) {
+	_, limit := utils.GetPaginationParams(r)
+	limit = min(limit, maxTrendingLimit)
+
+	categories, err := sqlite.GetTrendingCategories(r.Context(), db, limit)
+	if err != nil {
+		utils.SendError(w, r, apierror.Internal("Failed to fetch trending categories", err))
+		return
+	}
+	utils.SendJSONResponse(w, categories, http.StatusOK)
+}
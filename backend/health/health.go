@@ -0,0 +1,75 @@
+package health
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"forum/sqlite"
+)
+
+// Checker reports whether the server can usefully receive traffic
+type Checker struct {
+	db        *sql.DB
+	uploadDir string
+	draining  atomic.Bool
+}
+
+// NewChecker creates a readiness checker for db and the upload directory
+func NewChecker(db *sql.DB, uploadDir string) *Checker {
+	return &Checker{db: db, uploadDir: uploadDir}
+}
+
+// SetDraining makes every later readiness check fail. It is called when
+// graceful shutdown begins so load balancers stop sending new requests.
+func (c *Checker) SetDraining() {
+	c.draining.Store(true)
+}
+
+// Check runs every readiness check and returns each result keyed by name.
+// ok is false if any check failed.
+func (c *Checker) Check(ctx context.Context) (results map[string]string, ok bool) {
+	results = make(map[string]string)
+	ok = true
+	record := func(name string, err error) {
+		if err != nil {
+			results[name] = err.Error()
+			ok = false
+			return
+		}
+		results[name] = "ok"
+	}
+
+	if c.draining.Load() {
+		record("shutdown", fmt.Errorf("server is shutting down"))
+	} else {
+		record("shutdown", nil)
+	}
+	record("database", c.db.PingContext(ctx))
+	record("schema", c.checkSchema(ctx))
+	record("uploads", c.checkUploadDir())
+	return results, ok
+}
+
+func (c *Checker) checkSchema(ctx context.Context) error {
+	v, err := sqlite.GetSchemaVersion(ctx, c.db)
+	if err != nil {
+		return err
+	}
+	if v != sqlite.SchemaVersion {
+		return fmt.Errorf("schema version %d, want %d", v, sqlite.SchemaVersion)
+	}
+	return nil
+}
+
+func (c *Checker) checkUploadDir() error {
+	f, err := os.CreateTemp(c.uploadDir, ".readyz-*")
+	if err != nil {
+		return fmt.Errorf("upload directory is not writable: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
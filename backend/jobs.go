@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"forum/avatar"
+	"forum/config"
+	"forum/export"
+	"forum/scheduler"
+	"forum/sqlite"
+)
+
+// uploadGCGrace protects files written by requests that have not yet stored their URL
+const uploadGCGrace = time.Hour
+
+// registerJobs adds the built-in maintenance jobs to the scheduler
+func registerJobs(s *scheduler.Scheduler, db *sql.DB, cfg *config.Config) error {
+	jobs := []scheduler.Job{
+		{
+			Name: "session_purge",
+			Spec: cfg.Jobs.SessionPurge,
+			Run: func(ctx context.Context) error {
+				return sqlite.CleanupSessions(ctx, db, cfg.Session.Lifetime.Duration)
+			},
+		},
+		{
+			Name: "upload_gc",
+			Spec: cfg.Jobs.UploadGC,
+			Run: func(ctx context.Context) error {
+				return collectOrphanedUploads(ctx, db, cfg.Uploads.Dir)
+			},
+		},
+		{
+			Name: "trending_recompute",
+			Spec: cfg.Jobs.TrendingRecompute,
+			Run: func(ctx context.Context) error {
+				_, err := sqlite.RecomputeTrendingScores(ctx, db, time.Now())
+				return err
+			},
+		},
+		{
+			Name: "db_optimize",
+			Spec: cfg.Jobs.DBOptimize,
+			Run: func(ctx context.Context) error {
+				return sqlite.Optimize(ctx, db)
+			},
+		},
+		{
+			Name: "account_deletion",
+			Spec: cfg.Jobs.AccountDeletion,
+			Run: func(ctx context.Context) error {
+				n, err := sqlite.DeleteDueAccounts(ctx, db, time.Now().Add(-cfg.Accounts.DeletionGrace.Duration))
+				if err != nil {
+					return err
+				}
+				if n > 0 {
+					slog.Info("deleted accounts", "count", n)
+				}
+				return nil
+			},
+		},
+		{
+			Name: "data_export",
+			Spec: cfg.Jobs.DataExport,
+			Run: func(ctx context.Context) error {
+				return buildDataExports(ctx, db, cfg)
+			},
+		},
+	}
+
+	for _, job := range jobs {
+		job.Jitter = cfg.Jobs.Jitter.Duration
+		if err := s.Add(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collectOrphanedUploads deletes uploaded avatars and post images that no
+// user or post references any more
+func collectOrphanedUploads(ctx context.Context, db *sql.DB, uploadDir string) error {
+	referenced, err := sqlite.GetUploadURLs(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	candidates := []struct{ dir, prefix string }{
+		{uploadDir, "avatar_"},
+		{filepath.Join(uploadDir, "avatars"), "avatar_"},
+		{filepath.Join(uploadDir, "pictures"), "post_"},
+	}
+
+	removed := 0
+	for _, c := range candidates {
+		entries, err := os.ReadDir(c.dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if entry.IsDir() || !strings.HasPrefix(entry.Name(), c.prefix) {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || time.Since(info.ModTime()) < uploadGCGrace {
+				continue
+			}
+
+			path := filepath.Join(c.dir, entry.Name())
+			rel, err := filepath.Rel(uploadDir, path)
+			if err != nil {
+				continue
+			}
+			// Every size of an avatar is kept while its avatar_url is referenced
+			if referenced[avatar.DisplayURL("/static/"+filepath.ToSlash(rel))] {
+				continue
+			}
+
+			if err := os.Remove(path); err != nil {
+				slog.Error("failed to remove orphaned upload", "path", path, "err", err)
+				continue
+			}
+			removed++
+		}
+	}
+
+	if removed > 0 {
+		slog.Info("removed orphaned uploads", "count", removed)
+	}
+	return nil
+}
+
+// buildDataExports builds the archives of pending data exports, then
+// deletes exports whose download links have expired
+func buildDataExports(ctx context.Context, db *sql.DB, cfg *config.Config) error {
+	pending, err := sqlite.GetPendingDataExports(ctx, db)
+	if err != nil {
+		return err
+	}
+
+	opts := export.Options{UploadDir: cfg.Uploads.Dir, SessionLifetime: cfg.Session.Lifetime.Duration}
+	built := 0
+	for _, e := range pending {
+		status := "ready"
+		if err := export.WriteFile(ctx, db, e.UserID, export.ArchivePath(cfg.Exports.Dir, e.ID), opts); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			slog.Error("failed to build data export", "id", e.ID, "err", err)
+			status = "failed"
+		}
+		if err := sqlite.FinishDataExport(ctx, db, e.ID, status, time.Now().Add(cfg.Exports.LinkLifetime.Duration)); err != nil {
+			return err
+		}
+		if status == "ready" {
+			built++
+		}
+	}
+
+	expired, err := sqlite.DeleteExpiredDataExports(ctx, db, time.Now())
+	if err != nil {
+		return err
+	}
+
+	// Sweeping by age also catches the archives of deleted accounts and
+	// temporary files left by interrupted builds
+	entries, err := os.ReadDir(cfg.Exports.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	removed := 0
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || entry.IsDir() || time.Since(info.ModTime()) < cfg.Exports.LinkLifetime.Duration {
+			continue
+		}
+		path := filepath.Join(cfg.Exports.Dir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			slog.Error("failed to remove expired data export", "path", path, "err", err)
+			continue
+		}
+		removed++
+	}
+
+	// The job runs every minute, so idle runs stay quiet
+	if built > 0 || expired > 0 || removed > 0 {
+		slog.Info("built data exports", "count", built, "expired", expired, "removed_files", removed)
+	}
+	return nil
+}
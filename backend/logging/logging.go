@@ -0,0 +1,115 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+type contextKey string
+
+const (
+	loggerKey  contextKey = "logger"
+	requestKey contextKey = "request"
+)
+
+// Setup installs the process-wide slog logger. format is "text" or "json".
+func Setup(w io.Writer, format, level string) error {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	case "json":
+		handler = slog.NewJSONHandler(w, opts)
+	default:
+		return fmt.Errorf("invalid log format %q (use text or json)", format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// RequestInfo holds per-request details filled in while the request is handled
+type RequestInfo struct {
+	ID      string
+	UserID  string
+	TraceID string
+}
+
+// NewRequestContext attaches a logger tagged with the request ID to ctx
+func NewRequestContext(ctx context.Context, requestID string) (context.Context, *RequestInfo) {
+	info := &RequestInfo{ID: requestID}
+	ctx = context.WithValue(ctx, requestKey, info)
+	ctx = context.WithValue(ctx, loggerKey, slog.Default().With("request_id", requestID))
+	return ctx, info
+}
+
+// Request returns the request details stored in ctx, if any
+func Request(ctx context.Context) *RequestInfo {
+	info, _ := ctx.Value(requestKey).(*RequestInfo)
+	return info
+}
+
+// RequestID returns the ID of the request handled under ctx, or ""
+func RequestID(ctx context.Context) string {
+	if info := Request(ctx); info != nil {
+		return info.ID
+	}
+	return ""
+}
+
+// SetUserID records the authenticated user for the request handled under ctx
+func SetUserID(ctx context.Context, userID string) {
+	if info := Request(ctx); info != nil {
+		info.UserID = userID
+	}
+}
+
+// SetTraceID records the trace the request handled under ctx belongs to
+func SetTraceID(ctx context.Context, traceID string) {
+	if info := Request(ctx); info != nil {
+		info.TraceID = traceID
+	}
+}
+
+// FromContext returns the request-scoped logger, or the default logger
+// outside of a request
+func FromContext(ctx context.Context) *slog.Logger {
+	logger, ok := ctx.Value(loggerKey).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	if info := Request(ctx); info != nil {
+		if info.TraceID != "" {
+			logger = logger.With("trace_id", info.TraceID)
+		}
+		if info.UserID != "" {
+			logger = logger.With("user_id", info.UserID)
+		}
+	}
+	return logger
+}
+
+// secretRoutes are the route patterns whose paths carry a secret, such as
+// the ID of a user's data export
+var secretRoutes = map[string]bool{
+	"GET /api/v1/exports/{id}": true,
+}
+
+// Path returns the path of r to log, or the path part of its route pattern
+// when the path carries a secret
+func Path(r *http.Request) string {
+	if secretRoutes[r.Pattern] {
+		_, path, _ := strings.Cut(r.Pattern, " ")
+		return path
+	}
+	return r.URL.Path
+}
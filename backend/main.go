@@ -1,73 +1,257 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
-	"strconv"
+	"os/signal"
+	"sync"
+	"syscall"
 	"time"
 
+	"forum/certs"
+	"forum/config"
+	"forum/health"
+	"forum/logging"
+	"forum/metrics"
 	"forum/middleware"
 	"forum/routes"
+	"forum/scheduler"
 	"forum/sqlite"
+	"forum/tracing"
 )
 
 func main() {
-	// Validate CLI args
-	if len(os.Args) > 2 {
-		fmt.Println("Usage:\n\n$ go run .\n\nor\n\n$ go run . 'port no'\n\nwhere port no; is a four digit integer greater than 1023 and not equal to 3306/3389")
-		return
-	}
-	port := ":8080"
-	if len(os.Args) == 2 {
-		p, er := strconv.Atoi(os.Args[1])
-		if er != nil || !(p > 1023 && p < 65536 && p != 3306 && p != 3389) {
-			fmt.Println("Usage:\n\n$ go run .\n\nor\n\n$ go run . 'port no'\n\nwhere port no; is a four digit integer greater than 1023 and not equal to 3306/3389")
-			return
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
 		}
-		port = ":" + os.Args[1]
+		slog.Error("forum exited", "err", err)
+		os.Exit(1)
+	}
+}
+
+func serve(args []string) error {
+	cfg, err := config.Load("serve", args, os.Stderr)
+	if err != nil {
+		return err
+	}
+	config.Set(cfg)
+	if err := logging.Setup(os.Stderr, cfg.Log.Format, cfg.Log.Level); err != nil {
+		return err
 	}
 
-	// Initialize the database
-	err := sqlite.InitializeDatabase("forum.db")
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Options{
+		Exporter:    cfg.Tracing.Exporter,
+		Endpoint:    cfg.Tracing.Endpoint,
+		SampleRatio: cfg.Tracing.SampleRatio,
+		ServiceName: cfg.Tracing.ServiceName,
+		Stdout:      os.Stdout,
+	})
 	if err != nil {
-		log.Fatalf("Failed to initialize database: %v", err)
+		return err
+	}
+	// Flush spans after everything else has stopped
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Error("failed to flush traces", "err", err)
+		}
+	}()
+
+	// Initialize the database. It is closed last, after the server has
+	// drained and background jobs have stopped.
+	err = sqlite.InitializeDatabase(cfg.Database.Path, cfg.Database.Schema)
+	if err != nil {
+		return fmt.Errorf("failed to initialize database: %w", err)
 	}
 	defer sqlite.CloseDatabase()
 
+	// Offer the configured reaction types
+	if err := sqlite.SyncReactionTypes(context.Background(), sqlite.DB, cfg.Reactions.Parsed()); err != nil {
+		return fmt.Errorf("failed to sync reaction types: %w", err)
+	}
+
+	// Score posts now so /trending is ranked before the first scheduled recompute
+	if n, err := sqlite.RecomputeTrendingScores(context.Background(), sqlite.DB, time.Now()); err != nil {
+		slog.Error("failed to compute trending scores", "err", err)
+	} else {
+		slog.Debug("computed trending scores", "posts", n)
+	}
+
+	// Cancelled on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Register background jobs
+	jobs := scheduler.New(sqlite.DB)
+	if err := registerJobs(jobs, sqlite.DB, cfg); err != nil {
+		return err
+	}
+
 	// Set up routes and CORS
-	mux := routes.SetupRoutes(sqlite.DB)
+	checker := health.NewChecker(sqlite.DB, cfg.Uploads.Dir)
+	mux := routes.SetupRoutes(sqlite.DB, jobs, checker)
 	handler := middleware.CORS(mux)
+	if cfg.TLS.Enabled() && cfg.TLS.HSTSMaxAge.Duration > 0 {
+		handler = middleware.HSTS(cfg.TLS.HSTSMaxAge.Duration, cfg.TLS.HSTSIncludeSubdomains, handler)
+	}
+	handler = middleware.RequestID(middleware.AccessLog(middleware.Metrics(middleware.Tracing(handler))))
 
-	// Start daily session cleanup in background
-	go scheduleDailyCleanup()
+	// Handlers still running when shutdown gives up on draining are waited
+	// for, so the database is not closed under them
+	var inFlight sync.WaitGroup
+	srv := newServer(cfg, cfg.Addr(), countInFlight(&inFlight, handler))
+	servers := []*http.Server{srv}
+
+	// Run background jobs until shutdown
+	var background sync.WaitGroup
+	background.Add(1)
+	go func() {
+		defer background.Done()
+		jobs.Start(ctx)
+	}()
+	defer background.Wait()
 
 	// Start server
-	fmt.Printf("🚀 [%s] Server is running at http://localhost%s\n", time.Now().Format(time.RFC3339), port)
-	log.Fatal(http.ListenAndServe(port, handler))
-}
+	serveErr := make(chan error, 3)
+	if cfg.Metrics.Enabled {
+		metrics.RegisterDB(sqlite.DB)
+		if cfg.Metrics.Addr != "" {
+			metricsMux := http.NewServeMux()
+			metricsMux.Handle("/metrics", metrics.Handler())
+			metricsSrv := newServer(cfg, cfg.Metrics.Addr, metricsMux)
+			servers = append(servers, metricsSrv)
+			go func() {
+				serveErr <- metricsSrv.ListenAndServe()
+			}()
+			slog.Info("serving metrics", "addr", cfg.Metrics.Addr)
+		}
+	}
+	if cfg.TLS.Enabled() {
+		reloader, err := certs.NewReloader(cfg.TLS.CertFile, cfg.TLS.KeyFile)
+		if err != nil {
+			return err
+		}
+		srv.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+		}
+		go reloadCertificateOnHangup(ctx, reloader)
 
-// scheduleDailyCleanup runs session cleanup at midnight every day
-func scheduleDailyCleanup() {
-	for {
-		now := time.Now()
-		nextMidnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
-		sleepDuration := time.Until(nextMidnight)
-
-		fmt.Println("🕛 Session cleanup scheduled for midnight...")
-		for remaining := sleepDuration; remaining > 0; remaining -= time.Minute {
-			hours := int(remaining.Hours())
-			minutes := int(remaining.Minutes()) % 60
-			fmt.Printf("\r⏳ Time until cleanup: %02d h %02d min", hours, minutes)
-			time.Sleep(1 * time.Minute)
+		go func() {
+			serveErr <- srv.ListenAndServeTLS("", "")
+		}()
+
+		if cfg.TLS.RedirectPort != 0 {
+			redirect := newServer(cfg, cfg.RedirectAddr(), middleware.RedirectToHTTPS(cfg.Server.Port))
+			servers = append(servers, redirect)
+			go func() {
+				serveErr <- redirect.ListenAndServe()
+			}()
+			slog.Info("redirecting HTTP to HTTPS", "addr", cfg.RedirectAddr())
 		}
+		slog.Info("server is running", "url", fmt.Sprintf("https://localhost:%d", cfg.Server.Port))
+	} else {
+		go func() {
+			serveErr <- srv.ListenAndServe()
+		}()
+		slog.Info("server is running", "url", fmt.Sprintf("http://localhost:%d", cfg.Server.Port))
+	}
+
+	select {
+	case err := <-serveErr:
+		stop()
+		return err
+	case <-ctx.Done():
+	}
+
+	// Restore default signal handling so a second signal exits immediately
+	stop()
+
+	// Fail readiness first so load balancers stop sending new requests
+	checker.SetDraining()
+	if delay := cfg.Server.ShutdownDelay.Duration; delay > 0 {
+		slog.Info("readiness failing, waiting before draining", "delay", delay)
+		time.Sleep(delay)
+	}
+	slog.Info("shutting down, draining in-flight requests", "timeout", cfg.Server.ShutdownTimeout.Duration)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout.Duration)
+	defer cancel()
+	err = shutdownAll(shutdownCtx, servers)
+	inFlight.Wait()
+	if err != nil {
+		return fmt.Errorf("graceful shutdown failed: %w", err)
+	}
+	slog.Info("server stopped")
+	return nil
+}
 
-		fmt.Println("\n🚀 Running session cleanup...")
-		if err := sqlite.CleanupSessions(sqlite.DB, 24); err != nil {
-			fmt.Printf("❌ [%s] Session cleanup failed: %v\n", time.Now().Format(time.RFC3339), err)
-		} else {
-			fmt.Println("✅ Expired sessions cleaned up successfully at midnight.")
+// shutdownAll drains every server at once, so one that fails or runs out
+// of time does not leave the others serving. A server that does not drain
+// is closed, which drops its remaining connections and cancels their
+// requests' contexts.
+func shutdownAll(ctx context.Context, servers []*http.Server) error {
+	errs := make([]error, len(servers))
+	var wg sync.WaitGroup
+	for i, server := range servers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := server.Shutdown(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", server.Addr, err)
+				server.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// countInFlight adds every request to wg until next has handled it
+func countInFlight(wg *sync.WaitGroup, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wg.Add(1)
+		defer wg.Done()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// newServer builds an http.Server with the configured timeouts
+func newServer(cfg *config.Config, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadTimeout:       cfg.Server.ReadTimeout.Duration,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout.Duration,
+		WriteTimeout:      cfg.Server.WriteTimeout.Duration,
+		IdleTimeout:       cfg.Server.IdleTimeout.Duration,
+	}
+}
+
+// reloadCertificateOnHangup re-reads the TLS certificate whenever the process receives SIGHUP
+func reloadCertificateOnHangup(ctx context.Context, reloader *certs.Reloader) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := reloader.Reload(); err != nil {
+				slog.Error("TLS certificate reload failed, keeping the previous certificate", "err", err)
+			} else {
+				slog.Info("TLS certificate reloaded")
+			}
 		}
 	}
 }
@@ -0,0 +1,97 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Registry holds every forum metric. It is separate from the global
+// Prometheus registry so that only what we register is exported.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_http_requests_total",
+		Help: "HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forum_http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	QueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "forum_sqlite_query_duration_seconds",
+		Help:    "Duration of sqlite package functions.",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"function"})
+
+	Registrations = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "forum_registrations_total",
+		Help: "Successful user registrations.",
+	})
+
+	Logins = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_logins_total",
+		Help: "Login attempts by result (success or failure).",
+	}, []string{"result"})
+
+	Posts = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "forum_posts_created_total",
+		Help: "Posts created.",
+	})
+
+	Comments = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_comments_created_total",
+		Help: "Comments created, by kind (comment or reply).",
+	}, []string{"kind"})
+
+	Reactions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_reactions_total",
+		Help: "Reaction toggles by reaction type.",
+	}, []string{"type"})
+
+	UploadBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "forum_upload_bytes_total",
+		Help: "Bytes of uploaded files stored, by kind (avatar or post_image).",
+	}, []string{"kind"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		QueryDuration,
+		Registrations,
+		Logins,
+		Posts,
+		Comments,
+		Reactions,
+		UploadBytes,
+	)
+
+	// Export known label values as zero from the start
+	for _, result := range []string{"success", "failure"} {
+		Logins.WithLabelValues(result)
+	}
+	for _, kind := range []string{"comment", "reply"} {
+		Comments.WithLabelValues(kind)
+	}
+}
+
+// RegisterDB exports connection pool statistics for db
+func RegisterDB(db *sql.DB) {
+	Registry.MustRegister(collectors.NewDBStatsCollector(db, "forum"))
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,31 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/utils"
+)
+
+// AdminMiddleware requires the configured admin bearer token.
+// The admin API answers 404 when no token is configured.
+func AdminMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := config.Current().Admin.Token
+		if token == "" {
+			utils.SendError(w, r, apierror.NotFound("Not found"))
+			return
+		}
+
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			utils.SendError(w, r, apierror.Unauthorized("Missing or invalid admin token"))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"net/http"
 
+	"forum/apierror"
 	"forum/utils"
 )
 
@@ -17,7 +18,7 @@ func AuthMiddleware(db *sql.DB, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		userID, err := utils.GetUserIDFromSession(db, r)
 		if err != nil || userID == "" {
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			utils.SendError(w, r, apierror.Unauthorized("Not logged in"))
 			return
 		}
 
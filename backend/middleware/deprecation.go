@@ -0,0 +1,17 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Deprecated marks every response from next with a Deprecation header
+// (RFC 9745) carrying the date the route was deprecated
+func Deprecated(since time.Time, next http.Handler) http.Handler {
+	value := fmt.Sprintf("@%d", since.Unix())
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", value)
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"forum/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("forum/http")
+
+// Tracing starts a server span per request, continuing the caller's trace
+// from a traceparent header. The span is named after the route pattern the
+// ServeMux matched. Tracing has to pass a new request down, so it copies the
+// pattern back onto the request it received for AccessLog and Metrics.
+func Tracing(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.ClientAddress(r.RemoteAddr),
+				semconv.UserAgentOriginal(r.UserAgent()),
+			),
+		)
+		defer span.End()
+		if sc := span.SpanContext(); sc.IsValid() {
+			logging.SetTraceID(ctx, sc.TraceID().String())
+		}
+
+		rec := &statusRecorder{ResponseWriter: w}
+		traced := r.WithContext(ctx)
+		next.ServeHTTP(rec, traced)
+		r.Pattern = traced.Pattern
+
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		// The path is only known to be safe to record once the route is
+		span.SetAttributes(semconv.URLPath(logging.Path(traced)), semconv.HTTPResponseStatusCode(status))
+		if route := traced.Pattern; route != "" {
+			// Patterns registered without a method still get one in the span name
+			name := route
+			if !strings.Contains(route, " ") {
+				name = r.Method + " " + route
+			}
+			span.SetName(name)
+			span.SetAttributes(semconv.HTTPRoute(route))
+		}
+		if status >= 500 {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	})
+}
@@ -2,5 +2,5 @@ package models
 
 type Category struct {
 	ID   int    `json:"id" gorm:"primaryKey"`
-	Name string `json:"name" validate:"required" gorm:"unique;not null"`
+	Name string `json:"name" validate:"required,max=50" gorm:"unique;not null"`
 }
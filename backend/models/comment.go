@@ -3,24 +3,27 @@ package models
 import "time"
 
 type Comment struct {
-	ID            int            `json:"id" gorm:"primaryKey"`
-	UserID        string         `json:"user_id" validate:"required" gorm:"not null"`
-	UserName      string         `json:"username"`
-	ProfileAvatar string         `json:"avatar_url"`
-	PostID        int            `json:"post_id,omitempty"`
-	Content       string         `json:"content" validate:"required" gorm:"not null"`
-	CreatedAt     time.Time      `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
-	Replies       []ReplyComment `json:"replies,omitempty" gorm:"-"`
+	ID            int             `json:"id" gorm:"primaryKey"`
+	UserID        string          `json:"user_id" validate:"required" gorm:"not null"`
+	UserName      string          `json:"username"`
+	ProfileAvatar string          `json:"avatar_url"`
+	PostID        int             `json:"post_id,omitempty"`
+	Content       string          `json:"content" validate:"required,max=2000" gorm:"not null"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	ReplyCount    int             `json:"reply_count"`
+	Replies       []ReplyComment  `json:"replies,omitempty" gorm:"-"`
+	Reactions     *ReactionCounts `json:"reactions,omitempty" gorm:"-"`
 }
 
 type ReplyComment struct {
-	ID              int       `json:"id" gorm:"primaryKey"`
-	UserID          string    `json:"user_id" validate:"required" gorm:"not null"`
-	UserName        string    `json:"username"`
-	ProfileAvatar   string    `json:"avatar_url"`
-	ParentCommentID int       `json:"parent_comment_id,omitempty"`
-	Content         string    `json:"content" validate:"required" gorm:"not null"`
-	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID              int             `json:"id" gorm:"primaryKey"`
+	UserID          string          `json:"user_id" validate:"required" gorm:"not null"`
+	UserName        string          `json:"username"`
+	ProfileAvatar   string          `json:"avatar_url"`
+	ParentCommentID int             `json:"parent_comment_id,omitempty"`
+	Content         string          `json:"content" validate:"required,max=2000" gorm:"not null"`
+	CreatedAt       time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	Reactions       *ReactionCounts `json:"reactions,omitempty" gorm:"-"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// DataExport is a user's request for an archive of their personal data
+type DataExport struct {
+	ID          string     `json:"-"` // The secret in the download link
+	UserID      string     `json:"-"`
+	Status      string     `json:"status"` // "pending", "ready" or "failed"
+	RequestedAt time.Time  `json:"requested_at"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // When the archive and its link are deleted
+	DownloadURL string     `json:"download_url,omitempty"`
+}
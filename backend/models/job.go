@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// JobRun is one recorded execution of a scheduled background job
+type JobRun struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Status     string     `json:"status"` // running, succeeded, failed or skipped
+	Error      string     `json:"error,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
@@ -1,8 +1,60 @@
 package models
 
+import "time"
+
 type Like struct {
-	UserID    string `json:"user_id" validate:"required"`
-	PostID    *int   `json:"post_id,omitempty"`
-	CommentID *int   `json:"comment_id,omitempty"`
-	Type      string `json:"type" validate:"required,oneof=like dislike"` // must be "like" or "dislike"
+	UserID     string `json:"user_id" validate:"required"`
+	TargetType string `json:"target_type"` // "post", "comment" or "reply"
+	PostID     *int   `json:"post_id,omitempty"`
+	CommentID  *int   `json:"comment_id,omitempty"`
+	ReplyID    *int   `json:"reply_id,omitempty"`
+	Type       string `json:"type" validate:"required,max=32"` // a name from reaction_types
+}
+
+// ReactionType is a reaction users can give, such as like or thanks
+type ReactionType struct {
+	Name  string `json:"name"`
+	Emoji string `json:"emoji"`
+}
+
+// ReactionCounts summarises the reactions on one post, comment or reply.
+// Likes and Dislikes repeat the like and dislike entries of Counts, which
+// covers every enabled reaction type.
+type ReactionCounts struct {
+	Likes    int `json:"likes"`
+	Dislikes int `json:"dislikes"`
+	// Counts has the number of each enabled reaction type, including like and dislike
+	Counts map[string]int `json:"counts,omitempty"`
+	// ViewerReaction is the logged in user's own vote, "like" or "dislike", if any
+	ViewerReaction string `json:"viewer_reaction,omitempty"`
+	// ViewerReactions are all of the logged in user's own reactions
+	ViewerReactions []string `json:"viewer_reactions,omitempty"`
+}
+
+// ReactionBatch holds reaction counts keyed by post, comment and reply ID
+type ReactionBatch struct {
+	Posts    map[int]ReactionCounts `json:"posts"`
+	Comments map[int]ReactionCounts `json:"comments"`
+	Replies  map[int]ReactionCounts `json:"replies"`
+}
+
+// Reactor is a user who gave a reaction, for "who reacted" listings
+type Reactor struct {
+	UserID    string    `json:"user_id"`
+	Username  string    `json:"username"`
+	AvatarURL string    `json:"avatar_url"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UserReaction is one entry in a user's reaction history, with enough of
+// its target to link to it
+type UserReaction struct {
+	TargetType string    `json:"target_type"` // "post", "comment" or "reply"
+	TargetID   int       `json:"target_id"`
+	Type       string    `json:"type"`
+	PostID     int       `json:"post_id"`
+	PostTitle  string    `json:"post_title"`
+	Content    string    `json:"content"` // of the post, comment or reply
+	CreatedAt  time.Time `json:"created_at"`
 }
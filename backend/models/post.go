@@ -3,14 +3,29 @@ package models
 import "time"
 
 type Post struct {
-	ID            int       `json:"id" gorm:"primaryKey"`
-	ProfileAvatar string    `json:"avatar_url"`
-	Title         string    `json:"title" validate:"required" gorm:"not null"`
-	Content       string    `json:"content" validate:"required" gorm:"not null"`
-	Username      string    `json:"username" gorm:"-"`
-	UserID        string    `json:"user_id" gorm:"not null"`
-	CategoryIDs   []int     `json:"category_ids" gorm:"-"` // For multiple categories
-	ImageURL      *string   `json:"image_url,omitempty"`
-	CreatedAt     time.Time `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt     time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+	ID            int             `json:"id" gorm:"primaryKey"`
+	ProfileAvatar string          `json:"avatar_url"`
+	Title         string          `json:"title" validate:"required,max=200" gorm:"not null"`
+	Content       string          `json:"content" validate:"required,max=10000" gorm:"not null"`
+	Username      string          `json:"username" gorm:"-"`
+	UserID        string          `json:"user_id" gorm:"not null"`
+	CategoryIDs   []int           `json:"category_ids" gorm:"-"` // For multiple categories
+	ImageURL      *string         `json:"image_url,omitempty"`
+	CommentCount  int             `json:"comment_count"`
+	CreatedAt     time.Time       `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt     time.Time       `json:"updated_at" gorm:"autoUpdateTime"`
+	Reactions     *ReactionCounts `json:"reactions,omitempty" gorm:"-"`
+	IsSaved       bool            `json:"is_saved" gorm:"-"` // Whether the viewer saved the post
+}
+
+// PostRevision is an earlier version of an edited post
+type PostRevision struct {
+	PostID int `json:"post_id"`
+	// PostTitle is the current title of the post
+	PostTitle string `json:"post_title"`
+	Title     string `json:"title"`
+	Content   string `json:"content"`
+	// CreatedAt is when this version was saved, ReplacedAt when an edit replaced it
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
 }
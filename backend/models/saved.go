@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// SavedPost is a post the user bookmarked, with where and when they saved it
+type SavedPost struct {
+	Post       Post      `json:"post"`
+	Collection string    `json:"collection"` // "" when not filed in a collection
+	SavedAt    time.Time `json:"saved_at"`
+}
+
+// SavedCollection is one of a user's named collections of saved posts
+type SavedCollection struct {
+	Name      string `json:"name"`
+	PostCount int    `json:"post_count"`
+}
@@ -1,10 +1,29 @@
 package models
 
-// Post represents a forum post with relevant fields
+import "time"
+
+// Trend is a post ranked by its cached hot score
 type Trend struct {
-	ID           int    `json:"id"`
-	Title        string `json:"title"`
-	Content      string `json:"content"`
-	LikeCount    int    `json:"like_count"`    // Number of likes
-	CommentCount int    `json:"comment_count"` // Number of comments
+	ID            int       `json:"id"`
+	UserID        string    `json:"user_id"`
+	Username      string    `json:"username"`
+	ProfileAvatar string    `json:"avatar_url"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	ImageURL      *string   `json:"image_url,omitempty"`
+	CategoryIDs   []int     `json:"category_ids"`
+	LikeCount     int       `json:"like_count"`    // Number of likes
+	DislikeCount  int       `json:"dislike_count"` // Number of dislikes
+	CommentCount  int       `json:"comment_count"` // Number of comments
+	ReplyCount    int       `json:"reply_count"`   // Number of replies to its comments
+	Score         float64   `json:"score"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// CategoryTrend is a category ranked by the hot scores of its posts
+type CategoryTrend struct {
+	ID        int     `json:"id"`
+	Name      string  `json:"name"`
+	PostCount int     `json:"post_count"`
+	Score     float64 `json:"score"`
 }
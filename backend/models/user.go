@@ -4,10 +4,34 @@ import "time"
 
 type User struct {
 	ID           string    `json:"id" gorm:"primaryKey"`
-	Username     string    `json:"username" gorm:"unique;not null"`
-	Email        string    `json:"email" gorm:"unique;not null"`
+	Username     string    `json:"username" validate:"required,username" gorm:"unique;not null"`
+	Email        string    `json:"email" validate:"required,email" gorm:"unique;not null"`
 	PasswordHash string    `json:"-" gorm:"not null"`
 	AvatarURL    string    `json:"avatar_url" gorm:"default:'/static/default-avatar.png'"` // ✅ New field
+	Bio          string    `json:"bio" validate:"max=500"`
 	CreatedAt    time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt    time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
+
+// Profile is what anyone can see about a user. It leaves out the email
+// address and other account details.
+type Profile struct {
+	ID                string    `json:"id"`
+	Username          string    `json:"username"`
+	AvatarURL         string    `json:"avatar_url"`
+	Bio               string    `json:"bio"`
+	CreatedAt         time.Time `json:"created_at"`
+	PostCount         int       `json:"post_count"`
+	CommentCount      int       `json:"comment_count"`      // Comments and replies
+	ReactionsReceived int       `json:"reactions_received"` // Reactions on their posts, comments and replies
+}
+
+// UserComment is a comment or reply a user wrote, with the post it is under
+type UserComment struct {
+	ID        int       `json:"id"`
+	Type      string    `json:"type"` // "comment" or "reply"
+	PostID    int       `json:"post_id"`
+	PostTitle string    `json:"post_title"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,21 @@
+// Package openapi embeds the OpenAPI 3.1 document describing the HTTP API.
+package openapi
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// Spec is the OpenAPI document served at /api/openapi.json.
+// routes/openapi_test.go fails when it falls out of date with the routes or models.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// Handler serves Spec
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(Spec)
+	})
+}
@@ -0,0 +1,69 @@
+package routes
+
+import (
+	"net/http"
+
+	"forum/apierror"
+	"forum/handlers"
+	"forum/utils"
+)
+
+// Mux is an http.ServeMux that remembers every pattern registered on it,
+// so the OpenAPI test can check each route is documented, and that answers
+// unmatched requests with the JSON error envelope
+type Mux struct {
+	*http.ServeMux
+	patterns []string
+}
+
+// NewMux creates an empty Mux
+func NewMux() *Mux {
+	return &Mux{ServeMux: http.NewServeMux()}
+}
+
+// Handle registers handler for pattern
+func (m *Mux) Handle(pattern string, handler http.Handler) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.Handle(pattern, handler)
+}
+
+// HandleFunc registers handler for pattern
+func (m *Mux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.patterns = append(m.patterns, pattern)
+	m.ServeMux.HandleFunc(pattern, handler)
+}
+
+// Patterns returns the registered patterns in registration order
+func (m *Mux) Patterns() []string {
+	return append([]string(nil), m.patterns...)
+}
+
+// ServeHTTP dispatches r to the matching route. When nothing matches, the
+// ServeMux's own plain-text answer is replaced with a JSON 404, or a 405
+// with an Allow header if the path exists under other methods.
+func (m *Mux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, pattern := m.ServeMux.Handler(r)
+	if pattern != "" {
+		m.ServeMux.ServeHTTP(w, r)
+		return
+	}
+
+	rec := &discardRecorder{header: http.Header{}}
+	h.ServeHTTP(rec, r)
+	if rec.status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", rec.header.Get("Allow"))
+		utils.SendError(w, r, apierror.MethodNotAllowed())
+		return
+	}
+	handlers.NotFound(w, r)
+}
+
+// discardRecorder keeps the status and headers of a response and drops the body
+type discardRecorder struct {
+	header http.Header
+	status int
+}
+
+func (d *discardRecorder) Header() http.Header         { return d.header }
+func (d *discardRecorder) Write(b []byte) (int, error) { return len(b), nil }
+func (d *discardRecorder) WriteHeader(status int)      { d.status = status }
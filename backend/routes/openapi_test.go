@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"forum/apierror"
+	"forum/config"
+	"forum/health"
+	"forum/openapi"
+	"forum/scheduler"
+	"forum/version"
+)
+
+// undocumented lists paths that are not part of the JSON API
+var undocumented = []string{"/static/", "/api/docs/"}
+
+type spec struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Schemas map[string]struct {
+			Properties map[string]json.RawMessage `json:"properties"`
+		} `json:"schemas"`
+	} `json:"components"`
+}
+
+func loadSpec(t *testing.T) spec {
+	t.Helper()
+	var s spec
+	if err := json.Unmarshal(openapi.Spec, &s); err != nil {
+		t.Fatalf("openapi.json: %v", err)
+	}
+	return s
+}
+
+func TestOpenAPIDocumentsEveryRoute(t *testing.T) {
+	cfg := config.Default()
+	cfg.Metrics.Enabled = true
+	cfg.Metrics.Addr = ""
+	config.Set(cfg)
+	t.Cleanup(func() { config.Set(config.Default()) })
+
+	s := loadSpec(t)
+	mux := SetupRoutes(nil, scheduler.New(nil), health.NewChecker(nil, ""))
+
+	registered := make(map[string]bool)
+	for _, pattern := range mux.Patterns() {
+		method, path, ok := strings.Cut(pattern, " ")
+		if !ok {
+			method, path = "", pattern
+		}
+		if slices.Contains(undocumented, path) {
+			continue
+		}
+		registered[path] = true
+
+		ops, ok := s.Paths[path]
+		if !ok {
+			t.Errorf("route %q is missing from openapi.json", pattern)
+			continue
+		}
+		if method != "" {
+			if _, ok := ops[strings.ToLower(method)]; !ok {
+				t.Errorf("route %q: openapi.json has no %s operation for %s", pattern, method, path)
+			}
+		}
+	}
+
+	for path := range s.Paths {
+		if !registered[path] {
+			t.Errorf("openapi.json documents %s, which is not a registered route", path)
+		}
+	}
+}
+
+func TestOpenAPIDocumentsEveryModel(t *testing.T) {
+	s := loadSpec(t)
+
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, "../models", nil, 0)
+	if err != nil {
+		t.Fatalf("parse models: %v", err)
+	}
+	for _, pkg := range pkgs {
+		for _, file := range pkg.Files {
+			ast.Inspect(file, func(n ast.Node) bool {
+				ts, ok := n.(*ast.TypeSpec)
+				if !ok || !ts.Name.IsExported() {
+					return true
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok {
+					return true
+				}
+				var fields []string
+				for _, f := range st.Fields.List {
+					if f.Tag == nil {
+						continue
+					}
+					tag := reflect.StructTag(strings.Trim(f.Tag.Value, "`"))
+					if name := jsonName(tag.Get("json")); name != "" {
+						fields = append(fields, name)
+					}
+				}
+				checkSchema(t, s, ts.Name.Name, ts.Name.Name, fields)
+				return false
+			})
+		}
+	}
+}
+
+func TestOpenAPIDocumentsResponseTypes(t *testing.T) {
+	s := loadSpec(t)
+
+	// Response structs that live outside the models package
+	types := map[string]any{
+		"Error":     apierror.Response{},
+		"JobStatus": scheduler.Status{},
+		"Version":   version.Info{},
+	}
+	for schema, v := range types {
+		typ := reflect.TypeOf(v)
+		var fields []string
+		for i := range typ.NumField() {
+			if name := jsonName(typ.Field(i).Tag.Get("json")); name != "" {
+				fields = append(fields, name)
+			}
+		}
+		checkSchema(t, s, typ.String(), schema, fields)
+	}
+}
+
+func checkSchema(t *testing.T, s spec, goType, schema string, fields []string) {
+	t.Helper()
+	sc, ok := s.Components.Schemas[schema]
+	if !ok {
+		t.Errorf("%s has no %q schema in openapi.json", goType, schema)
+		return
+	}
+	for _, f := range fields {
+		if _, ok := sc.Properties[f]; !ok {
+			t.Errorf("schema %q is missing %s field %q", schema, goType, f)
+		}
+	}
+}
+
+// jsonName returns the JSON field name from a json struct tag, or ""
+// when the field is not serialised
+func jsonName(tag string) string {
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "-" {
+		return ""
+	}
+	return name
+}
@@ -2,11 +2,22 @@ package routes
 
 import (
 	"database/sql"
-	"log"
 	"net/http"
+	"time"
 
+	"forum/apierror"
+	"forum/config"
+	"forum/graph"
 	"forum/handlers"
+	"forum/health"
+	"forum/logging"
+	"forum/metrics"
 	"forum/middleware"
+	"forum/openapi"
+	"forum/scheduler"
+	"forum/utils"
+
+	"github.com/swaggest/swgui/v5emb"
 )
 
 // HandlerWrapper wraps handlers to include the database connection
@@ -16,44 +27,124 @@ func HandlerWrapper(db *sql.DB, handler func(*sql.DB, http.ResponseWriter, *http
 	}
 }
 
-func SetupRoutes(db *sql.DB) http.Handler {
-	mux := http.NewServeMux()
-	// Fetch user data
-	mux.Handle("/api/user", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetUser)))
-
-	// Authentication routes
-	mux.HandleFunc("/api/register", HandlerWrapper(db, handlers.RegisterUser))
-	mux.HandleFunc("/api/login", HandlerWrapper(db, handlers.LoginUser))
-	mux.HandleFunc("/api/logout", HandlerWrapper(db, handlers.LogoutUser))
-
-	// Post routes (protected by auth middleware)
-	mux.Handle("/api/posts/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreatePost)))
-	mux.HandleFunc("/api/posts", HandlerWrapper(db, handlers.GetPosts)) // Allow public access
-	mux.Handle("/api/posts/update", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdatePost)))
-	mux.Handle("/api/posts/delete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeletePost)))
-
-	// Comment routes (protected by auth middleware)
-	mux.Handle("/api/comments/delete", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteComment)))
-	mux.Handle("/api/comment/reply/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateReplComment)))
-	mux.Handle("/api/comments/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateComment)))
-	mux.HandleFunc("/api/comments/get", HandlerWrapper(db, handlers.GetPostComments)) // Public access
-
-	// Category routes (protected by auth middleware)
-	mux.Handle("/api/categories/create", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateCategory)))
-	mux.HandleFunc("/api/categories", HandlerWrapper(db, handlers.GetCategories))
-	// Like routes
-	mux.Handle("/api/likes/toggle", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleLike))) // Protected
-	mux.HandleFunc("/api/likes/reactions", HandlerWrapper(db, handlers.GetReactions))                       // Public
+// legacyDeprecated is when the unversioned /api routes were superseded by /api/v1
+var legacyDeprecated = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+
+func SetupRoutes(db *sql.DB, jobs *scheduler.Scheduler, checker *health.Checker) *Mux {
+	mux := NewMux()
+	legacy := func(h http.Handler) http.Handler { return middleware.Deprecated(legacyDeprecated, h) }
+
+	// Liveness, readiness and build information
+	mux.HandleFunc("GET /healthz", handlers.Healthz)
+	mux.HandleFunc("GET /readyz", handlers.Readyz(checker))
+	mux.HandleFunc("GET /api/version", HandlerWrapper(db, handlers.GetVersion))
+
+	// API documentation
+	mux.Handle("GET /api/openapi.json", openapi.Handler())
+	mux.Handle("GET /api/docs/", v5emb.New("Forum API", "/api/openapi.json", "/api/docs/"))
+
+	// Authentication and the logged in user
+	mux.HandleFunc("POST /api/v1/register", HandlerWrapper(db, handlers.RegisterUser))
+	mux.HandleFunc("POST /api/v1/login", HandlerWrapper(db, handlers.LoginUser))
+	mux.HandleFunc("POST /api/v1/logout", HandlerWrapper(db, handlers.LogoutUser))
+	mux.Handle("GET /api/v1/me", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetUser)))
+	mux.Handle("PATCH /api/v1/me", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdateMe)))
+	mux.Handle("DELETE /api/v1/me", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteMe)))
+	mux.Handle("PUT /api/v1/me/avatar", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdateAvatar)))
+	mux.Handle("DELETE /api/v1/me/avatar", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteAvatar)))
+	mux.Handle("POST /api/v1/me/password", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ChangePassword)))
+	mux.Handle("GET /api/v1/me/reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetMyReactions)))
+	mux.Handle("GET /api/v1/me/saved", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetSavedPosts)))
+	mux.Handle("GET /api/v1/me/saved/collections", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetSavedCollections)))
+	mux.Handle("GET /api/v1/me/export", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.RequestDataExport(jobs))))
+	mux.Handle("GET /api/v1/exports/{id}", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DownloadDataExport)))
+
+	// Public user profiles
+	mux.HandleFunc("GET /api/v1/users/{username}", HandlerWrapper(db, handlers.GetProfile))
+	mux.HandleFunc("GET /api/v1/users/{username}/posts", HandlerWrapper(db, handlers.GetProfilePosts))
+	mux.HandleFunc("GET /api/v1/users/{username}/comments", HandlerWrapper(db, handlers.GetProfileComments))
+
+	// Posts (writes protected by auth middleware)
+	mux.HandleFunc("GET /api/v1/posts", HandlerWrapper(db, handlers.GetPosts))
+	mux.Handle("POST /api/v1/posts", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreatePost)))
+	mux.HandleFunc("GET /api/v1/posts/{id}", HandlerWrapper(db, handlers.GetPost))
+	mux.Handle("PUT /api/v1/posts/{id}", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdatePost)))
+	mux.Handle("PATCH /api/v1/posts/{id}", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.PatchPost)))
+	mux.Handle("DELETE /api/v1/posts/{id}", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeletePost)))
+	mux.Handle("PUT /api/v1/posts/{id}/save", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.SavePost)))
+	mux.Handle("DELETE /api/v1/posts/{id}/save", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UnsavePost)))
+
+	// Comments and replies
+	mux.HandleFunc("GET /api/v1/posts/{id}/comments", HandlerWrapper(db, handlers.GetPostComments))
+	mux.Handle("POST /api/v1/posts/{id}/comments", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateComment)))
+	mux.Handle("DELETE /api/v1/comments/{id}", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteComment)))
+	mux.HandleFunc("GET /api/v1/comments/{id}/replies", HandlerWrapper(db, handlers.GetCommentReplies))
+	mux.Handle("POST /api/v1/comments/{id}/replies", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateReplComment)))
+
+	// Reactions
+	mux.HandleFunc("GET /api/v1/posts/{id}/reactions", HandlerWrapper(db, handlers.GetPostReactions))
+	mux.Handle("POST /api/v1/posts/{id}/reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.TogglePostReaction)))
+	mux.HandleFunc("GET /api/v1/comments/{id}/reactions", HandlerWrapper(db, handlers.GetCommentReactions))
+	mux.Handle("POST /api/v1/comments/{id}/reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleCommentReaction)))
+	mux.HandleFunc("GET /api/v1/posts/{id}/reactions/users", HandlerWrapper(db, handlers.GetPostReactors))
+	mux.HandleFunc("GET /api/v1/comments/{id}/reactions/users", HandlerWrapper(db, handlers.GetCommentReactors))
+	mux.HandleFunc("GET /api/v1/replies/{id}/reactions", HandlerWrapper(db, handlers.GetReplyReactions))
+	mux.Handle("POST /api/v1/replies/{id}/reactions", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleReplyReaction)))
+	mux.HandleFunc("GET /api/v1/replies/{id}/reactions/users", HandlerWrapper(db, handlers.GetReplyReactors))
+	mux.HandleFunc("POST /api/v1/reactions/batch", HandlerWrapper(db, handlers.GetReactionsBatch))
+	mux.HandleFunc("GET /api/v1/reactions/types", HandlerWrapper(db, handlers.GetReactionTypes))
+
+	// Categories
+	mux.HandleFunc("GET /api/v1/categories", HandlerWrapper(db, handlers.GetCategories))
+	mux.Handle("POST /api/v1/categories", middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateCategory)))
+
+	// Trending, ranked by the scores the trending_recompute job caches
+	mux.HandleFunc("GET /api/v1/trending", HandlerWrapper(db, handlers.GetTrending))
+	mux.HandleFunc("GET /api/v1/trending/categories", HandlerWrapper(db, handlers.GetTrendingCategories))
+
+	// Read-only GraphQL over the same data; the session cookie identifies the viewer
+	gql := graph.Handler(db)
+	mux.Handle("GET /graphql", gql)
+	mux.Handle("POST /graphql", gql)
+
+	// Legacy unversioned routes, kept as deprecated aliases of /api/v1
+	mux.Handle("GET /api/user", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.GetUser))))
+	mux.Handle("POST /api/register", legacy(HandlerWrapper(db, handlers.RegisterUser)))
+	mux.Handle("POST /api/login", legacy(HandlerWrapper(db, handlers.LoginUser)))
+	mux.Handle("POST /api/logout", legacy(HandlerWrapper(db, handlers.LogoutUser)))
+	mux.Handle("POST /api/posts/create", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreatePost))))
+	mux.Handle("GET /api/posts", legacy(HandlerWrapper(db, handlers.GetPosts)))
+	mux.Handle("GET /api/posts/{id}", legacy(HandlerWrapper(db, handlers.GetPost)))
+	mux.Handle("PUT /api/posts/update", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.UpdatePost))))
+	mux.Handle("DELETE /api/posts/delete", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeletePost))))
+	mux.Handle("DELETE /api/comments/delete", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.DeleteComment))))
+	mux.Handle("POST /api/comment/reply/create", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateReplComment))))
+	mux.Handle("POST /api/comments/create", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateComment))))
+	mux.Handle("GET /api/comments/get", legacy(HandlerWrapper(db, handlers.GetPostComments)))
+	mux.Handle("POST /api/categories/create", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.CreateCategory))))
+	mux.Handle("GET /api/categories", legacy(HandlerWrapper(db, handlers.GetCategories)))
+	mux.Handle("POST /api/likes/toggle", legacy(middleware.AuthMiddleware(db, HandlerWrapper(db, handlers.ToggleLike))))
+	mux.Handle("GET /api/likes/reactions", legacy(HandlerWrapper(db, handlers.GetReactions)))
 
 	// comment, post and likes owner
-	mux.Handle("/api/owner", HandlerWrapper(db, handlers.GetOwner))
+	mux.HandleFunc("GET /api/owner", HandlerWrapper(db, handlers.GetOwner))
+
+	// Admin routes (protected by the admin token)
+	mux.Handle("GET /api/admin/jobs", middleware.AdminMiddleware(handlers.GetJobs(jobs)))
+	mux.Handle("GET /api/admin/jobs/history", middleware.AdminMiddleware(handlers.GetJobHistory(jobs)))
+	mux.Handle("POST /api/admin/jobs/run", middleware.AdminMiddleware(handlers.RunJob(jobs)))
+
+	// Prometheus metrics, unless they are served on a separate listener
+	if cfg := config.Current().Metrics; cfg.Enabled && cfg.Addr == "" {
+		mux.Handle("GET /metrics", middleware.AdminMiddleware(metrics.Handler()))
+	}
 
 	// Serve static files securely (prevent directory listing)
-	fs := http.FileServer(http.Dir("./static"))
-	mux.Handle("/static/", http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	fs := http.FileServer(http.Dir(config.Current().Uploads.Dir))
+	mux.Handle("GET /static/", http.StripPrefix("/static/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.URL.Path == "/" || r.URL.Path == "" || r.URL.Path[len(r.URL.Path)-1] == '/' {
-			log.Printf("⚠️  Directory listing blocked: /static/%s", r.URL.Path)
-			http.NotFound(w, r)
+			logging.FromContext(r.Context()).Warn("directory listing blocked", "path", "/static/"+r.URL.Path)
+			utils.SendError(w, r, apierror.NotFound("Not found"))
 			return
 		}
 		fs.ServeHTTP(w, r)
@@ -0,0 +1,151 @@
+package scheduler
+
+import (
+	"slices"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseErrors(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"@fortnightly",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * 32 * *",
+		"* * * 0 *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"*/x * * * *",
+		"a * * * *",
+		"1-b * * * *",
+		"1,,2 * * * *",
+		"@every",
+		"@every soon",
+		"@every 500ms",
+	} {
+		if _, err := Parse(spec); err == nil {
+			t.Errorf("Parse(%q) succeeded, want an error", spec)
+		}
+	}
+}
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		field string
+		want  []int
+	}{
+		{"*", []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"3", []int{3}},
+		{"2-5", []int{2, 3, 4, 5}},
+		{"1,4,9", []int{1, 4, 9}},
+		{"*/3", []int{0, 3, 6, 9}},
+		{"1-8/3", []int{1, 4, 7}},
+		{"5/2", []int{5, 7, 9}},
+		{"0,7-9", []int{0, 7, 8, 9}},
+	}
+	for _, tt := range tests {
+		set, err := parseField(tt.field, bounds{0, 9})
+		if err != nil {
+			t.Errorf("parseField(%q): %v", tt.field, err)
+			continue
+		}
+		var got []int
+		for v := range 10 {
+			if has(set, v) {
+				got = append(got, v)
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("parseField(%q) = %v, want %v", tt.field, got, tt.want)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	utc := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// local parses a wall clock time in New York, offset included, so both
+	// sides of a DST change can be named
+	local := func(s string) time.Time {
+		v, err := time.Parse("2006-01-02 15:04:05 -0700", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v.In(ny)
+	}
+
+	tests := []struct {
+		name  string
+		spec  string
+		after time.Time
+		want  time.Time
+	}{
+		{"step", "*/15 * * * *", utc("2024-01-01 10:07:30"), utc("2024-01-01 10:15:00")},
+		{"strictly after", "*/15 * * * *", utc("2024-01-01 10:15:00"), utc("2024-01-01 10:30:00")},
+		{"range with step", "10-40/15 * * * *", utc("2024-01-01 10:26:00"), utc("2024-01-01 10:40:00")},
+		{"range with step wraps hour", "10-40/15 * * * *", utc("2024-01-01 10:40:00"), utc("2024-01-01 11:10:00")},
+		{"start with step", "5/20 * * * *", utc("2024-01-01 10:46:00"), utc("2024-01-01 11:05:00")},
+		{"list", "0 6,18 * * *", utc("2024-01-01 06:00:00"), utc("2024-01-01 18:00:00")},
+		{"month boundary", "0 0 1 * *", utc("2024-01-31 12:00:00"), utc("2024-02-01 00:00:00")},
+		{"year boundary", "30 23 31 12 *", utc("2024-12-31 23:30:00"), utc("2025-12-31 23:30:00")},
+		{"leap day", "0 0 29 2 *", utc("2025-03-01 00:00:00"), utc("2028-02-29 00:00:00")},
+		{"never", "0 0 30 2 *", utc("2024-01-01 00:00:00"), time.Time{}},
+
+		// With both day fields restricted, either may match
+		{"dom or dow, dow first", "0 0 1 * 1", utc("2024-09-01 00:00:00"), utc("2024-09-02 00:00:00")},
+		{"dom or dow, dom first", "0 0 1 * 1", utc("2024-09-30 00:00:00"), utc("2024-10-01 00:00:00")},
+		// With one of them "*", only the other restricts the day
+		{"dow only", "0 9 * * 1-5", utc("2024-09-06 10:00:00"), utc("2024-09-09 09:00:00")},
+		{"dom only", "0 9 15 * *", utc("2024-09-06 10:00:00"), utc("2024-09-15 09:00:00")},
+		{"sunday as 7", "0 0 * * 7", utc("2024-09-02 00:00:00"), utc("2024-09-08 00:00:00")},
+		{"sunday as 0", "0 0 * * 0", utc("2024-09-02 00:00:00"), utc("2024-09-08 00:00:00")},
+
+		{"@yearly", "@yearly", utc("2024-12-31 23:59:59"), utc("2025-01-01 00:00:00")},
+		{"@annually", "@annually", utc("2024-06-01 00:00:00"), utc("2025-01-01 00:00:00")},
+		{"@monthly", "@monthly", utc("2024-02-29 12:00:00"), utc("2024-03-01 00:00:00")},
+		{"@weekly", "@weekly", utc("2024-09-04 12:00:00"), utc("2024-09-08 00:00:00")},
+		{"@daily", "@daily", utc("2024-09-04 12:00:00"), utc("2024-09-05 00:00:00")},
+		{"@midnight", "@midnight", utc("2024-09-04 00:00:00"), utc("2024-09-05 00:00:00")},
+		{"@hourly", "@hourly", utc("2024-09-04 10:00:00"), utc("2024-09-04 11:00:00")},
+		{"@every", "@every 90s", utc("2024-09-04 10:00:00"), utc("2024-09-04 10:01:30")},
+		{"@every truncates", "@every 1m", utc("2024-09-04 10:00:00").Add(500 * time.Millisecond), utc("2024-09-04 10:01:00")},
+
+		// 02:00-02:59 does not exist on 2024-03-10 in New York
+		{"spring forward skips the missing time", "30 2 * * *", local("2024-03-10 00:00:00 -0500"), local("2024-03-11 02:30:00 -0400")},
+		{"spring forward hourly", "0 * * * *", local("2024-03-10 01:00:00 -0500"), local("2024-03-10 03:00:00 -0400")},
+		{"spring forward daily", "@daily", local("2024-03-09 12:00:00 -0500"), local("2024-03-10 00:00:00 -0500")},
+		// 01:00-01:59 happens twice on 2024-11-03 in New York
+		{"fall back first run", "30 1 * * *", local("2024-11-03 00:00:00 -0400"), local("2024-11-03 01:30:00 -0400")},
+		{"fall back runs once", "30 1 * * *", local("2024-11-03 01:30:00 -0400"), local("2024-11-04 01:30:00 -0500")},
+		{"fall back after repeat", "0 * * * *", local("2024-11-03 01:00:00 -0500"), local("2024-11-03 02:00:00 -0500")},
+		{"fall back 24 hours", "0 12 * * *", local("2024-11-02 12:00:00 -0400"), local("2024-11-03 12:00:00 -0500")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			got := s.Next(tt.after)
+			if !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.after, got, tt.want)
+			}
+		})
+	}
+}
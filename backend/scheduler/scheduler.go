@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"forum/models"
+	"forum/sqlite"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("forum/scheduler")
+
+var (
+	ErrUnknownJob     = errors.New("unknown job")
+	ErrAlreadyRunning = errors.New("job is already running")
+	ErrNotStarted     = errors.New("scheduler is not running")
+)
+
+// Job is a unit of background work run on a cron-like schedule
+type Job struct {
+	Name string
+	Spec string
+	// Jitter delays each run by a random amount up to this duration
+	Jitter time.Duration
+	Run    func(ctx context.Context) error
+}
+
+// Status describes a registered job for the admin API
+type Status struct {
+	Name    string         `json:"name"`
+	Spec    string         `json:"spec"`
+	Running bool           `json:"running"`
+	NextRun *time.Time     `json:"next_run,omitempty"`
+	LastRun *models.JobRun `json:"last_run,omitempty"`
+}
+
+type entry struct {
+	job      Job
+	schedule Schedule
+	running  atomic.Bool
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// Scheduler runs registered jobs in-process, never overlapping runs of the
+// same job, and records every run in the jobs table
+type Scheduler struct {
+	db *sql.DB
+	// Retention is how long run history is kept
+	Retention time.Duration
+
+	mu      sync.Mutex
+	entries []*entry
+	byName  map[string]*entry
+	ctx     context.Context
+	wg      sync.WaitGroup
+}
+
+// New creates a scheduler that records run history in db
+func New(db *sql.DB) *Scheduler {
+	return &Scheduler{
+		db:        db,
+		Retention: 30 * 24 * time.Hour,
+		byName:    make(map[string]*entry),
+	}
+}
+
+// Add registers a job. It must be called before Start.
+func (s *Scheduler) Add(job Job) error {
+	schedule, err := Parse(job.Spec)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", job.Name, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.byName[job.Name]; exists {
+		return fmt.Errorf("job %s is already registered", job.Name)
+	}
+	e := &entry{job: job, schedule: schedule}
+	s.entries = append(s.entries, e)
+	s.byName[job.Name] = e
+	return nil
+}
+
+// Start runs the registered jobs until ctx is cancelled, then waits for
+// in-progress runs to return
+func (s *Scheduler) Start(ctx context.Context) {
+	if err := sqlite.MarkInterruptedJobRuns(ctx, s.db); err != nil {
+		slog.Error("failed to mark interrupted job runs", "err", err)
+	}
+
+	s.mu.Lock()
+	s.ctx = ctx
+	entries := s.entries
+	s.mu.Unlock()
+
+	var loops sync.WaitGroup
+	for _, e := range entries {
+		loops.Add(1)
+		go func() {
+			defer loops.Done()
+			s.loop(ctx, e)
+		}()
+	}
+	loops.Wait()
+	s.wg.Wait()
+}
+
+// loop sleeps until the job's next activation and launches it
+func (s *Scheduler) loop(ctx context.Context, e *entry) {
+	for {
+		next := e.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn("job has no upcoming run", "job", e.job.Name, "spec", e.job.Spec)
+			return
+		}
+		if e.job.Jitter > 0 {
+			next = next.Add(rand.N(e.job.Jitter))
+		}
+		e.mu.Lock()
+		e.next = next
+		e.mu.Unlock()
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.wg.Add(1)
+		go s.run(ctx, e)
+	}
+}
+
+// RunNow triggers a job immediately, outside its schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	e, ok := s.byName[name]
+	ctx := s.ctx
+	s.mu.Unlock()
+
+	if !ok {
+		return ErrUnknownJob
+	}
+	if ctx == nil || ctx.Err() != nil {
+		return ErrNotStarted
+	}
+	if e.running.Load() {
+		return ErrAlreadyRunning
+	}
+
+	s.wg.Add(1)
+	go s.run(ctx, e)
+	return nil
+}
+
+// run executes one run of a job, recording it in the jobs table
+func (s *Scheduler) run(ctx context.Context, e *entry) {
+	defer s.wg.Done()
+	name := e.job.Name
+	logger := slog.With("job", name)
+
+	ctx, span := tracer.Start(ctx, "job "+name, trace.WithNewRoot(), trace.WithAttributes(attribute.String("job.name", name)))
+	defer span.End()
+	// Run history is still recorded when shutdown cancels ctx mid-run
+	record := context.WithoutCancel(ctx)
+
+	if !e.running.CompareAndSwap(false, true) {
+		logger.Warn("job skipped, previous run still in progress")
+		span.SetAttributes(attribute.Bool("job.skipped", true))
+		if err := sqlite.RecordSkippedJobRun(record, s.db, name, time.Now()); err != nil {
+			logger.Error("failed to record skipped job run", "err", err)
+		}
+		return
+	}
+	defer e.running.Store(false)
+
+	started := time.Now()
+	runID, err := sqlite.StartJobRun(record, s.db, name, started)
+	if err != nil {
+		logger.Error("failed to record job start", "err", err)
+	}
+
+	jobErr := safeRun(ctx, e.job)
+
+	status, errMsg := "succeeded", ""
+	if jobErr != nil {
+		status, errMsg = "failed", jobErr.Error()
+		span.RecordError(jobErr)
+		span.SetStatus(codes.Error, errMsg)
+		logger.Error("job failed", "duration", time.Since(started), "err", jobErr)
+	} else {
+		logger.Info("job finished", "duration", time.Since(started))
+	}
+
+	if runID != 0 {
+		if err := sqlite.FinishJobRun(record, s.db, runID, status, errMsg, time.Now()); err != nil {
+			logger.Error("failed to record job result", "err", err)
+		}
+	}
+	if err := sqlite.PruneJobRuns(record, s.db, time.Now().Add(-s.Retention)); err != nil {
+		logger.Error("failed to prune job history", "err", err)
+	}
+}
+
+// safeRun turns a panicking job into a failed run
+func safeRun(ctx context.Context, job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return job.Run(ctx)
+}
+
+// Status lists every registered job with its next and most recent run
+func (s *Scheduler) Status(ctx context.Context) ([]Status, error) {
+	latest, err := sqlite.GetLatestJobRuns(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	entries := s.entries
+	s.mu.Unlock()
+
+	statuses := make([]Status, 0, len(entries))
+	for _, e := range entries {
+		st := Status{Name: e.job.Name, Spec: e.job.Spec, Running: e.running.Load()}
+		e.mu.Lock()
+		if !e.next.IsZero() {
+			next := e.next
+			st.NextRun = &next
+		}
+		e.mu.Unlock()
+		if run, ok := latest[e.job.Name]; ok {
+			st.LastRun = &run
+		}
+		statuses = append(statuses, st)
+	}
+	return statuses, nil
+}
+
+// History returns the most recent runs of a job
+func (s *Scheduler) History(ctx context.Context, name string, limit int) ([]models.JobRun, error) {
+	s.mu.Lock()
+	_, ok := s.byName[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return sqlite.GetJobRuns(ctx, s.db, name, limit)
+}
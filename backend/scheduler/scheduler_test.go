@@ -0,0 +1,168 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"forum/sqlite"
+)
+
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if err := sqlite.InitializeDatabase(filepath.Join(t.TempDir(), "forum.db"), "../schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(sqlite.CloseDatabase)
+	return sqlite.DB
+}
+
+// start runs s in the background and returns a function that stops it and
+// waits for every run to finish
+func start(t *testing.T, s *Scheduler) func() {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.Start(ctx)
+	}()
+	// RunNow works once Start has taken ctx
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		s.mu.Lock()
+		started := s.ctx != nil
+		s.mu.Unlock()
+		if started {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("scheduler did not start")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+func statuses(t *testing.T, s *Scheduler, name string) []string {
+	t.Helper()
+	runs, err := s.History(context.Background(), name, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, run := range runs {
+		got = append(got, run.Status)
+	}
+	return got
+}
+
+func TestRunNowErrors(t *testing.T) {
+	s := New(openDB(t))
+	if err := s.Add(Job{Name: "noop", Spec: "@yearly", Run: func(context.Context) error { return nil }}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Add(Job{Name: "noop", Spec: "@daily"}); err == nil {
+		t.Error("adding a second job with the same name succeeded")
+	}
+	if err := s.Add(Job{Name: "bad", Spec: "61 * * * *"}); err == nil {
+		t.Error("adding a job with an invalid spec succeeded")
+	}
+
+	if err := s.RunNow("noop"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("RunNow before Start = %v, want ErrNotStarted", err)
+	}
+	stop := start(t, s)
+	if err := s.RunNow("missing"); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("RunNow of an unknown job = %v, want ErrUnknownJob", err)
+	}
+	stop()
+	if err := s.RunNow("noop"); !errors.Is(err, ErrNotStarted) {
+		t.Errorf("RunNow after stopping = %v, want ErrNotStarted", err)
+	}
+}
+
+func TestRunNowRecordsResult(t *testing.T) {
+	s := New(openDB(t))
+	jobs := map[string]func(context.Context) error{
+		"ok":     func(context.Context) error { return nil },
+		"fails":  func(context.Context) error { return errors.New("boom") },
+		"panics": func(context.Context) error { panic("boom") },
+	}
+	for name, run := range jobs {
+		if err := s.Add(Job{Name: name, Spec: "@yearly", Run: run}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stop := start(t, s)
+	for name := range jobs {
+		if err := s.RunNow(name); err != nil {
+			t.Fatalf("RunNow(%s): %v", name, err)
+		}
+	}
+	stop()
+
+	want := map[string]string{"ok": "succeeded", "fails": "failed", "panics": "failed"}
+	for name, status := range want {
+		got := statuses(t, s, name)
+		if len(got) != 1 || got[0] != status {
+			t.Errorf("%s runs = %v, want [%s]", name, got, status)
+		}
+	}
+}
+
+func TestNoOverlappingRuns(t *testing.T) {
+	s := New(openDB(t))
+	running := make(chan struct{})
+	release := make(chan struct{})
+	runs := 0
+	err := s.Add(Job{Name: "slow", Spec: "@yearly", Run: func(context.Context) error {
+		runs++
+		close(running)
+		<-release
+		return nil
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := start(t, s)
+	if err := s.RunNow("slow"); err != nil {
+		t.Fatal(err)
+	}
+	<-running
+
+	if err := s.RunNow("slow"); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("RunNow while running = %v, want ErrAlreadyRunning", err)
+	}
+	st, err := s.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st) != 1 || !st[0].Running {
+		t.Errorf("Status while running = %+v, want slow running", st)
+	}
+
+	// The schedule firing while the job is still running is skipped
+	e := s.byName["slow"]
+	s.wg.Add(1)
+	s.run(context.Background(), e)
+
+	close(release)
+	stop()
+
+	if runs != 1 {
+		t.Errorf("job ran %d times, want 1", runs)
+	}
+	got := statuses(t, s, "slow")
+	if len(got) != 2 || got[0] != "skipped" || got[1] != "succeeded" {
+		t.Errorf("slow runs = %v, want [skipped succeeded]", got)
+	}
+}
@@ -6,10 +6,37 @@ CREATE TABLE IF NOT EXISTS users (
     email TEXT UNIQUE NOT NULL,
     password_hash TEXT NOT NULL,
     avatar_url TEXT DEFAULT '',
+    bio TEXT NOT NULL DEFAULT '',
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 
+-- Placeholder that owns the content of anonymised accounts. Its username
+-- cannot be registered and its empty password hash never matches.
+INSERT OR IGNORE INTO users (id, username, email, password_hash, avatar_url)
+VALUES ('00000000-0000-0000-0000-000000000000', '[deleted]', 'deleted@invalid', '', '/static/profiles/default.png');
+
+-- Account deletions requested by their owners, carried out by the
+-- account_deletion job once the grace period has passed
+CREATE TABLE IF NOT EXISTS account_deletions (
+    user_id TEXT PRIMARY KEY,
+    mode TEXT NOT NULL CHECK (mode IN ('anonymize', 'delete')),
+    requested_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+-- Personal data exports. The id is the secret in the download link.
+CREATE TABLE IF NOT EXISTS data_exports (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'ready', 'failed')),
+    requested_at DATETIME NOT NULL,
+    expires_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_data_exports_user ON data_exports(user_id, requested_at);
+
 
 -- Sessions Table
 CREATE TABLE IF NOT EXISTS sessions (
@@ -29,6 +56,10 @@ CREATE TABLE IF NOT EXISTS posts (
     title TEXT NOT NULL,
     content TEXT NOT NULL,
     image_url TEXT,
+    -- Kept up to date by the count triggers below; `forum db recount` repairs them
+    like_count INTEGER NOT NULL DEFAULT 0,
+    dislike_count INTEGER NOT NULL DEFAULT 0,
+    comment_count INTEGER NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
@@ -51,12 +82,19 @@ CREATE TABLE IF NOT EXISTS comments (
     user_id TEXT NOT NULL,
     post_id INTEGER NOT NULL,
     content TEXT NOT NULL,
+    like_count INTEGER NOT NULL DEFAULT 0,
+    dislike_count INTEGER NOT NULL DEFAULT 0,
+    reply_count INTEGER NOT NULL DEFAULT 0,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
     -- FOREIGN KEY (parent_comment_id) REFERENCES comments(id) ON DELETE CASCADE
 );
+
+-- Indexes for loading a post's comments and for the "active" sort
+CREATE INDEX IF NOT EXISTS idx_comments_post ON comments(post_id);
+
 -- ReplyComments Table
 CREATE TABLE IF NOT EXISTS replycomments (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -69,24 +107,90 @@ CREATE TABLE IF NOT EXISTS replycomments (
     FOREIGN KEY (parent_comment_id) REFERENCES comments(id) ON DELETE CASCADE
 );
 
+CREATE INDEX IF NOT EXISTS idx_replycomments_parent ON replycomments(parent_comment_id);
+
+
+-- Reaction types users can give. like and dislike are built in; the others
+-- are synced from the reactions.types setting at startup, and disabled
+-- rather than deleted when they are removed so old reactions survive
+CREATE TABLE IF NOT EXISTS reaction_types (
+    name TEXT PRIMARY KEY,
+    emoji TEXT NOT NULL DEFAULT '',
+    position INTEGER NOT NULL DEFAULT 0,
+    enabled INTEGER NOT NULL DEFAULT 1
+);
 
--- Likes Table (Supports both post and comment likes and allows dislikes)
+INSERT OR IGNORE INTO reaction_types (name, emoji, position) VALUES
+    ('like', '👍', 0),
+    ('dislike', '👎', 1);
+
+-- Likes Table: one row per user, target and reaction type. target_type
+-- says whether the row is on a post, a comment or a reply, and only the
+-- matching ID column is set. A user may give several reaction types to the
+-- same target, but like and dislike exclude each other (enforced by ToggleLike).
 CREATE TABLE IF NOT EXISTS likes (
     user_id TEXT NOT NULL,
+    target_type TEXT NOT NULL CHECK (target_type IN ('post', 'comment', 'reply')),
     post_id INTEGER,
     comment_id INTEGER,
-    type TEXT NOT NULL CHECK(type IN ('like', 'dislike')),
+    reply_id INTEGER,
+    type TEXT NOT NULL,
     created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    PRIMARY KEY (user_id, post_id, comment_id),
+    CHECK ((post_id IS NOT NULL) + (comment_id IS NOT NULL) + (reply_id IS NOT NULL) = 1),
+    CHECK (CASE target_type WHEN 'post' THEN post_id WHEN 'comment' THEN comment_id ELSE reply_id END IS NOT NULL),
     FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
     FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
-    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY (reply_id) REFERENCES replycomments(id) ON DELETE CASCADE,
+    FOREIGN KEY (type) REFERENCES reaction_types(name)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_post ON likes(post_id, user_id, type) WHERE post_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_comment ON likes(comment_id, user_id, type) WHERE comment_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_likes_reply ON likes(reply_id, user_id, type) WHERE reply_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_likes_user ON likes(user_id);
+
+-- Posts each user bookmarked, optionally filed under one of their named
+-- collections ('' when unfiled)
+CREATE TABLE IF NOT EXISTS saved_posts (
+    user_id TEXT NOT NULL,
+    post_id INTEGER NOT NULL,
+    collection TEXT NOT NULL DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, post_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
 );
 
+CREATE INDEX IF NOT EXISTS idx_saved_posts_user ON saved_posts(user_id, created_at);
+
+-- Earlier versions of edited posts, recorded by the record_post_revision
+-- trigger below. created_at is when the version was saved and replaced_at
+-- when an edit replaced it.
+CREATE TABLE IF NOT EXISTS post_revisions (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    post_id INTEGER NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    replaced_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_post_revisions_post ON post_revisions(post_id);
+
 -- Ensure the old trigger is removed before creating a new one
 DROP TRIGGER IF EXISTS update_user_timestamp;
 DROP TRIGGER IF EXISTS update_post_timestamp;
 DROP TRIGGER IF EXISTS update_comment_timestamp;
+DROP TRIGGER IF EXISTS record_post_revision;
+DROP TRIGGER IF EXISTS count_like_insert;
+DROP TRIGGER IF EXISTS count_like_delete;
+DROP TRIGGER IF EXISTS count_like_update;
+DROP TRIGGER IF EXISTS count_comment_insert;
+DROP TRIGGER IF EXISTS count_comment_delete;
+DROP TRIGGER IF EXISTS count_reply_insert;
+DROP TRIGGER IF EXISTS count_reply_delete;
 
 -- Auto-update `updated_at` column in `users`
 CREATE TRIGGER update_user_timestamp
@@ -96,27 +200,139 @@ BEGIN
     UPDATE users SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
 END;
 
--- Auto-update `updated_at` column in `posts`
+-- Auto-update `updated_at` column in `posts` when its content changes,
+-- not when the count triggers touch it
 CREATE TRIGGER update_post_timestamp
-AFTER UPDATE ON posts
+AFTER UPDATE OF title, content, image_url ON posts
 FOR EACH ROW
 BEGIN
     UPDATE posts SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
 END;
 
--- Auto-update `updated_at` column in `comments`
+-- Keep the previous version of a post whose title or content changes
+CREATE TRIGGER record_post_revision
+AFTER UPDATE OF title, content ON posts
+FOR EACH ROW
+WHEN OLD.title IS NOT NEW.title OR OLD.content IS NOT NEW.content
+BEGIN
+    INSERT INTO post_revisions (post_id, title, content, created_at)
+    VALUES (OLD.id, OLD.title, OLD.content, OLD.updated_at);
+END;
+
+-- Auto-update `updated_at` column in `comments` when its content changes
 CREATE TRIGGER update_comment_timestamp
-AFTER UPDATE ON comments
+AFTER UPDATE OF content ON comments
 FOR EACH ROW
 BEGIN
     UPDATE comments SET updated_at = CURRENT_TIMESTAMP WHERE id = OLD.id;
 END;
 
+-- Keep like_count and dislike_count in step with `likes`
+CREATE TRIGGER count_like_insert
+AFTER INSERT ON likes
+FOR EACH ROW
+BEGIN
+    UPDATE posts SET
+        like_count = like_count + (NEW.type = 'like'),
+        dislike_count = dislike_count + (NEW.type = 'dislike')
+    WHERE id = NEW.post_id;
+    UPDATE comments SET
+        like_count = like_count + (NEW.type = 'like'),
+        dislike_count = dislike_count + (NEW.type = 'dislike')
+    WHERE id = NEW.comment_id;
+END;
+
+CREATE TRIGGER count_like_delete
+AFTER DELETE ON likes
+FOR EACH ROW
+BEGIN
+    UPDATE posts SET
+        like_count = like_count - (OLD.type = 'like'),
+        dislike_count = dislike_count - (OLD.type = 'dislike')
+    WHERE id = OLD.post_id;
+    UPDATE comments SET
+        like_count = like_count - (OLD.type = 'like'),
+        dislike_count = dislike_count - (OLD.type = 'dislike')
+    WHERE id = OLD.comment_id;
+END;
+
+CREATE TRIGGER count_like_update
+AFTER UPDATE OF type ON likes
+FOR EACH ROW
+BEGIN
+    UPDATE posts SET
+        like_count = like_count - (OLD.type = 'like') + (NEW.type = 'like'),
+        dislike_count = dislike_count - (OLD.type = 'dislike') + (NEW.type = 'dislike')
+    WHERE id = NEW.post_id;
+    UPDATE comments SET
+        like_count = like_count - (OLD.type = 'like') + (NEW.type = 'like'),
+        dislike_count = dislike_count - (OLD.type = 'dislike') + (NEW.type = 'dislike')
+    WHERE id = NEW.comment_id;
+END;
+
+-- Keep comment_count on posts and reply_count on comments in step
+CREATE TRIGGER count_comment_insert
+AFTER INSERT ON comments
+FOR EACH ROW
+BEGIN
+    UPDATE posts SET comment_count = comment_count + 1 WHERE id = NEW.post_id;
+END;
+
+CREATE TRIGGER count_comment_delete
+AFTER DELETE ON comments
+FOR EACH ROW
+BEGIN
+    UPDATE posts SET comment_count = comment_count - 1 WHERE id = OLD.post_id;
+END;
+
+CREATE TRIGGER count_reply_insert
+AFTER INSERT ON replycomments
+FOR EACH ROW
+BEGIN
+    UPDATE comments SET reply_count = reply_count + 1 WHERE id = NEW.parent_comment_id;
+END;
+
+CREATE TRIGGER count_reply_delete
+AFTER DELETE ON replycomments
+FOR EACH ROW
+BEGIN
+    UPDATE comments SET reply_count = reply_count - 1 WHERE id = OLD.parent_comment_id;
+END;
+
 CREATE TABLE IF NOT EXISTS categories (
     id INTEGER PRIMARY KEY AUTOINCREMENT,
     name TEXT UNIQUE NOT NULL
 );
 
+-- Background job run history
+CREATE TABLE IF NOT EXISTS jobs (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT NOT NULL,
+    status TEXT NOT NULL CHECK(status IN ('running', 'succeeded', 'failed', 'skipped')),
+    error TEXT NOT NULL DEFAULT '',
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_jobs_name_started ON jobs(name, started_at);
+
+-- Cached hot scores, recomputed periodically by the trending job
+CREATE TABLE IF NOT EXISTS trending_scores (
+    post_id INTEGER PRIMARY KEY,
+    score REAL NOT NULL,
+    computed_at DATETIME NOT NULL,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+
+-- Sum of the hot scores of each category's posts, recomputed with trending_scores
+CREATE TABLE IF NOT EXISTS trending_category_scores (
+    category_id INTEGER PRIMARY KEY,
+    score REAL NOT NULL,
+    post_count INTEGER NOT NULL,
+    computed_at DATETIME NOT NULL,
+    FOREIGN KEY (category_id) REFERENCES categories(id) ON DELETE CASCADE
+);
+
 
 -- BEGIN TRANSACTION;
 
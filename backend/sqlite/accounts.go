@@ -0,0 +1,130 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DeletedUserID is the placeholder user that anonymised accounts' posts,
+// comments and replies are reassigned to
+const DeletedUserID = "00000000-0000-0000-0000-000000000000"
+
+// UpdatePassword replaces the password hash of userID
+func UpdatePassword(ctx context.Context, db *sql.DB, userID, passwordHash string) error {
+	ctx, end := track(ctx, "UpdatePassword")
+	defer end()
+	_, err := db.ExecContext(ctx, `UPDATE users SET password_hash = ? WHERE id = ?`, passwordHash, userID)
+	return err
+}
+
+// DeleteOtherSessions logs userID out everywhere except the session keepID,
+// and returns how many sessions it removed
+func DeleteOtherSessions(ctx context.Context, db *sql.DB, userID, keepID string) (int64, error) {
+	ctx, end := track(ctx, "DeleteOtherSessions")
+	defer end()
+	res, err := db.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ? AND id != ?`, userID, keepID)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// RequestAccountDeletion schedules userID's account for deletion in mode
+// ("anonymize" or "delete") and logs them out of every session. Asking
+// again replaces the mode and restarts the grace period.
+func RequestAccountDeletion(ctx context.Context, db *sql.DB, userID, mode string, at time.Time) error {
+	ctx, end := track(ctx, "RequestAccountDeletion")
+	defer end()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO account_deletions (user_id, mode, requested_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id) DO UPDATE SET mode = excluded.mode, requested_at = excluded.requested_at
+	`, userID, mode, at.UTC()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM sessions WHERE user_id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// CancelAccountDeletion withdraws userID's pending deletion, and reports
+// whether there was one
+func CancelAccountDeletion(ctx context.Context, db *sql.DB, userID string) (bool, error) {
+	ctx, end := track(ctx, "CancelAccountDeletion")
+	defer end()
+	res, err := db.ExecContext(ctx, `DELETE FROM account_deletions WHERE user_id = ?`, userID)
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteDueAccounts carries out the deletions requested at or before
+// cutoff and returns how many accounts it removed. Anonymised accounts
+// hand their posts, comments and replies to DeletedUserID first; in both
+// modes the user's reactions, saved posts and sessions are removed with
+// the account.
+func DeleteDueAccounts(ctx context.Context, db *sql.DB, cutoff time.Time) (int, error) {
+	ctx, end := track(ctx, "DeleteDueAccounts")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT user_id, mode FROM account_deletions WHERE requested_at <= ?
+	`, cutoff.UTC())
+	if err != nil {
+		return 0, err
+	}
+	type deletion struct{ userID, mode string }
+	var due []deletion
+	for rows.Next() {
+		var d deletion
+		if err := rows.Scan(&d.userID, &d.mode); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		due = append(due, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for i, d := range due {
+		if err := deleteAccount(ctx, db, d.userID, d.mode == "anonymize"); err != nil {
+			return i, err
+		}
+	}
+	return len(due), nil
+}
+
+// deleteAccount removes userID, reassigning their content to DeletedUserID
+// first when anonymize is set. The foreign keys cascade to everything else.
+func deleteAccount(ctx context.Context, db *sql.DB, userID string, anonymize bool) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if anonymize {
+		for _, table := range []string{"posts", "comments", "replycomments"} {
+			if _, err := tx.ExecContext(ctx, `UPDATE `+table+` SET user_id = ? WHERE user_id = ?`, DeletedUserID, userID); err != nil {
+				return err
+			}
+		}
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM users WHERE id = ?`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
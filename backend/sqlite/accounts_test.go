@@ -0,0 +1,235 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+)
+
+// countRows returns the number of rows query selects
+func countRows(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM (`+query+`)`, args...).Scan(&n); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+	return n
+}
+
+// checkOwner checks that the row id of table belongs to userID
+func checkOwner(t *testing.T, db *sql.DB, table string, id int, userID string) {
+	t.Helper()
+	var got string
+	if err := db.QueryRow(`SELECT user_id FROM `+table+` WHERE id = ?`, id).Scan(&got); err != nil {
+		t.Fatalf("%s %d: %v", table, id, err)
+	}
+	if got != userID {
+		t.Errorf("%s %d belongs to %q, want %q", table, id, got, userID)
+	}
+}
+
+func TestDeleteDueAccounts(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash) VALUES
+			('u1', 'ann', 'ann@example.com', ''),
+			('u2', 'ben', 'ben@example.com', ''),
+			('u3', 'cat', 'cat@example.com', '');
+	`)
+
+	// ann is deleted outright and cat anonymised; ben stays
+	post := func(userID string) int {
+		p, err := CreatePost(ctx, db, userID, nil, "title", "content", "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return p.ID
+	}
+	comment := func(userID string, postID int) int {
+		c, err := CreateComment(ctx, db, userID, postID, "comment")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.ID
+	}
+	reply := func(userID string, commentID int) int {
+		r, err := CreateReplyComment(ctx, db, userID, commentID, "reply")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r.ID
+	}
+	react := func(userID, target string, id int, reaction string) {
+		if err := ToggleLike(ctx, db, userID, target, id, reaction); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	annPost, benPost, catPost := post("u1"), post("u2"), post("u3")
+	benOnAnn := comment("u2", annPost)
+	reply("u3", benOnAnn)
+	annOnBen := comment("u1", benPost)
+	reply("u2", annOnBen)
+	catOnBen := comment("u3", benPost)
+	reply("u1", catOnBen)
+	catReply := reply("u3", catOnBen)
+
+	react("u1", "post", benPost, "like")
+	react("u3", "post", benPost, "like")
+	react("u2", "post", catPost, "dislike")
+	react("u1", "comment", catOnBen, "like")
+	react("u2", "comment", catOnBen, "like")
+	react("u3", "comment", annOnBen, "dislike")
+	if err := SavePost(ctx, db, "u1", benPost, ""); err != nil {
+		t.Fatal(err)
+	}
+	checkCounts(t, db,
+		map[int]postCounts{benPost: {2, 0, 2}, catPost: {0, 1, 0}},
+		map[int]commentCounts{catOnBen: {2, 0, 2}, annOnBen: {0, 1, 1}})
+
+	now := time.Now()
+	for _, s := range []string{"u1", "u2", "u3"} {
+		if _, err := CreateSession(ctx, db, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := RequestAccountDeletion(ctx, db, "u1", "delete", now.Add(-20*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := RequestAccountDeletion(ctx, db, "u3", "anonymize", now.Add(-2*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if n := countRows(t, db, `SELECT 1 FROM sessions WHERE user_id IN ('u1', 'u3')`); n != 0 {
+		t.Errorf("%d sessions left after requesting deletion, want 0", n)
+	}
+
+	// Cancelled requests are not carried out
+	if err := RequestAccountDeletion(ctx, db, "u2", "delete", now.Add(-30*24*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []bool{true, false} {
+		if cancelled, err := CancelAccountDeletion(ctx, db, "u2"); err != nil || cancelled != want {
+			t.Errorf("CancelAccountDeletion = %v, %v, want %v", cancelled, err, want)
+		}
+	}
+
+	// Only ann's request is past the grace period
+	n, err := DeleteDueAccounts(ctx, db, now.Add(-14*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d accounts, want 1", n)
+	}
+	// Her post went with the comment and reply under it, her comment with
+	// its reply, and her reply, reactions and saved post with her
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{`SELECT 1 FROM users WHERE id = 'u1'`, 0},
+		{`SELECT 1 FROM posts WHERE id = ?`, 0},
+		{`SELECT 1 FROM comments WHERE post_id = ? OR user_id = 'u1'`, 0},
+		{`SELECT 1 FROM replycomments WHERE user_id = 'u1'`, 0},
+		{`SELECT 1 FROM likes WHERE user_id = 'u1'`, 0},
+		{`SELECT 1 FROM saved_posts WHERE user_id = 'u1'`, 0},
+		{`SELECT 1 FROM account_deletions`, 1},
+		{`SELECT 1 FROM replycomments`, 1},
+	} {
+		if got := countRows(t, db, tt.query, annPost); got != tt.want {
+			t.Errorf("%s: %d rows, want %d", tt.query, got, tt.want)
+		}
+	}
+	checkCounts(t, db,
+		map[int]postCounts{benPost: {1, 0, 1}, catPost: {0, 1, 0}},
+		map[int]commentCounts{catOnBen: {1, 0, 1}})
+
+	n, err = DeleteDueAccounts(ctx, db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("deleted %d accounts, want 1", n)
+	}
+	// Cat's writing stays under the placeholder user; only her reactions go
+	checkOwner(t, db, "posts", catPost, DeletedUserID)
+	checkOwner(t, db, "comments", catOnBen, DeletedUserID)
+	checkOwner(t, db, "replycomments", catReply, DeletedUserID)
+	for _, tt := range []struct {
+		query string
+		want  int
+	}{
+		{`SELECT 1 FROM users WHERE id = 'u3'`, 0},
+		{`SELECT 1 FROM likes WHERE user_id = 'u3'`, 0},
+		{`SELECT 1 FROM account_deletions`, 0},
+		{`SELECT 1 FROM users WHERE id IN ('u2', ?)`, 2},
+	} {
+		if got := countRows(t, db, tt.query, DeletedUserID); got != tt.want {
+			t.Errorf("%s: %d rows, want %d", tt.query, got, tt.want)
+		}
+	}
+	checkCounts(t, db,
+		map[int]postCounts{benPost: {0, 0, 1}, catPost: {0, 1, 0}},
+		map[int]commentCounts{catOnBen: {1, 0, 1}})
+
+	if n, err := DeleteDueAccounts(ctx, db, now); err != nil || n != 0 {
+		t.Errorf("second run deleted %d accounts, %v, want 0", n, err)
+	}
+	if posts, comments, err := Recount(ctx, db); err != nil || posts != 0 || comments != 0 {
+		t.Errorf("recount repaired %d posts and %d comments, %v, want none", posts, comments, err)
+	}
+}
+
+func TestDeleteOtherSessions(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash) VALUES
+			('u1', 'ann', 'ann@example.com', ''),
+			('u2', 'ben', 'ben@example.com', '');
+	`)
+
+	var sessions []string
+	for range 3 {
+		id, err := CreateSession(ctx, db, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sessions = append(sessions, id)
+	}
+	other, err := CreateSession(ctx, db, "u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := DeleteOtherSessions(ctx, db, "u1", sessions[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 2 {
+		t.Errorf("removed %d sessions, want 2", n)
+	}
+	for _, tt := range []struct {
+		id   string
+		want int
+	}{
+		{sessions[0], 0},
+		{sessions[1], 1},
+		{sessions[2], 0},
+		{other, 1},
+	} {
+		if got := countRows(t, db, `SELECT 1 FROM sessions WHERE id = ?`, tt.id); got != tt.want {
+			t.Errorf("session %s: %d rows, want %d", tt.id, got, tt.want)
+		}
+	}
+
+	// Another user's session ID keeps nothing of this user's
+	if n, err := DeleteOtherSessions(ctx, db, "u1", other); err != nil || n != 1 {
+		t.Errorf("removed %d sessions, %v, want 1", n, err)
+	}
+	if got := countRows(t, db, `SELECT 1 FROM sessions WHERE id = ?`, other); got != 1 {
+		t.Error("another user's session was removed")
+	}
+}
@@ -0,0 +1,250 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"forum/models"
+)
+
+// Batched lookups keyed by ID, so that one query serves a whole page of
+// posts or comments, or every object at the same depth of a GraphQL request
+
+// inClause returns "?, ?, ..." for n values and the values as query args
+func inClause[T any](values []T) (string, []any) {
+	args := make([]any, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", "), args
+}
+
+// GetUsersByIDs retrieves users keyed by ID. Unknown IDs are left out.
+func GetUsersByIDs(ctx context.Context, db *sql.DB, ids []string) (map[string]models.User, error) {
+	ctx, end := track(ctx, "GetUsersByIDs")
+	defer end()
+
+	in, args := inClause(ids)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, username, email, avatar_url, created_at, updated_at
+		FROM users
+		WHERE id IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]models.User, len(ids))
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.Email, &u.AvatarURL, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		users[u.ID] = u
+	}
+	return users, rows.Err()
+}
+
+// GetCategoriesByIDs retrieves categories keyed by ID
+func GetCategoriesByIDs(ctx context.Context, db *sql.DB, ids []int) (map[int]models.Category, error) {
+	ctx, end := track(ctx, "GetCategoriesByIDs")
+	defer end()
+
+	in, args := inClause(ids)
+	rows, err := db.QueryContext(ctx, `SELECT id, name FROM categories WHERE id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := make(map[int]models.Category, len(ids))
+	for rows.Next() {
+		var c models.Category
+		if err := rows.Scan(&c.ID, &c.Name); err != nil {
+			return nil, err
+		}
+		categories[c.ID] = c
+	}
+	return categories, rows.Err()
+}
+
+// GetCommentsByPostIDs retrieves the top-level comments of each post,
+// oldest first, without their replies
+func GetCommentsByPostIDs(ctx context.Context, db *sql.DB, postIDs []int) (map[int][]models.Comment, error) {
+	ctx, end := track(ctx, "GetCommentsByPostIDs")
+	defer end()
+
+	in, args := inClause(postIDs)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, user_id, post_id, content, created_at, updated_at
+		FROM comments
+		WHERE post_id IN (`+in+`)
+		ORDER BY created_at ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make(map[int][]models.Comment, len(postIDs))
+	for rows.Next() {
+		var c models.Comment
+		if err := rows.Scan(&c.ID, &c.UserID, &c.PostID, &c.Content, &c.CreatedAt, &c.UpdatedAt); err != nil {
+			return nil, err
+		}
+		comments[c.PostID] = append(comments[c.PostID], c)
+	}
+	return comments, rows.Err()
+}
+
+// GetRepliesByCommentIDs retrieves the replies to each comment, oldest first
+func GetRepliesByCommentIDs(ctx context.Context, db *sql.DB, commentIDs []int) (map[int][]models.ReplyComment, error) {
+	ctx, end := track(ctx, "GetRepliesByCommentIDs")
+	defer end()
+
+	in, args := inClause(commentIDs)
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, user_id, parent_comment_id, content, created_at, updated_at
+		FROM replycomments
+		WHERE parent_comment_id IN (`+in+`)
+		ORDER BY created_at ASC, id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replies := make(map[int][]models.ReplyComment, len(commentIDs))
+	for rows.Next() {
+		var r models.ReplyComment
+		if err := rows.Scan(&r.ID, &r.UserID, &r.ParentCommentID, &r.Content, &r.CreatedAt, &r.UpdatedAt); err != nil {
+			return nil, err
+		}
+		replies[r.ParentCommentID] = append(replies[r.ParentCommentID], r)
+	}
+	return replies, rows.Err()
+}
+
+// GetReactionSummaries counts each enabled reaction type on each of the
+// posts, comments and replies in one query. When viewerID is set, each
+// summary also carries that user's own reactions. Every requested ID is
+// present in the result, with zero counts if it has no reactions.
+//
+// The like_count and dislike_count columns are not used here: they exist
+// so listings can sort and score posts and comments without touching
+// likes, while a summary needs every reaction type, replies as well, and
+// the viewer's own reactions, which this one indexed GROUP BY gives.
+func GetReactionSummaries(ctx context.Context, db *sql.DB, viewerID string, postIDs, commentIDs, replyIDs []int) (models.ReactionBatch, error) {
+	ctx, end := track(ctx, "GetReactionSummaries")
+	defer end()
+
+	batch := models.ReactionBatch{
+		Posts:    zeroCounts(postIDs),
+		Comments: zeroCounts(commentIDs),
+		Replies:  zeroCounts(replyIDs),
+	}
+	if len(postIDs) == 0 && len(commentIDs) == 0 && len(replyIDs) == 0 {
+		return batch, nil
+	}
+	summaries := map[string]map[int]models.ReactionCounts{
+		"post":    batch.Posts,
+		"comment": batch.Comments,
+		"reply":   batch.Replies,
+	}
+
+	postIn, postArgs := inClause(postIDs)
+	commentIn, commentArgs := inClause(commentIDs)
+	replyIn, replyArgs := inClause(replyIDs)
+	args := append([]any{viewerID}, postArgs...)
+	args = append(args, commentArgs...)
+	args = append(args, replyArgs...)
+	rows, err := db.QueryContext(ctx, `
+		SELECT l.target_type, COALESCE(l.post_id, l.comment_id, l.reply_id) AS target_id,
+			l.type, COUNT(*), MAX(l.user_id = ?)
+		FROM likes l
+		JOIN reaction_types t ON t.name = l.type
+		WHERE t.enabled AND (
+			l.post_id IN (`+postIn+`)
+			OR l.comment_id IN (`+commentIn+`)
+			OR l.reply_id IN (`+replyIn+`)
+		)
+		GROUP BY l.target_type, target_id, l.type
+		ORDER BY t.position
+	`, args...)
+	if err != nil {
+		return models.ReactionBatch{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var target, typ string
+		var id, n int
+		var mine bool
+		if err := rows.Scan(&target, &id, &typ, &n, &mine); err != nil {
+			return models.ReactionBatch{}, err
+		}
+		if counts, ok := summaries[target]; ok {
+			counts[id] = addReaction(counts[id], typ, n, mine)
+		}
+	}
+	return batch, rows.Err()
+}
+
+// zeroCounts returns empty counts for each of ids
+func zeroCounts(ids []int) map[int]models.ReactionCounts {
+	counts := make(map[int]models.ReactionCounts, len(ids))
+	for _, id := range ids {
+		counts[id] = models.ReactionCounts{}
+	}
+	return counts
+}
+
+// addReaction records n reactions of typ, mine when the viewer gave one
+func addReaction(c models.ReactionCounts, typ string, n int, mine bool) models.ReactionCounts {
+	if c.Counts == nil {
+		c.Counts = make(map[string]int)
+	}
+	c.Counts[typ] = n
+	switch typ {
+	case "like":
+		c.Likes = n
+	case "dislike":
+		c.Dislikes = n
+	}
+	if mine {
+		c.ViewerReactions = append(c.ViewerReactions, typ)
+		if _, ok := opposingVotes[typ]; ok {
+			c.ViewerReaction = typ
+		}
+	}
+	return c
+}
+
+// GetSavedPostIDs reports which of postIDs userID has saved
+func GetSavedPostIDs(ctx context.Context, db *sql.DB, userID string, postIDs []int) (map[int]bool, error) {
+	ctx, end := track(ctx, "GetSavedPostIDs")
+	defer end()
+
+	in, args := inClause(postIDs)
+	rows, err := db.QueryContext(ctx, `
+		SELECT post_id
+		FROM saved_posts
+		WHERE user_id = ? AND post_id IN (`+in+`)
+	`, append([]any{userID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saved := make(map[int]bool, len(postIDs))
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		saved[id] = true
+	}
+	return saved, rows.Err()
+}
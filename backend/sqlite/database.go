@@ -1,20 +1,23 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io"
 	"os"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 var DB *sql.DB
 
-// InitializeDatabase initializes the SQLite database
-func InitializeDatabase(dbPath string) error {
+// SchemaVersion is the version of schema.sql this binary expects. Bump it
+// whenever schema.sql changes; it is stored in PRAGMA user_version.
+const SchemaVersion = 11
+
+// InitializeDatabase initializes the SQLite database and applies the schema file
+func InitializeDatabase(dbPath, schemaPath string) error {
 	var err error
-	DB, err = sql.Open("sqlite3", dbPath)
+	DB, err = sql.Open(driverName, dbPath)
 	if err != nil {
 		return fmt.Errorf("failed to open database: %w", err)
 	}
@@ -24,14 +27,183 @@ func InitializeDatabase(dbPath string) error {
 	if err != nil {
 		return fmt.Errorf("failed to enable foreign key constraints: %w", err)
 	}
-	
+
+	// Add columns that CREATE TABLE IF NOT EXISTS cannot add to older databases
+	added, err := addCounterColumns(DB)
+	if err != nil {
+		return fmt.Errorf("failed to add count columns: %w", err)
+	}
+	if _, err := addColumns(DB, "users", userColumns); err != nil {
+		return fmt.Errorf("failed to add user columns: %w", err)
+	}
+
+	// Move likes aside when its definition predates likesVersion
+	if err := renameOldLikes(DB); err != nil {
+		return fmt.Errorf("failed to migrate likes: %w", err)
+	}
+
 	// Apply schema from schema.sql file
-	if err := applySchemaFromFile("schema.sql"); err != nil {
+	if err := applySchemaFromFile(schemaPath); err != nil {
 		return fmt.Errorf("failed to apply schema: %w", err)
 	}
+
+	oldLikes, err := copyOldLikes(DB)
+	if err != nil {
+		return fmt.Errorf("failed to migrate likes: %w", err)
+	}
+
+	// Fill the new count columns from the rows that already exist, and
+	// repair the counts the copied likes added a second time
+	if added || oldLikes {
+		if _, _, err := Recount(context.Background(), DB); err != nil {
+			return fmt.Errorf("failed to fill count columns: %w", err)
+		}
+	}
+
+	// Record which schema version has been applied
+	if _, err := DB.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return fmt.Errorf("failed to set schema version: %w", err)
+	}
 	return nil
 }
 
+// GetSchemaVersion returns the schema version recorded in the database
+func GetSchemaVersion(ctx context.Context, db *sql.DB) (int, error) {
+	ctx, end := track(ctx, "GetSchemaVersion")
+	defer end()
+	var v int
+	err := db.QueryRowContext(ctx, "PRAGMA user_version").Scan(&v)
+	return v, err
+}
+
+// counterColumns are the denormalised counts added in schema version 2
+var counterColumns = map[string][]string{
+	"posts":    {"like_count", "dislike_count", "comment_count"},
+	"comments": {"like_count", "dislike_count", "reply_count"},
+}
+
+// userColumns are the users columns added after version 1, with their definitions
+var userColumns = map[string]string{
+	"bio": "TEXT NOT NULL DEFAULT ''",
+}
+
+// addCounterColumns adds any missing counterColumns to tables that already
+// exist, and reports whether it added any
+func addCounterColumns(db *sql.DB) (bool, error) {
+	added := false
+	for table, columns := range counterColumns {
+		definitions := make(map[string]string, len(columns))
+		for _, column := range columns {
+			definitions[column] = "INTEGER NOT NULL DEFAULT 0"
+		}
+		ok, err := addColumns(db, table, definitions)
+		if err != nil {
+			return false, err
+		}
+		added = added || ok
+	}
+	return added, nil
+}
+
+// addColumns adds the columns in definitions that table is missing, when
+// table already exists, and reports whether it added any
+func addColumns(db *sql.DB, table string, definitions map[string]string) (bool, error) {
+	existing, err := tableColumns(db, table)
+	if err != nil {
+		return false, err
+	}
+	if len(existing) == 0 {
+		// New database; schema.sql creates the table with its columns
+		return false, nil
+	}
+	added := false
+	for column, definition := range definitions {
+		if existing[column] {
+			continue
+		}
+		if _, err := db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)); err != nil {
+			return false, err
+		}
+		added = true
+	}
+	return added, nil
+}
+
+// tableColumns returns the set of column names in table, empty if it does not exist
+func tableColumns(db *sql.DB, table string) (map[string]bool, error) {
+	rows, err := db.Query("SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := make(map[string]bool)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		columns[name] = true
+	}
+	return columns, rows.Err()
+}
+
+// likesVersion is the schema version that last changed the likes table's
+// constraints, which SQLite can only change by recreating the table
+const likesVersion = 6
+
+// renameOldLikes renames a likes table from before likesVersion to
+// likes_old so schema.sql can create the new table. Before version 5 its
+// CHECK constraint only allowed like and dislike and its primary key one
+// reaction per user; before version 6 it had no target_type or reply_id.
+func renameOldLikes(db *sql.DB) error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return err
+	}
+	if version >= likesVersion {
+		return nil
+	}
+	// A likes_old left by an interrupted start is copied as it is
+	if old, err := tableColumns(db, "likes_old"); err != nil || len(old) > 0 {
+		return err
+	}
+	columns, err := tableColumns(db, "likes")
+	if err != nil || len(columns) == 0 {
+		return err
+	}
+	_, err = db.Exec("ALTER TABLE likes RENAME TO likes_old")
+	return err
+}
+
+// copyOldLikes moves the rows renameOldLikes set aside into the new likes
+// table and drops likes_old. It reports whether there was anything to move.
+func copyOldLikes(db *sql.DB) (bool, error) {
+	columns, err := tableColumns(db, "likes_old")
+	if err != nil || len(columns) == 0 {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`
+		INSERT OR IGNORE INTO likes (user_id, target_type, post_id, comment_id, type, created_at)
+		SELECT user_id, IIF(post_id IS NULL, 'comment', 'post'), post_id, comment_id, type, created_at
+		FROM likes_old
+		WHERE (post_id IS NULL) != (comment_id IS NULL)
+	`); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("DROP TABLE likes_old"); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
 // applySchemaFromFile reads and executes schema.sql
 func applySchemaFromFile(filename string) error {
 	file, err := os.Open(filename)
@@ -47,8 +219,7 @@ func applySchemaFromFile(filename string) error {
 
 	_, err = DB.Exec(string(schemaSQL))
 	if err != nil {
-		fmt.Printf("❌ Schema execution failed: %v\nContents of schema.sql:\n%s\n", err, string(schemaSQL))
-		return err
+		return fmt.Errorf("failed to execute %s: %w", filename, err)
 	}
 
 	return nil
@@ -0,0 +1,234 @@
+package sqlite
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+// openDB initialises a new database from schema.sql for the test
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	if err := InitializeDatabase(filepath.Join(t.TempDir(), "forum.db"), "../schema.sql"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(CloseDatabase)
+	return DB
+}
+
+// mustExec runs statements that set up a test
+func mustExec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	if _, err := db.Exec(query, args...); err != nil {
+		t.Fatalf("%s: %v", query, err)
+	}
+}
+
+// schemaV1 is the part of the first schema.sql that later versions migrate
+const schemaV1 = `
+CREATE TABLE users (
+    id TEXT PRIMARY KEY,
+    username TEXT UNIQUE NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    avatar_url TEXT DEFAULT '',
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    user_id TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE posts (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    title TEXT NOT NULL,
+    content TEXT NOT NULL,
+    image_url TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+CREATE TABLE comments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    post_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
+CREATE TABLE replycomments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    parent_comment_id INTEGER NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (parent_comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE TABLE likes (
+    user_id TEXT NOT NULL,
+    post_id INTEGER,
+    comment_id INTEGER,
+    type TEXT NOT NULL CHECK(type IN ('like', 'dislike')),
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, post_id, comment_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY (comment_id) REFERENCES comments(id) ON DELETE CASCADE
+);
+CREATE TABLE categories (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT UNIQUE NOT NULL
+);
+PRAGMA user_version = 1;
+
+INSERT INTO users (id, username, email, password_hash) VALUES
+    ('u1', 'ann', 'ann@example.com', ''),
+    ('u2', 'ben', 'ben@example.com', ''),
+    ('u3', 'cat', 'cat@example.com', '');
+INSERT INTO posts (id, user_id, title, content) VALUES
+    (1, 'u1', 'first', 'content'),
+    (2, 'u2', 'second', 'content');
+INSERT INTO comments (id, user_id, post_id, content) VALUES
+    (1, 'u2', 1, 'comment'),
+    (2, 'u3', 1, 'comment'),
+    (3, 'u1', 2, 'comment');
+INSERT INTO replycomments (user_id, parent_comment_id, content) VALUES
+    ('u1', 1, 'reply'),
+    ('u3', 1, 'reply'),
+    ('u2', 3, 'reply');
+INSERT INTO likes (user_id, post_id, comment_id, type) VALUES
+    ('u1', 1, NULL, 'like'),
+    ('u2', 1, NULL, 'like'),
+    ('u3', 1, NULL, 'dislike'),
+    ('u3', 2, NULL, 'dislike'),
+    ('u1', NULL, 1, 'like'),
+    ('u3', NULL, 1, 'dislike'),
+    ('u2', NULL, 3, 'like'),
+    -- Rows naming both or neither target were never valid and are dropped
+    ('u2', 2, 3, 'like'),
+    ('u1', NULL, NULL, 'like');
+`
+
+type postCounts struct{ likes, dislikes, comments int }
+type commentCounts struct{ likes, dislikes, replies int }
+
+// Counts expected after migrating schemaV1
+var (
+	wantPostCounts    = map[int]postCounts{1: {2, 1, 2}, 2: {0, 1, 1}}
+	wantCommentCounts = map[int]commentCounts{1: {1, 1, 2}, 2: {0, 0, 0}, 3: {1, 0, 1}}
+)
+
+func TestMigrateFromVersion1(t *testing.T) {
+	tests := []struct {
+		name  string
+		setup string
+	}{
+		{"without counts", ""},
+		// A database from between versions 2 and 5 already stores correct
+		// counts, which copying its likes into the new table adds again
+		{"with stored counts", `
+			ALTER TABLE posts ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE posts ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE posts ADD COLUMN comment_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE comments ADD COLUMN like_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE comments ADD COLUMN dislike_count INTEGER NOT NULL DEFAULT 0;
+			ALTER TABLE comments ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+			UPDATE posts SET like_count = 2, dislike_count = 1, comment_count = 2 WHERE id = 1;
+			UPDATE posts SET dislike_count = 1, comment_count = 1 WHERE id = 2;
+			UPDATE comments SET like_count = 1, dislike_count = 1, reply_count = 2 WHERE id = 1;
+			UPDATE comments SET like_count = 1, reply_count = 1 WHERE id = 3;
+			PRAGMA user_version = 4;
+		`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "forum.db")
+			old, err := sql.Open(driverName, path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			mustExec(t, old, schemaV1)
+			if tt.setup != "" {
+				mustExec(t, old, tt.setup)
+			}
+			old.Close()
+
+			// A second start must leave the migrated database as it is
+			for start := range 2 {
+				if err := InitializeDatabase(path, "../schema.sql"); err != nil {
+					t.Fatalf("start %d: %v", start+1, err)
+				}
+				checkMigrated(t, DB)
+				CloseDatabase()
+			}
+		})
+	}
+}
+
+func checkMigrated(t *testing.T, db *sql.DB) {
+	t.Helper()
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Errorf("user_version = %d, want %d", version, SchemaVersion)
+	}
+	if columns, err := tableColumns(db, "likes_old"); err != nil || len(columns) != 0 {
+		t.Errorf("likes_old still exists (err %v)", err)
+	}
+
+	var likes int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM likes`).Scan(&likes); err != nil {
+		t.Fatal(err)
+	}
+	if likes != 7 {
+		t.Errorf("%d likes after migrating, want 7", likes)
+	}
+	var wrongTarget int
+	if err := db.QueryRow(`
+		SELECT COUNT(*) FROM likes
+		WHERE target_type != IIF(post_id IS NULL, 'comment', 'post')
+	`).Scan(&wrongTarget); err != nil {
+		t.Fatal(err)
+	}
+	if wrongTarget != 0 {
+		t.Errorf("%d likes have the wrong target_type", wrongTarget)
+	}
+
+	checkCounts(t, db, wantPostCounts, wantCommentCounts)
+}
+
+// checkCounts compares the stored counts with want
+func checkCounts(t *testing.T, db *sql.DB, wantPosts map[int]postCounts, wantComments map[int]commentCounts) {
+	t.Helper()
+	for id, want := range wantPosts {
+		var got postCounts
+		err := db.QueryRow(`SELECT like_count, dislike_count, comment_count FROM posts WHERE id = ?`, id).
+			Scan(&got.likes, &got.dislikes, &got.comments)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("post %d counts = %+v, want %+v", id, got, want)
+		}
+	}
+	for id, want := range wantComments {
+		var got commentCounts
+		err := db.QueryRow(`SELECT like_count, dislike_count, reply_count FROM comments WHERE id = ?`, id).
+			Scan(&got.likes, &got.dislikes, &got.replies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("comment %d counts = %+v, want %+v", id, got, want)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/models"
+)
+
+const dataExportColumns = `id, user_id, status, requested_at, expires_at`
+
+func scanDataExport(row interface{ Scan(...any) error }) (models.DataExport, error) {
+	var e models.DataExport
+	err := row.Scan(&e.ID, &e.UserID, &e.Status, &e.RequestedAt, &e.ExpiresAt)
+	return e, err
+}
+
+// CreateDataExport records a data export request
+func CreateDataExport(ctx context.Context, db *sql.DB, e models.DataExport) error {
+	ctx, end := track(ctx, "CreateDataExport")
+	defer end()
+	var expiresAt *time.Time
+	if e.ExpiresAt != nil {
+		t := e.ExpiresAt.UTC()
+		expiresAt = &t
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO data_exports (id, user_id, status, requested_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, e.ID, e.UserID, e.Status, e.RequestedAt.UTC(), expiresAt)
+	return err
+}
+
+// GetDataExport retrieves an export by the ID in its download link
+func GetDataExport(ctx context.Context, db *sql.DB, id string) (models.DataExport, error) {
+	ctx, end := track(ctx, "GetDataExport")
+	defer end()
+	return scanDataExport(db.QueryRowContext(ctx, `
+		SELECT `+dataExportColumns+` FROM data_exports WHERE id = ?
+	`, id))
+}
+
+// GetLatestDataExport retrieves userID's most recent export request
+func GetLatestDataExport(ctx context.Context, db *sql.DB, userID string) (models.DataExport, error) {
+	ctx, end := track(ctx, "GetLatestDataExport")
+	defer end()
+	return scanDataExport(db.QueryRowContext(ctx, `
+		SELECT `+dataExportColumns+` FROM data_exports
+		WHERE user_id = ?
+		ORDER BY requested_at DESC
+		LIMIT 1
+	`, userID))
+}
+
+// GetPendingDataExports returns the exports still waiting to be built, oldest first
+func GetPendingDataExports(ctx context.Context, db *sql.DB) ([]models.DataExport, error) {
+	ctx, end := track(ctx, "GetPendingDataExports")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT `+dataExportColumns+` FROM data_exports
+		WHERE status = 'pending'
+		ORDER BY requested_at
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var exports []models.DataExport
+	for rows.Next() {
+		e, err := scanDataExport(rows)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, e)
+	}
+	return exports, rows.Err()
+}
+
+// FinishDataExport marks an export "ready" or "failed". Either way the row
+// is deleted at expiresAt.
+func FinishDataExport(ctx context.Context, db *sql.DB, id, status string, expiresAt time.Time) error {
+	ctx, end := track(ctx, "FinishDataExport")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+		UPDATE data_exports SET status = ?, expires_at = ? WHERE id = ?
+	`, status, expiresAt.UTC(), id)
+	return err
+}
+
+// DeleteExpiredDataExports removes the exports that expired before now and
+// returns how many there were
+func DeleteExpiredDataExports(ctx context.Context, db *sql.DB, now time.Time) (int64, error) {
+	ctx, end := track(ctx, "DeleteExpiredDataExports")
+	defer end()
+	res, err := db.ExecContext(ctx, `DELETE FROM data_exports WHERE expires_at < ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// CountUserItems returns how many posts, post revisions, comments, replies,
+// reactions and saved posts userID has, as a measure of how big their data export is
+func CountUserItems(ctx context.Context, db *sql.DB, userID string) (int, error) {
+	ctx, end := track(ctx, "CountUserItems")
+	defer end()
+	var n int
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM posts WHERE user_id = ?1) +
+			(SELECT COUNT(*) FROM post_revisions WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?1)) +
+			(SELECT COUNT(*) FROM comments WHERE user_id = ?1) +
+			(SELECT COUNT(*) FROM replycomments WHERE user_id = ?1) +
+			(SELECT COUNT(*) FROM likes WHERE user_id = ?1) +
+			(SELECT COUNT(*) FROM saved_posts WHERE user_id = ?1)
+	`, userID).Scan(&n)
+	return n, err
+}
+
+// GetSessionTimes returns when each of userID's current sessions was
+// created, newest first
+func GetSessionTimes(ctx context.Context, db *sql.DB, userID string) ([]time.Time, error) {
+	ctx, end := track(ctx, "GetSessionTimes")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT created_at FROM sessions WHERE user_id = ? ORDER BY created_at DESC
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	times := []time.Time{}
+	for rows.Next() {
+		var t time.Time
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		times = append(times, t)
+	}
+	return times, rows.Err()
+}
+
+// GetUserPostRevisions returns one page of the earlier versions of userID's
+// posts, grouped by post and oldest first
+func GetUserPostRevisions(ctx context.Context, db *sql.DB, userID string, page, limit int) ([]models.PostRevision, error) {
+	ctx, end := track(ctx, "GetUserPostRevisions")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT r.post_id, p.title, r.title, r.content, r.created_at, r.replaced_at
+		FROM post_revisions r
+		JOIN posts p ON p.id = r.post_id
+		WHERE p.user_id = ?
+		ORDER BY r.post_id, r.id
+		LIMIT ? OFFSET ?
+	`, userID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.PostRevision{}
+	for rows.Next() {
+		var r models.PostRevision
+		if err := rows.Scan(&r.PostID, &r.PostTitle, &r.Title, &r.Content, &r.CreatedAt, &r.ReplacedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
@@ -0,0 +1,53 @@
+package sqlite
+
+import (
+	"database/sql"
+	"math"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is the go-sqlite3 driver with the ranking functions below
+// registered on every connection, so queries can ORDER BY them. Foreign
+// keys are enabled per connection too, since the pool opens new ones as
+// it needs them and cascading deletes must work on all of them.
+const driverName = "sqlite3_forum"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			if _, err := conn.Exec("PRAGMA foreign_keys = ON", nil); err != nil {
+				return err
+			}
+			if err := conn.RegisterFunc("wilson_lower_bound", WilsonLowerBound, true); err != nil {
+				return err
+			}
+			return conn.RegisterFunc("controversy", Controversy, true)
+		},
+	})
+}
+
+// WilsonLowerBound is the lower bound of the 95% Wilson score interval for
+// the share of likes among all votes. It ranks 50 likes out of 60 above
+// 3 out of 3, because the larger sample is more certain.
+func WilsonLowerBound(likes, dislikes int64) float64 {
+	n := float64(likes + dislikes)
+	if n == 0 {
+		return 0
+	}
+	const z = 1.96
+	p := float64(likes) / n
+	return (p + z*z/(2*n) - z*math.Sqrt((p*(1-p)+z*z/(4*n))/n)) / (1 + z*z/n)
+}
+
+// Controversy is high when a post has many votes split evenly between likes
+// and dislikes (Reddit style): the vote count raised to the ratio of the
+// smaller side to the larger. One-sided votes score 0.
+func Controversy(likes, dislikes int64) float64 {
+	if likes <= 0 || dislikes <= 0 {
+		return 0
+	}
+	magnitude := float64(likes + dislikes)
+	balance := float64(min(likes, dislikes)) / float64(max(likes, dislikes))
+	return math.Pow(magnitude, balance)
+}
@@ -0,0 +1,82 @@
+package sqlite
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWilsonLowerBound(t *testing.T) {
+	tests := []struct {
+		likes, dislikes int64
+		want            float64
+	}{
+		{0, 0, 0},
+		{0, 5, 0},
+		{1, 0, 0.20654},
+		{3, 0, 0.43850},
+		{5, 5, 0.23659},
+		{50, 10, 0.71968},
+	}
+	for _, tt := range tests {
+		if got := WilsonLowerBound(tt.likes, tt.dislikes); math.Abs(got-tt.want) > 1e-5 {
+			t.Errorf("WilsonLowerBound(%d, %d) = %.5f, want %.5f", tt.likes, tt.dislikes, got, tt.want)
+		}
+	}
+
+	// More evidence for the same share of likes ranks higher
+	if WilsonLowerBound(50, 10) <= WilsonLowerBound(5, 1) {
+		t.Error("50 likes of 60 should rank above 5 of 6")
+	}
+	if WilsonLowerBound(50, 10) <= WilsonLowerBound(3, 0) {
+		t.Error("50 likes of 60 should rank above 3 of 3")
+	}
+
+	for likes := range int64(20) {
+		for dislikes := range int64(20) {
+			got := WilsonLowerBound(likes, dislikes)
+			if likes+dislikes == 0 {
+				continue
+			}
+			share := float64(likes) / float64(likes+dislikes)
+			if got < -1e-12 || got > share+1e-12 {
+				t.Errorf("WilsonLowerBound(%d, %d) = %f, outside 0 to the like share %f", likes, dislikes, got, share)
+			}
+			if next := WilsonLowerBound(likes+1, dislikes); next <= got {
+				t.Errorf("another like lowered the score of (%d, %d)", likes, dislikes)
+			}
+			if next := WilsonLowerBound(likes, dislikes+1); next > got+1e-12 {
+				t.Errorf("another dislike raised the score of (%d, %d)", likes, dislikes)
+			}
+		}
+	}
+}
+
+func TestControversy(t *testing.T) {
+	tests := []struct {
+		likes, dislikes int64
+		want            float64
+	}{
+		{0, 0, 0},
+		{7, 0, 0},
+		{0, 7, 0},
+		{1, 1, 2},
+		{5, 5, 10},
+		{10, 5, math.Sqrt(15)},
+		{5, 10, math.Sqrt(15)},
+		{-1, 3, 0},
+	}
+	for _, tt := range tests {
+		if got := Controversy(tt.likes, tt.dislikes); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("Controversy(%d, %d) = %f, want %f", tt.likes, tt.dislikes, got, tt.want)
+		}
+	}
+
+	// An even split ranks above a lopsided one with as many votes, and more
+	// votes split evenly rank higher still
+	if Controversy(10, 10) <= Controversy(15, 5) {
+		t.Error("10/10 should be more controversial than 15/5")
+	}
+	if Controversy(20, 20) <= Controversy(10, 10) {
+		t.Error("20/20 should be more controversial than 10/10")
+	}
+}
@@ -0,0 +1,34 @@
+package sqlite
+
+import (
+	"context"
+	"time"
+
+	"forum/metrics"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("forum/sqlite")
+
+// track starts a span for a query function and records how long it took.
+// Use as
+//
+//	ctx, end := track(ctx, "FunctionName")
+//	defer end()
+func track(ctx context.Context, function string) (context.Context, func()) {
+	start := time.Now()
+	ctx, span := tracer.Start(ctx, "sqlite."+function,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			attribute.String("db.system", "sqlite"),
+			attribute.String("code.function", function),
+		),
+	)
+	return ctx, func() {
+		metrics.QueryDuration.WithLabelValues(function).Observe(time.Since(start).Seconds())
+		span.End()
+	}
+}
@@ -0,0 +1,123 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/models"
+)
+
+// StartJobRun records that a job has started and returns the run ID
+func StartJobRun(ctx context.Context, db *sql.DB, name string, startedAt time.Time) (int64, error) {
+	ctx, end := track(ctx, "StartJobRun")
+	defer end()
+	res, err := db.ExecContext(ctx, `
+		INSERT INTO jobs (name, status, started_at) VALUES (?, 'running', ?)
+	`, name, startedAt)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+// FinishJobRun stores the outcome of a job run
+func FinishJobRun(ctx context.Context, db *sql.DB, runID int64, status, errMsg string, finishedAt time.Time) error {
+	ctx, end := track(ctx, "FinishJobRun")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+		UPDATE jobs SET status = ?, error = ?, finished_at = ? WHERE id = ?
+	`, status, errMsg, finishedAt, runID)
+	return err
+}
+
+// RecordSkippedJobRun records a run that did not start because the previous one was still going
+func RecordSkippedJobRun(ctx context.Context, db *sql.DB, name string, at time.Time) error {
+	ctx, end := track(ctx, "RecordSkippedJobRun")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO jobs (name, status, error, started_at, finished_at)
+		VALUES (?, 'skipped', 'previous run still in progress', ?, ?)
+	`, name, at, at)
+	return err
+}
+
+// MarkInterruptedJobRuns fails runs left as running by a previous process
+func MarkInterruptedJobRuns(ctx context.Context, db *sql.DB) error {
+	ctx, end := track(ctx, "MarkInterruptedJobRuns")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+		UPDATE jobs SET status = 'failed', error = 'interrupted by shutdown', finished_at = ?
+		WHERE status = 'running'
+	`, time.Now())
+	return err
+}
+
+// PruneJobRuns deletes run history older than the given time
+func PruneJobRuns(ctx context.Context, db *sql.DB, before time.Time) error {
+	ctx, end := track(ctx, "PruneJobRuns")
+	defer end()
+	_, err := db.ExecContext(ctx, `DELETE FROM jobs WHERE started_at < ?`, before)
+	return err
+}
+
+// GetJobRuns returns the most recent runs of a job, newest first
+func GetJobRuns(ctx context.Context, db *sql.DB, name string, limit int) ([]models.JobRun, error) {
+	ctx, end := track(ctx, "GetJobRuns")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, status, error, started_at, finished_at
+		FROM jobs WHERE name = ?
+		ORDER BY started_at DESC, id DESC
+		LIMIT ?
+	`, name, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []models.JobRun{}
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetLatestJobRuns returns the newest run of every job, keyed by job name
+func GetLatestJobRuns(ctx context.Context, db *sql.DB) (map[string]models.JobRun, error) {
+	ctx, end := track(ctx, "GetLatestJobRuns")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, name, status, error, started_at, finished_at
+		FROM jobs
+		WHERE id IN (SELECT MAX(id) FROM jobs GROUP BY name)
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	latest := make(map[string]models.JobRun)
+	for rows.Next() {
+		run, err := scanJobRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		latest[run.Name] = run
+	}
+	return latest, rows.Err()
+}
+
+func scanJobRun(rows *sql.Rows) (models.JobRun, error) {
+	var run models.JobRun
+	var finishedAt sql.NullTime
+	err := rows.Scan(&run.ID, &run.Name, &run.Status, &run.Error, &run.StartedAt, &finishedAt)
+	if finishedAt.Valid {
+		run.FinishedAt = &finishedAt.Time
+	}
+	return run, err
+}
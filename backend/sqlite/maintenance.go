@@ -0,0 +1,190 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"math"
+	"time"
+)
+
+// GetUploadURLs returns every avatar and post image URL still referenced by a row
+func GetUploadURLs(ctx context.Context, db *sql.DB) (map[string]bool, error) {
+	ctx, end := track(ctx, "GetUploadURLs")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT avatar_url FROM users WHERE avatar_url IS NOT NULL AND avatar_url != ''
+		UNION
+		SELECT image_url FROM posts WHERE image_url IS NOT NULL AND image_url != ''
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	urls := make(map[string]bool)
+	for rows.Next() {
+		var url string
+		if err := rows.Scan(&url); err != nil {
+			return nil, err
+		}
+		urls[url] = true
+	}
+	return urls, rows.Err()
+}
+
+// Recount recomputes the like, dislike, comment and reply counts on posts
+// and comments from the source tables, and returns how many rows of each
+// were out of date. The schema triggers keep the counts current; this
+// repairs them after manual edits or a restore.
+func Recount(ctx context.Context, db *sql.DB) (posts, comments int64, err error) {
+	ctx, end := track(ctx, "Recount")
+	defer end()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, `
+		WITH actual AS (
+			SELECT
+				p.id,
+				(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.type = 'like') AS likes,
+				(SELECT COUNT(*) FROM likes l WHERE l.post_id = p.id AND l.type = 'dislike') AS dislikes,
+				(SELECT COUNT(*) FROM comments c WHERE c.post_id = p.id) AS comments
+			FROM posts p
+		)
+		UPDATE posts SET
+			like_count = actual.likes,
+			dislike_count = actual.dislikes,
+			comment_count = actual.comments
+		FROM actual
+		WHERE actual.id = posts.id
+			AND (like_count != actual.likes OR dislike_count != actual.dislikes OR comment_count != actual.comments)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	if posts, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	res, err = tx.ExecContext(ctx, `
+		WITH actual AS (
+			SELECT
+				c.id,
+				(SELECT COUNT(*) FROM likes l WHERE l.comment_id = c.id AND l.type = 'like') AS likes,
+				(SELECT COUNT(*) FROM likes l WHERE l.comment_id = c.id AND l.type = 'dislike') AS dislikes,
+				(SELECT COUNT(*) FROM replycomments r WHERE r.parent_comment_id = c.id) AS replies
+			FROM comments c
+		)
+		UPDATE comments SET
+			like_count = actual.likes,
+			dislike_count = actual.dislikes,
+			reply_count = actual.replies
+		FROM actual
+		WHERE actual.id = comments.id
+			AND (like_count != actual.likes OR dislike_count != actual.dislikes OR reply_count != actual.replies)
+	`)
+	if err != nil {
+		return 0, 0, err
+	}
+	if comments, err = res.RowsAffected(); err != nil {
+		return 0, 0, err
+	}
+
+	return posts, comments, tx.Commit()
+}
+
+// hotScore ranks a post by net votes plus comments and replies, decayed by age (Hacker News style)
+func hotScore(likes, dislikes, comments, replies int, age time.Duration) float64 {
+	points := float64(likes - dislikes + comments + replies)
+	hours := math.Max(age.Hours(), 0)
+	return points / math.Pow(hours+2, 1.8)
+}
+
+// RecomputeTrendingScores rebuilds the trending_scores and
+// trending_category_scores caches and returns the number of posts scored
+func RecomputeTrendingScores(ctx context.Context, db *sql.DB, now time.Time) (int, error) {
+	ctx, end := track(ctx, "RecomputeTrendingScores")
+	defer end()
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			p.id,
+			p.created_at,
+			p.like_count,
+			p.dislike_count,
+			p.comment_count,
+			(SELECT COALESCE(SUM(c.reply_count), 0) FROM comments c WHERE c.post_id = p.id)
+		FROM posts p
+	`)
+	if err != nil {
+		return 0, err
+	}
+
+	type score struct {
+		postID int
+		value  float64
+	}
+	var scores []score
+	for rows.Next() {
+		var postID, likes, dislikes, comments, replies int
+		var createdAt time.Time
+		if err := rows.Scan(&postID, &createdAt, &likes, &dislikes, &comments, &replies); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		scores = append(scores, score{postID, hotScore(likes, dislikes, comments, replies, now.Sub(createdAt))})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trending_scores`); err != nil {
+		return 0, err
+	}
+	stmt, err := tx.PrepareContext(ctx, `INSERT INTO trending_scores (post_id, score, computed_at) VALUES (?, ?, ?)`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	for _, s := range scores {
+		if _, err := stmt.ExecContext(ctx, s.postID, s.value, now); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trending_category_scores`); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO trending_category_scores (category_id, score, post_count, computed_at)
+		SELECT pc.category_id, SUM(t.score), COUNT(*), ?
+		FROM post_categories pc
+		JOIN trending_scores t ON t.post_id = pc.post_id
+		GROUP BY pc.category_id
+	`, now); err != nil {
+		return 0, err
+	}
+	return len(scores), tx.Commit()
+}
+
+// Optimize refreshes query planner statistics and compacts the database file
+func Optimize(ctx context.Context, db *sql.DB) error {
+	ctx, end := track(ctx, "Optimize")
+	defer end()
+	if _, err := db.ExecContext(ctx, `PRAGMA optimize`); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `VACUUM`)
+	return err
+}
@@ -0,0 +1,305 @@
+package sqlite
+
+import (
+	"context"
+	"math"
+	"slices"
+	"testing"
+	"time"
+)
+
+// TestCountsMatchRecount runs the writes that change stored counts and
+// checks after each one that Recount finds nothing to repair
+func TestCountsMatchRecount(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash) VALUES
+			('u1', 'ann', 'ann@example.com', ''),
+			('u2', 'ben', 'ben@example.com', ''),
+			('u3', 'cat', 'cat@example.com', '');
+		INSERT INTO reaction_types (name, emoji, position) VALUES ('thanks', '🙏', 2);
+	`)
+
+	post1, err := CreatePost(ctx, db, "u1", nil, "first", "content", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	post2, err := CreatePost(ctx, db, "u2", nil, "second", "content", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var commentIDs, replyIDs []int
+	for i, userID := range []string{"u2", "u3", "u1"} {
+		postID := post1.ID
+		if i == 2 {
+			postID = post2.ID
+		}
+		c, err := CreateComment(ctx, db, userID, postID, "comment")
+		if err != nil {
+			t.Fatal(err)
+		}
+		commentIDs = append(commentIDs, c.ID)
+		for _, replier := range []string{"u1", "u3"} {
+			r, err := CreateReplyComment(ctx, db, replier, c.ID, "reply")
+			if err != nil {
+				t.Fatal(err)
+			}
+			replyIDs = append(replyIDs, r.ID)
+		}
+	}
+
+	toggle := func(userID, target string, id int, reaction string) func() error {
+		return func() error { return ToggleLike(ctx, db, userID, target, id, reaction) }
+	}
+	exec := func(query string, args ...any) func() error {
+		return func() error {
+			_, err := db.ExecContext(ctx, query, args...)
+			return err
+		}
+	}
+	steps := []struct {
+		name string
+		run  func() error
+	}{
+		{"like post", toggle("u1", "post", post1.ID, "like")},
+		{"like post again", toggle("u2", "post", post1.ID, "like")},
+		{"dislike post", toggle("u3", "post", post1.ID, "dislike")},
+		{"switch like to dislike", toggle("u2", "post", post1.ID, "dislike")},
+		{"undo like", toggle("u1", "post", post1.ID, "like")},
+		{"other reaction type", toggle("u1", "post", post1.ID, "thanks")},
+		{"like comment", toggle("u1", "comment", commentIDs[0], "like")},
+		{"dislike comment", toggle("u2", "comment", commentIDs[0], "dislike")},
+		{"like second comment", toggle("u3", "comment", commentIDs[1], "like")},
+		{"switch comment dislike to like", toggle("u2", "comment", commentIDs[0], "like")},
+		{"undo comment like", toggle("u2", "comment", commentIDs[0], "like")},
+		{"check reaction counts", func() error {
+			checkCounts(t, db,
+				map[int]postCounts{post1.ID: {0, 2, 2}},
+				map[int]commentCounts{commentIDs[0]: {1, 0, 2}, commentIDs[1]: {1, 0, 2}})
+			return nil
+		}},
+		{"like reply", toggle("u2", "reply", replyIDs[0], "like")},
+		{"dislike reply", toggle("u3", "reply", replyIDs[1], "dislike")},
+		{"add comment", func() error {
+			_, err := CreateComment(ctx, db, "u3", post2.ID, "late comment")
+			return err
+		}},
+		{"add reply", func() error {
+			_, err := CreateReplyComment(ctx, db, "u2", commentIDs[1], "late reply")
+			return err
+		}},
+		{"delete reply", exec(`DELETE FROM replycomments WHERE id = ?`, replyIDs[0])},
+		{"delete liked reply", exec(`DELETE FROM replycomments WHERE id = ?`, replyIDs[1])},
+		{"delete comment with replies and reactions", func() error { return DeleteComment(ctx, db, commentIDs[0]) }},
+		{"change reaction in place", exec(`UPDATE likes SET type = 'dislike' WHERE comment_id = ? AND type = 'like'`, commentIDs[1])},
+		{"delete account", func() error {
+			mustExec(t, db, `INSERT INTO account_deletions (user_id, mode, requested_at) VALUES ('u3', 'delete', ?)`, time.Now().UTC())
+			_, err := DeleteDueAccounts(ctx, db, time.Now())
+			return err
+		}},
+		{"anonymise account", func() error {
+			mustExec(t, db, `INSERT INTO account_deletions (user_id, mode, requested_at) VALUES ('u2', 'anonymize', ?)`, time.Now().UTC())
+			_, err := DeleteDueAccounts(ctx, db, time.Now())
+			return err
+		}},
+		{"delete post", func() error { return DeletePost(ctx, db, post2.ID) }},
+	}
+
+	for _, step := range steps {
+		if err := step.run(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		posts, comments, err := Recount(ctx, db)
+		if err != nil {
+			t.Fatalf("%s: recount: %v", step.name, err)
+		}
+		if posts != 0 || comments != 0 {
+			t.Errorf("after %s, recount repaired %d posts and %d comments", step.name, posts, comments)
+		}
+	}
+
+	// One of post1's comments was deleted and the other went with its
+	// author's account, as did the reactions on it
+	checkCounts(t, db, map[int]postCounts{post1.ID: {0, 0, 0}}, nil)
+}
+
+func TestRecountRepairsCounts(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash) VALUES ('u1', 'ann', 'ann@example.com', '');
+		INSERT INTO posts (id, user_id, title, content) VALUES (1, 'u1', 'title', 'content'), (2, 'u1', 'title', 'content');
+		INSERT INTO comments (id, user_id, post_id, content) VALUES (1, 'u1', 1, 'comment');
+		INSERT INTO likes (user_id, target_type, post_id, type) VALUES ('u1', 'post', 1, 'like');
+		UPDATE posts SET like_count = 7, dislike_count = 3, comment_count = 0 WHERE id = 1;
+		UPDATE comments SET reply_count = 4 WHERE id = 1;
+	`)
+
+	posts, comments, err := Recount(ctx, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if posts != 1 || comments != 1 {
+		t.Errorf("recount repaired %d posts and %d comments, want 1 and 1", posts, comments)
+	}
+	checkCounts(t, db,
+		map[int]postCounts{1: {1, 0, 1}, 2: {0, 0, 0}},
+		map[int]commentCounts{1: {0, 0, 0}})
+}
+
+func TestHotScore(t *testing.T) {
+	tests := []struct {
+		likes, dislikes, comments, replies int
+		age                                time.Duration
+		want                               float64
+	}{
+		{0, 0, 0, 0, 0, 0},
+		{10, 0, 0, 0, 0, 2.8717458874925876},
+		{10, 0, 0, 0, 22 * time.Hour, 0.032780816364406315},
+		// Comments and replies count like votes, dislikes against them
+		{4, 2, 3, 2, 46 * time.Hour, 0.0065896722208193485},
+		{1, 4, 0, 0, 2 * time.Hour, -0.24740773326991766},
+		// Posts dated in the future score as brand new
+		{10, 0, 0, 0, -time.Hour, 2.8717458874925876},
+	}
+	for _, tt := range tests {
+		got := hotScore(tt.likes, tt.dislikes, tt.comments, tt.replies, tt.age)
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("hotScore(%d, %d, %d, %d, %s) = %v, want %v", tt.likes, tt.dislikes, tt.comments, tt.replies, tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestRecomputeTrendingScores(t *testing.T) {
+	ctx := context.Background()
+	db := openDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	ago := func(d time.Duration) string { return now.Add(-d).Format(time.DateTime) }
+	day := 24 * time.Hour
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash) VALUES ('u1', 'ann', 'ann@example.com', '');
+		INSERT INTO categories (id, name) VALUES (1, 'fresh'), (2, 'old'), (3, 'disliked'), (4, 'empty');
+		INSERT INTO posts (id, user_id, title, content, created_at) VALUES
+			(1, 'u1', 'an hour old', '', ?),
+			(2, 'u1', 'three days old', '', ?),
+			(3, 'u1', 'ten days old', '', ?),
+			(4, 'u1', 'disliked', '', ?),
+			(5, 'u1', 'forty days old', '', ?);
+		INSERT INTO post_categories (post_id, category_id) VALUES (1, 1), (2, 1), (3, 2), (5, 2), (4, 3);
+		INSERT INTO comments (id, user_id, post_id, content) VALUES (1, 'u1', 2, ''), (2, 'u1', 2, '');
+		UPDATE comments SET reply_count = 2 WHERE id IN (1, 2);
+		UPDATE posts SET like_count = 5 WHERE id = 1;
+		UPDATE posts SET like_count = 50, dislike_count = 2, comment_count = 3 WHERE id = 2;
+		UPDATE posts SET like_count = 100 WHERE id = 3;
+		UPDATE posts SET like_count = 1, dislike_count = 4 WHERE id = 4;
+		UPDATE posts SET like_count = 1000 WHERE id = 5;
+	`, ago(time.Hour), ago(3*day), ago(10*day), ago(2*time.Hour), ago(40*day))
+
+	n, err := RecomputeTrendingScores(ctx, db, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 5 {
+		t.Errorf("scored %d posts, want 5", n)
+	}
+	want := map[int]float64{
+		1: hotScore(5, 0, 0, 0, time.Hour),
+		2: hotScore(50, 2, 3, 4, 3*day),
+		3: hotScore(100, 0, 0, 0, 10*day),
+		4: hotScore(1, 4, 0, 0, 2*time.Hour),
+		5: hotScore(1000, 0, 0, 0, 40*day),
+	}
+	rows, err := db.Query(`SELECT post_id, score FROM trending_scores`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[int]float64{}
+	for rows.Next() {
+		var id int
+		var score float64
+		if err := rows.Scan(&id, &score); err != nil {
+			t.Fatal(err)
+		}
+		got[id] = score
+	}
+	rows.Close()
+	if len(got) != len(want) {
+		t.Errorf("scores = %v, want %v", got, want)
+	}
+	for id, score := range want {
+		if math.Abs(got[id]-score) > 1e-12 {
+			t.Errorf("post %d scored %v, want %v", id, got[id], score)
+		}
+	}
+
+	// A post created since the recompute ranks with score 0, above the
+	// disliked post
+	mustExec(t, db, `INSERT INTO posts (id, user_id, title, content, created_at) VALUES (6, 'u1', 'new', '', ?)`, ago(0))
+	for _, tt := range []struct {
+		window time.Duration
+		want   []int
+	}{
+		{0, []int{1, 2, 3, 5, 6, 4}},
+		{30 * day, []int{1, 2, 3, 6, 4}},
+		{7 * day, []int{1, 2, 6, 4}},
+		{day, []int{1, 6, 4}},
+	} {
+		var since time.Time
+		if tt.window > 0 {
+			since = now.Add(-tt.window)
+		}
+		trends, err := GetTrendingPosts(ctx, db, since, 1, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []int
+		for _, trend := range trends {
+			ids = append(ids, trend.ID)
+		}
+		if !slices.Equal(ids, tt.want) {
+			t.Errorf("trending within %s = %v, want %v", tt.window, ids, tt.want)
+		}
+	}
+	if trends, err := GetTrendingPosts(ctx, db, time.Time{}, 2, 4); err != nil || len(trends) != 2 || trends[0].ID != 6 {
+		t.Errorf("second page of 4 = %+v, %v, want posts 6 and 4", trends, err)
+	}
+
+	type categoryRank struct {
+		id, posts int
+		score     float64
+	}
+	checkCategories := func(want []categoryRank) {
+		t.Helper()
+		categories, err := GetTrendingCategories(ctx, db, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(categories) != len(want) {
+			t.Fatalf("categories = %+v, want %+v", categories, want)
+		}
+		for i, c := range categories {
+			if c.ID != want[i].id || c.PostCount != want[i].posts || math.Abs(c.Score-want[i].score) > 1e-12 {
+				t.Errorf("category %d = %+v, want %+v", i, c, want[i])
+			}
+		}
+	}
+	// Categories rank by the summed scores of their posts; one without
+	// posts is left out
+	checkCategories([]categoryRank{
+		{1, 2, want[1] + want[2]},
+		{2, 2, want[3] + want[5]},
+		{3, 1, want[4]},
+	})
+
+	// Recomputing replaces the previous scores
+	mustExec(t, db, `DELETE FROM posts WHERE id IN (1, 3)`)
+	if n, err := RecomputeTrendingScores(ctx, db, now); err != nil || n != 4 {
+		t.Errorf("recompute scored %d posts, %v, want 4", n, err)
+	}
+	checkCategories([]categoryRank{
+		{1, 1, want[2]},
+		{2, 1, want[5]},
+		{3, 1, want[4]},
+	})
+}
@@ -0,0 +1,97 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"forum/models"
+)
+
+// GetProfile retrieves the public profile of the user called username,
+// with their activity counts. It returns sql.ErrNoRows for unknown users.
+func GetProfile(ctx context.Context, db *sql.DB, username string) (models.Profile, error) {
+	ctx, end := track(ctx, "GetProfile")
+	defer end()
+
+	var p models.Profile
+	err := db.QueryRowContext(ctx, `
+		SELECT
+			u.id, u.username, u.avatar_url, u.bio, u.created_at,
+			(SELECT COUNT(*) FROM posts WHERE user_id = u.id),
+			(SELECT COUNT(*) FROM comments WHERE user_id = u.id)
+				+ (SELECT COUNT(*) FROM replycomments WHERE user_id = u.id),
+			(SELECT COUNT(*)
+				FROM likes l
+				JOIN reaction_types t ON t.name = l.type
+				LEFT JOIN posts p ON p.id = l.post_id
+				LEFT JOIN comments c ON c.id = l.comment_id
+				LEFT JOIN replycomments r ON r.id = l.reply_id
+				WHERE t.enabled AND COALESCE(p.user_id, c.user_id, r.user_id) = u.id)
+		FROM users u
+		WHERE u.username = ?
+	`, username).Scan(
+		&p.ID, &p.Username, &p.AvatarURL, &p.Bio, &p.CreatedAt,
+		&p.PostCount,
+		&p.CommentCount,
+		&p.ReactionsReceived,
+	)
+	return p, err
+}
+
+// GetUserComments returns the comments and replies userID wrote, most
+// recent first, with the post each belongs to
+func GetUserComments(ctx context.Context, db *sql.DB, userID string, page, limit int) ([]models.UserComment, error) {
+	ctx, end := track(ctx, "GetUserComments")
+	defer end()
+	offset := (page - 1) * limit
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT id, type, post_id, post_title, content, created_at
+		FROM (
+			SELECT c.id, 'comment' AS type, p.id AS post_id, p.title AS post_title, c.content, c.created_at
+			FROM comments c
+			JOIN posts p ON p.id = c.post_id
+			WHERE c.user_id = ?
+			UNION ALL
+			SELECT r.id, 'reply', p.id, p.title, r.content, r.created_at
+			FROM replycomments r
+			JOIN comments c ON c.id = r.parent_comment_id
+			JOIN posts p ON p.id = c.post_id
+			WHERE r.user_id = ?
+		)
+		ORDER BY created_at DESC, type, id DESC
+		LIMIT ? OFFSET ?
+	`, userID, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := []models.UserComment{}
+	for rows.Next() {
+		var c models.UserComment
+		if err := rows.Scan(&c.ID, &c.Type, &c.PostID, &c.PostTitle, &c.Content, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+	return comments, rows.Err()
+}
+
+// UpdateUser saves the username, email and bio of user
+func UpdateUser(ctx context.Context, db *sql.DB, user models.User) error {
+	ctx, end := track(ctx, "UpdateUser")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+		UPDATE users SET username = ?, email = ?, bio = ? WHERE id = ?
+	`, user.Username, user.Email, user.Bio, user.ID)
+	return err
+}
+
+// UpdateAvatar sets the avatar URL of userID
+func UpdateAvatar(ctx context.Context, db *sql.DB, userID, avatarURL string) error {
+	ctx, end := track(ctx, "UpdateAvatar")
+	defer end()
+	_, err := db.ExecContext(ctx, `UPDATE users SET avatar_url = ? WHERE id = ?`, avatarURL, userID)
+	return err
+}
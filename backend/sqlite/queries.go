@@ -1,7 +1,10 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -13,10 +16,12 @@ import (
 )
 
 // GetUserByUsername retrieves a user by username
-func GetUserByUsername(db *sql.DB, username string) (models.User, error) {
+func GetUserByUsername(ctx context.Context, db *sql.DB, username string) (models.User, error) {
+	ctx, end := track(ctx, "GetUserByUsername")
+	defer end()
 	var user models.User
-	err := db.QueryRow(`
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+	err := db.QueryRowContext(ctx, `
+		SELECT id, username, email, password_hash, avatar_url, bio, created_at, updated_at
 		FROM users WHERE username = ?
 	`, username).Scan(
 		&user.ID,
@@ -24,6 +29,7 @@ func GetUserByUsername(db *sql.DB, username string) (models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Bio,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -34,10 +40,12 @@ func GetUserByUsername(db *sql.DB, username string) (models.User, error) {
 }
 
 // CreateUser inserts a new user into the database
-func CreateUser(db *sql.DB, username, email, passwordHash, avatarURL string) error {
+func CreateUser(ctx context.Context, db *sql.DB, username, email, passwordHash, avatarURL string) error {
+	ctx, end := track(ctx, "CreateUser")
+	defer end()
 	userID := uuid.New().String()
 
-	_, err := db.Exec(`
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO users (id, username, email, password_hash, avatar_url)
 		VALUES (?, ?, ?, ?, ?)
 	`, userID, username, email, passwordHash, avatarURL)
@@ -46,7 +54,9 @@ func CreateUser(db *sql.DB, username, email, passwordHash, avatarURL string) err
 }
 
 // CreatePost inserts a new post and its category associations
-func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, imageURL string) (models.Post, error) {
+func CreatePost(ctx context.Context, db *sql.DB, userID string, categoryIDs []int, title, content, imageURL string) (models.Post, error) {
+	ctx, end := track(ctx, "CreatePost")
+	defer end()
 	var post models.Post
 
 	// Insert into posts table
@@ -55,7 +65,7 @@ func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, im
 		VALUES (?, ?, ?, ?)
 		RETURNING id, user_id, title, content, image_url, created_at
 	`
-	err := db.QueryRow(query, userID, title, content, imageURL).Scan(
+	err := db.QueryRowContext(ctx, query, userID, title, content, imageURL).Scan(
 		&post.ID,
 		&post.UserID,
 		&post.Title,
@@ -69,7 +79,7 @@ func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, im
 
 	// Insert into post_categories table
 	for _, catID := range categoryIDs {
-		_, err := db.Exec(`INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, post.ID, catID)
+		_, err := db.ExecContext(ctx, `INSERT INTO post_categories (post_id, category_id) VALUES (?, ?)`, post.ID, catID)
 		if err != nil {
 			return post, fmt.Errorf("failed to insert into post_categories: %w", err)
 		}
@@ -80,12 +90,14 @@ func CreatePost(db *sql.DB, userID string, categoryIDs []int, title, content, im
 }
 
 // GetPost retrieves a single post by ID with its category IDs
-func GetPost(db *sql.DB, postID int) (models.Post, error) {
+func GetPost(ctx context.Context, db *sql.DB, postID int) (models.Post, error) {
+	ctx, end := track(ctx, "GetPost")
+	defer end()
 	var post models.Post
 
 	// Fetch main post data
-	err := db.QueryRow(`
-        SELECT id, user_id, title, content, image_url, created_at, updated_at
+	err := db.QueryRowContext(ctx, `
+        SELECT id, user_id, title, content, image_url, comment_count, created_at, updated_at
         FROM posts WHERE id = ?
     `, postID).Scan(
 		&post.ID,
@@ -93,6 +105,7 @@ func GetPost(db *sql.DB, postID int) (models.Post, error) {
 		&post.Title,
 		&post.Content,
 		&post.ImageURL,
+		&post.CommentCount,
 		&post.CreatedAt,
 		&post.UpdatedAt,
 	)
@@ -101,7 +114,7 @@ func GetPost(db *sql.DB, postID int) (models.Post, error) {
 	}
 
 	// Fetch category IDs from join table
-	rows, err := db.Query(`SELECT category_id FROM post_categories WHERE post_id = ?`, postID)
+	rows, err := db.QueryContext(ctx, `SELECT category_id FROM post_categories WHERE post_id = ?`, postID)
 	if err != nil {
 		return post, err
 	}
@@ -118,32 +131,129 @@ func GetPost(db *sql.DB, postID int) (models.Post, error) {
 	return post, nil
 }
 
-func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
-	offset := (page - 1) * limit
+// PostSorts are the orders GetPosts accepts. Each maps to a numeric sort key
+// and whether larger keys come first; ties are broken by post ID in the
+// same direction so every order is total.
+var PostSorts = map[string]struct {
+	key  string
+	desc bool
+}{
+	"new":           {"unixepoch(p.created_at)", true},
+	"oldest":        {"unixepoch(p.created_at)", false},
+	"top":           {"wilson_lower_bound(p.like_count, p.dislike_count)", true},
+	"controversial": {"controversy(p.like_count, p.dislike_count)", true},
+	// The latest of the post, its newest comment and its newest reply
+	"active": {`MAX(
+		unixepoch(p.created_at),
+		COALESCE((SELECT unixepoch(MAX(c.created_at)) FROM comments c WHERE c.post_id = p.id), 0),
+		COALESCE((SELECT unixepoch(MAX(r.created_at)) FROM replycomments r JOIN comments c ON c.id = r.parent_comment_id WHERE c.post_id = p.id), 0)
+	)`, true},
+}
+
+// PostListOptions selects one page of posts
+type PostListOptions struct {
+	// Sort is a key of PostSorts; empty means "new"
+	Sort string
+	// Since skips posts created before it; zero includes every post
+	Since time.Time
+	// AuthorID, when set, only includes that user's posts
+	AuthorID string
+	Page     int
+	Limit    int
+	// After continues from the last post of a previous page. Unlike Page it
+	// neither repeats nor skips posts when new ones are created meanwhile.
+	After *PostCursor
+}
+
+// PostCursor is the position of a post in a sort order
+type PostCursor struct {
+	Key float64 `json:"k"`
+	ID  int     `json:"id"`
+}
+
+// String encodes the cursor for use in a URL
+func (c PostCursor) String() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// ParsePostCursor decodes a cursor made by PostCursor.String
+func ParsePostCursor(s string) (PostCursor, error) {
+	var c PostCursor
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, err
+	}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return c, err
+	}
+	if c.ID < 1 {
+		return c, errors.New("cursor has no post ID")
+	}
+	return c, nil
+}
+
+// GetPosts returns one page of posts in the requested order, with a cursor
+// for the page after it. The cursor is nil when this page is the last.
+func GetPosts(ctx context.Context, db *sql.DB, opts PostListOptions) ([]models.Post, *PostCursor, error) {
+	ctx, end := track(ctx, "GetPosts")
+	defer end()
+
+	if opts.Sort == "" {
+		opts.Sort = "new"
+	}
+	order, ok := PostSorts[opts.Sort]
+	if !ok {
+		return nil, nil, fmt.Errorf("unknown sort %q", opts.Sort)
+	}
+	cmp, dir := ">", "ASC"
+	if order.desc {
+		cmp, dir = "<", "DESC"
+	}
+
+	where := "p.created_at >= ?"
+	args := []any{opts.Since.UTC().Format(time.DateTime)}
+	if opts.AuthorID != "" {
+		where += " AND p.user_id = ?"
+		args = append(args, opts.AuthorID)
+	}
+	offset := (opts.Page - 1) * opts.Limit
+	if opts.After != nil {
+		where += " AND (sort_key " + cmp + " ? OR (sort_key = ? AND p.id " + cmp + " ?))"
+		args = append(args, opts.After.Key, opts.After.Key, opts.After.ID)
+		offset = 0
+	}
+	args = append(args, opts.Limit, offset)
 
 	// Query basic post data
-	rows, err := db.Query(`
+	rows, err := db.QueryContext(ctx, `
 		SELECT 
-			posts.id, 
-			posts.user_id, 
+			p.id, 
+			p.user_id, 
 			users.username, 
-			posts.title, 
-			posts.content, 
-			posts.image_url,
-			posts.created_at, 
-			posts.updated_at
-		FROM posts
-		JOIN users ON posts.user_id = users.id
-		ORDER BY posts.created_at DESC
+			users.avatar_url,
+			p.title, 
+			p.content, 
+			p.image_url,
+			p.comment_count,
+			p.created_at, 
+			p.updated_at,
+			`+order.key+` AS sort_key
+		FROM posts p
+		JOIN users ON p.user_id = users.id
+		WHERE `+where+`
+		ORDER BY sort_key `+dir+`, p.id `+dir+`
 		LIMIT ? OFFSET ?
-	`, limit, offset)
+	`, args...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer rows.Close()
 
-	postMap := make(map[int]*models.Post)
+	posts := []models.Post{}
+	index := make(map[int]int)
 	var postIDs []any
+	var last PostCursor
 
 	for rows.Next() {
 		var post models.Post
@@ -151,23 +261,30 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 			&post.ID,
 			&post.UserID,
 			&post.Username,
+			&post.ProfileAvatar,
 			&post.Title,
 			&post.Content,
 			&post.ImageURL,
+			&post.CommentCount,
 			&post.CreatedAt,
 			&post.UpdatedAt,
+			&last.Key,
 		)
 		if err != nil {
-			fmt.Println(err)
-			return nil, err
+			return nil, nil, err
 		}
+		last.ID = post.ID
 		post.CategoryIDs = []int{}
-		postMap[post.ID] = &post
+		index[post.ID] = len(posts)
+		posts = append(posts, post)
 		postIDs = append(postIDs, post.ID)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
 
 	if len(postIDs) == 0 {
-		return []models.Post{}, nil
+		return posts, nil, nil
 	}
 
 	// Build query for categories
@@ -182,48 +299,52 @@ func GetPosts(db *sql.DB, page, limit int) ([]models.Post, error) {
 		WHERE post_id IN (%s)
 	`, strings.Join(placeholders, ","))
 
-	catRows, err := db.Query(query, postIDs...)
+	catRows, err := db.QueryContext(ctx, query, postIDs...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	defer catRows.Close()
 
 	for catRows.Next() {
 		var postID, categoryID int
 		if err := catRows.Scan(&postID, &categoryID); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		if post, ok := postMap[postID]; ok {
-			post.CategoryIDs = append(post.CategoryIDs, categoryID)
+		if i, ok := index[postID]; ok {
+			posts[i].CategoryIDs = append(posts[i].CategoryIDs, categoryID)
 		}
 	}
-
-	// Build final slice from postMap
-	posts := make([]models.Post, 0, len(postMap))
-	for _, post := range postMap {
-		posts = append(posts, *post)
+	if err := catRows.Err(); err != nil {
+		return nil, nil, err
 	}
 
-	return posts, nil
+	if len(posts) < opts.Limit {
+		return posts, nil, nil
+	}
+	return posts, &last, nil
 }
 
 // DeletePost removes a post by ID
-func DeletePost(db *sql.DB, postID int) error {
-	_, err := db.Exec(`DELETE FROM posts WHERE id = ?`, postID)
+func DeletePost(ctx context.Context, db *sql.DB, postID int) error {
+	ctx, end := track(ctx, "DeletePost")
+	defer end()
+	_, err := db.ExecContext(ctx, `DELETE FROM posts WHERE id = ?`, postID)
 	return err
 }
 
 // GetOrCreateCategoryIDs resolves category names to IDs, creating new ones if needed.
-func GetOrCreateCategoryIDs(db *sql.DB, names []string) ([]int, error) {
+func GetOrCreateCategoryIDs(ctx context.Context, db *sql.DB, names []string) ([]int, error) {
+	ctx, end := track(ctx, "GetOrCreateCategoryIDs")
+	defer end()
 	var ids []int
 
 	for _, name := range names {
 		var id int
-		err := db.QueryRow(`SELECT id FROM categories WHERE name = ?`, name).Scan(&id)
+		err := db.QueryRowContext(ctx, `SELECT id FROM categories WHERE name = ?`, name).Scan(&id)
 		if err != nil {
 			if err == sql.ErrNoRows {
 				// Create new category
-				err = db.QueryRow(`INSERT INTO categories (name) VALUES (?) RETURNING id`, name).Scan(&id)
+				err = db.QueryRowContext(ctx, `INSERT INTO categories (name) VALUES (?) RETURNING id`, name).Scan(&id)
 				if err != nil {
 					return nil, fmt.Errorf("could not create category %q: %w", name, err)
 				}
@@ -236,101 +357,77 @@ func GetOrCreateCategoryIDs(db *sql.DB, names []string) ([]int, error) {
 	return ids, nil
 }
 
-// ToggleLike toggles a like for a post or comment
-func ToggleLike(db *sql.DB, userID string, postID *int, commentID *int, reactionType string) error {
-	if reactionType != "like" && reactionType != "dislike" {
-		return errors.New("invalid reaction type")
-	}
-	if (postID == nil && commentID == nil) || (postID != nil && commentID != nil) {
-		return errors.New("must provide either postID or commentID, but not both")
-	}
-
-	var existingType string
-	var query string
-	var args []any
-
-	if postID != nil {
-		query = `SELECT type FROM likes WHERE user_id = ? AND post_id = ?`
-		args = []any{userID, *postID}
-	} else {
-		query = `SELECT type FROM likes WHERE user_id = ? AND comment_id = ?`
-		args = []any{userID, *commentID}
+// ToggleLike adds a reaction of reactionType to a post, comment or reply,
+// or removes it when the user already gave one. target is one of the
+// likes.target_type values. A like replaces the user's dislike and the
+// other way round; the other reaction types are independent of each other.
+// It returns ErrUnknownReaction for types that are not enabled, and
+// sql.ErrNoRows when the target does not exist.
+func ToggleLike(ctx context.Context, db *sql.DB, userID, target string, targetID int, reactionType string) error {
+	ctx, end := track(ctx, "ToggleLike")
+	defer end()
+	t, ok := reactionTargets[target]
+	if !ok {
+		return fmt.Errorf("unknown reaction target %q", target)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback()
 
-	err := db.QueryRow(query, args...).Scan(&existingType)
-
-	switch {
-	case err == sql.ErrNoRows:
-		// No existing reaction — insert
-		if postID != nil {
-			_, err = db.Exec(`INSERT INTO likes (user_id, post_id, type) VALUES (?, ?, ?)`, userID, *postID, reactionType)
-		} else {
-			_, err = db.Exec(`INSERT INTO likes (user_id, comment_id, type) VALUES (?, ?, ?)`, userID, *commentID, reactionType)
-		}
-	case err == nil && existingType == reactionType:
-		// Same reaction exists — toggle off (delete)
-		if postID != nil {
-			_, err = db.Exec(`DELETE FROM likes WHERE user_id = ? AND post_id = ?`, userID, *postID)
-		} else {
-			_, err = db.Exec(`DELETE FROM likes WHERE user_id = ? AND comment_id = ?`, userID, *commentID)
-		}
-	case err == nil:
-		// Different reaction — update
-		if postID != nil {
-			_, err = db.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND post_id = ?`, reactionType, userID, *postID)
-		} else {
-			_, err = db.Exec(`UPDATE likes SET type = ? WHERE user_id = ? AND comment_id = ?`, reactionType, userID, *commentID)
-		}
-	default:
+	var enabled bool
+	err = tx.QueryRowContext(ctx, `SELECT enabled FROM reaction_types WHERE name = ?`, reactionType).Scan(&enabled)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !enabled) {
+		return ErrUnknownReaction
+	} else if err != nil {
 		return err
 	}
 
-	return err
-}
-
-func CountLikesAndDislikes(db *sql.DB, postID *int, commentID *int) (likes int, dislikes int, err error) {
-	if (postID == nil && commentID == nil) || (postID != nil && commentID != nil) {
-		return 0, 0, errors.New("must provide either postID or commentID, but not both")
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT 1 FROM `+t.table+` WHERE id = ?`, targetID).Scan(&exists); err != nil {
+		return err
 	}
 
-	var rows *sql.Rows
-	if postID != nil {
-		rows, err = db.Query(`SELECT type, COUNT(*) FROM likes WHERE post_id = ? GROUP BY type`, *postID)
-	} else {
-		rows, err = db.Query(`SELECT type, COUNT(*) FROM likes WHERE comment_id = ? GROUP BY type`, *commentID)
-	}
+	// Same reaction exists — toggle off
+	res, err := tx.ExecContext(ctx, `DELETE FROM likes WHERE user_id = ? AND `+t.column+` = ? AND type = ?`, userID, targetID, reactionType)
 	if err != nil {
-		return
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return tx.Commit()
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var typ string
-		var count int
-		if err = rows.Scan(&typ, &count); err != nil {
-			return
-		}
-		if typ == "like" {
-			likes = count
-		} else if typ == "dislike" {
-			dislikes = count
+	if opposite := opposingVotes[reactionType]; opposite != "" {
+		if _, err := tx.ExecContext(ctx, `DELETE FROM likes WHERE user_id = ? AND `+t.column+` = ? AND type = ?`, userID, targetID, opposite); err != nil {
+			return err
 		}
 	}
-	return
+	if _, err := tx.ExecContext(ctx, `INSERT INTO likes (user_id, target_type, `+t.column+`, type) VALUES (?, ?, ?, ?)`, userID, target, targetID, reactionType); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
-// CleanupSessions removes expired sessions
-func CleanupSessions(db *sql.DB, expiryHours int) error {
-	_, err := db.Exec(`
-	DELETE FROM sessions WHERE created_at <= datetime('now', '-'|| ? || ' hours')
-`, -expiryHours)
+// CleanupSessions removes sessions older than the given lifetime
+func CleanupSessions(ctx context.Context, db *sql.DB, lifetime time.Duration) error {
+	ctx, end := track(ctx, "CleanupSessions")
+	defer end()
+	_, err := db.ExecContext(ctx, `
+	DELETE FROM sessions WHERE created_at <= ?
+`, time.Now().Add(-lifetime))
 	return err
 }
 
 // GetUserIDFromSession retrieves a user ID from a session ID
-func GetUserIDFromSession(db *sql.DB, sessionID string) (string, error) {
+func GetUserIDFromSession(ctx context.Context, db *sql.DB, sessionID string) (string, error) {
+	ctx, end := track(ctx, "GetUserIDFromSession")
+	defer end()
 	var userID string
-	err := db.QueryRow(`
+	err := db.QueryRowContext(ctx, `
 		SELECT user_id FROM sessions WHERE id = ?
 	`, sessionID).Scan(&userID)
 	if err != nil {
@@ -351,7 +448,9 @@ func IsUniqueConstraintError(err error) bool {
 }
 
 // CreateComment inserts a new comment
-func CreateComment(db *sql.DB, userID string, postID int, content string) (models.Comment, error) {
+func CreateComment(ctx context.Context, db *sql.DB, userID string, postID int, content string) (models.Comment, error) {
+	ctx, end := track(ctx, "CreateComment")
+	defer end()
 	var comment models.Comment
 
 	query := `
@@ -360,7 +459,7 @@ func CreateComment(db *sql.DB, userID string, postID int, content string) (model
 		RETURNING id, user_id, post_id, content, created_at, updated_at
 	`
 
-	err := db.QueryRow(query, userID, postID, content).Scan(
+	err := db.QueryRowContext(ctx, query, userID, postID, content).Scan(
 		&comment.ID,
 		&comment.UserID,
 		&comment.PostID,
@@ -375,7 +474,9 @@ func CreateComment(db *sql.DB, userID string, postID int, content string) (model
 	return comment, err
 }
 
-func CreateReplyComment(db *sql.DB, userID string, parentCommentID int, content string) (models.ReplyComment, error) {
+func CreateReplyComment(ctx context.Context, db *sql.DB, userID string, parentCommentID int, content string) (models.ReplyComment, error) {
+	ctx, end := track(ctx, "CreateReplyComment")
+	defer end()
 	var reply models.ReplyComment
 
 	query := `
@@ -384,7 +485,7 @@ func CreateReplyComment(db *sql.DB, userID string, parentCommentID int, content
 		RETURNING id, user_id, parent_comment_id, content, created_at, updated_at
 	`
 
-	err := db.QueryRow(query, userID, parentCommentID, content).Scan(
+	err := db.QueryRowContext(ctx, query, userID, parentCommentID, content).Scan(
 		&reply.ID,
 		&reply.UserID,
 		&reply.ParentCommentID,
@@ -397,11 +498,13 @@ func CreateReplyComment(db *sql.DB, userID string, parentCommentID int, content
 }
 
 // GetPostComments retrieves comments for a specific post
-func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
+func GetPostComments(ctx context.Context, db *sql.DB, postID int) ([]models.Comment, error) {
+	ctx, end := track(ctx, "GetPostComments")
+	defer end()
 	// Step 1: Fetch top-level comments
-	commentRows, err := db.Query(`
+	commentRows, err := db.QueryContext(ctx, `
 		SELECT 
-			c.id, c.user_id, c.post_id, c.content,
+			c.id, c.user_id, c.post_id, c.content, c.reply_count,
 			c.created_at, c.updated_at, u.username, u.avatar_url
 		FROM comments c
 		JOIN users u ON u.id = c.user_id
@@ -413,7 +516,7 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 	}
 	defer commentRows.Close()
 
-	commentsMap := make(map[int]*models.Comment)
+	commentIndex := make(map[int]int)
 	var comments []models.Comment
 
 	for commentRows.Next() {
@@ -423,6 +526,7 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 			&c.UserID,
 			&c.PostID,
 			&c.Content,
+			&c.ReplyCount,
 			&c.CreatedAt,
 			&c.UpdatedAt,
 			&c.UserName,
@@ -431,12 +535,12 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 		if err != nil {
 			return nil, err
 		}
+		commentIndex[c.ID] = len(comments)
 		comments = append(comments, c)
-		commentsMap[c.ID] = &comments[len(comments)-1] // store pointer
 	}
 
 	// Step 2: Fetch replies
-	replyRows, err := db.Query(`
+	replyRows, err := db.QueryContext(ctx, `
 		SELECT 
 			r.id, r.user_id, r.parent_comment_id, r.content,
 			r.created_at, r.updated_at, u.username, u.avatar_url
@@ -468,17 +572,65 @@ func GetPostComments(db *sql.DB, postID int) ([]models.Comment, error) {
 			return nil, err
 		}
 
-		if parent, ok := commentsMap[r.ParentCommentID]; ok {
-			parent.Replies = append(parent.Replies, r)
+		if i, ok := commentIndex[r.ParentCommentID]; ok {
+			comments[i].Replies = append(comments[i].Replies, r)
 		}
 	}
 
 	return comments, nil
 }
 
+// GetCommentReplies retrieves the replies to a comment, oldest first.
+// It returns sql.ErrNoRows when the comment does not exist.
+func GetCommentReplies(ctx context.Context, db *sql.DB, commentID int) ([]models.ReplyComment, error) {
+	ctx, end := track(ctx, "GetCommentReplies")
+	defer end()
+
+	var exists int
+	if err := db.QueryRowContext(ctx, `SELECT 1 FROM comments WHERE id = ?`, commentID).Scan(&exists); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			r.id, r.user_id, r.parent_comment_id, r.content,
+			r.created_at, r.updated_at, u.username, u.avatar_url
+		FROM replycomments r
+		JOIN users u ON u.id = r.user_id
+		WHERE r.parent_comment_id = ?
+		ORDER BY r.created_at ASC
+	`, commentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	replies := []models.ReplyComment{}
+	for rows.Next() {
+		var r models.ReplyComment
+		err := rows.Scan(
+			&r.ID,
+			&r.UserID,
+			&r.ParentCommentID,
+			&r.Content,
+			&r.CreatedAt,
+			&r.UpdatedAt,
+			&r.UserName,
+			&r.ProfileAvatar,
+		)
+		if err != nil {
+			return nil, err
+		}
+		replies = append(replies, r)
+	}
+	return replies, rows.Err()
+}
+
 // CreateCategory inserts a new category
-func CreateCategory(db *sql.DB, name string) error {
-	_, err := db.Exec(`
+func CreateCategory(ctx context.Context, db *sql.DB, name string) error {
+	ctx, end := track(ctx, "CreateCategory")
+	defer end()
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO categories (name)
 		VALUES (?)
 	`, name)
@@ -486,8 +638,10 @@ func CreateCategory(db *sql.DB, name string) error {
 }
 
 // GetCategories retrieves all categories
-func GetCategories(db *sql.DB) ([]models.Category, error) {
-	rows, err := db.Query(`SELECT id, name FROM categories`)
+func GetCategories(ctx context.Context, db *sql.DB) ([]models.Category, error) {
+	ctx, end := track(ctx, "GetCategories")
+	defer end()
+	rows, err := db.QueryContext(ctx, `SELECT id, name FROM categories`)
 	if err != nil {
 		return nil, err
 	}
@@ -505,8 +659,10 @@ func GetCategories(db *sql.DB) ([]models.Category, error) {
 }
 
 // UpdatePost updates an existing post's title and content
-func UpdatePost(db *sql.DB, postID int, title, content string) error {
-	_, err := db.Exec(`
+func UpdatePost(ctx context.Context, db *sql.DB, postID int, title, content string) error {
+	ctx, end := track(ctx, "UpdatePost")
+	defer end()
+	_, err := db.ExecContext(ctx, `
 		UPDATE posts 
 		SET title = ?, content = ?
 		WHERE id = ?
@@ -515,18 +671,22 @@ func UpdatePost(db *sql.DB, postID int, title, content string) error {
 }
 
 // DeleteComment removes a comment from the database by its ID
-func DeleteComment(db *sql.DB, commentID int) error {
-	_, err := db.Exec(`
+func DeleteComment(ctx context.Context, db *sql.DB, commentID int) error {
+	ctx, end := track(ctx, "DeleteComment")
+	defer end()
+	_, err := db.ExecContext(ctx, `
 		DELETE FROM comments WHERE id = ?
 	`, commentID)
 	return err
 }
 
 // GetUserByEmail retrieves a user by email
-func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
+func GetUserByEmail(ctx context.Context, db *sql.DB, email string) (models.User, error) {
+	ctx, end := track(ctx, "GetUserByEmail")
+	defer end()
 	var user models.User
-	err := db.QueryRow(`
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+	err := db.QueryRowContext(ctx, `
+		SELECT id, username, email, password_hash, avatar_url, bio, created_at, updated_at
 		FROM users
 		WHERE email = ?
 	`, email).Scan(
@@ -535,6 +695,7 @@ func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Bio,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -545,9 +706,11 @@ func GetUserByEmail(db *sql.DB, email string) (models.User, error) {
 }
 
 // CreateSession creates a new session for a user and returns the session ID
-func CreateSession(db *sql.DB, userID string) (string, error) {
+func CreateSession(ctx context.Context, db *sql.DB, userID string) (string, error) {
+	ctx, end := track(ctx, "CreateSession")
+	defer end()
 	sessionID := uuid.New().String()
-	_, err := db.Exec(`
+	_, err := db.ExecContext(ctx, `
 		INSERT INTO sessions (id, user_id, created_at) VALUES (?, ?, ?)
 	`, sessionID, userID, time.Now())
 	if err != nil {
@@ -557,27 +720,32 @@ func CreateSession(db *sql.DB, userID string) (string, error) {
 }
 
 // DeleteSession removes a session from the database
-func DeleteSession(db *sql.DB, sessionID string) error {
-	_, err := db.Exec(`
+func DeleteSession(ctx context.Context, db *sql.DB, sessionID string) error {
+	ctx, end := track(ctx, "DeleteSession")
+	defer end()
+	_, err := db.ExecContext(ctx, `
 		DELETE FROM sessions WHERE id = ?
 	`, sessionID)
 	return err
 }
 
-func GetUserByID(db *sql.DB, userID string) (*models.User, error) {
+func GetUserByID(ctx context.Context, db *sql.DB, userID string) (*models.User, error) {
+	ctx, end := track(ctx, "GetUserByID")
+	defer end()
 	var user models.User
 
 	query := `
-		SELECT id, username, email, password_hash, avatar_url, created_at, updated_at
+		SELECT id, username, email, password_hash, avatar_url, bio, created_at, updated_at
 		FROM users
 		WHERE id = ?
 	`
-	err := db.QueryRow(query, userID).Scan(
+	err := db.QueryRowContext(ctx, query, userID).Scan(
 		&user.ID,
 		&user.Username,
 		&user.Email,
 		&user.PasswordHash,
 		&user.AvatarURL,
+		&user.Bio,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
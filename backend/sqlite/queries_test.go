@@ -0,0 +1,340 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPost is a post seeded by seedPosts, with the activity under it
+type testPost struct {
+	createdAt       string
+	likes, dislikes int64
+	comment, reply  string // "" for none
+}
+
+// testPosts tie in every sort order: in created_at, including different
+// times within the same second, in votes and in activity
+var testPosts = []testPost{
+	{"2024-01-01 10:00:00", 0, 0, "2024-01-07 00:00:00", ""},
+	{"2024-01-01 10:00:00", 0, 0, "2024-01-02 00:00:00", "2024-01-07 00:00:00"},
+	{"2024-01-01 10:00:00.250", 5, 5, "", ""},
+	{"2024-01-01 10:00:00.750", 5, 5, "", ""},
+	{"2024-01-02 09:00:00", 10, 0, "2024-01-06 00:00:00", ""},
+	{"2024-01-02 09:00:00", 10, 0, "", ""},
+	{"2024-01-03 12:00:00", 3, 3, "", ""},
+	{"2024-01-03 12:00:00", 1, 9, "", ""},
+	{"2024-01-04 08:30:00", 9, 1, "2024-01-04 08:30:00", "2024-01-04 08:30:00"},
+	{"2024-01-05 00:00:00", 0, 2, "", ""},
+	{"2024-01-05 00:00:00", 2, 0, "", ""},
+	{"2024-01-06 00:00:00", 5, 5, "", ""},
+	{"2024-01-06 00:00:00.500", 100, 1, "", ""},
+}
+
+// seedPosts inserts testPosts as posts 1 to len(testPosts)
+func seedPosts(t *testing.T, db *sql.DB) {
+	t.Helper()
+	mustExec(t, db, `INSERT INTO users (id, username, email, password_hash) VALUES ('u1', 'ann', 'ann@example.com', '')`)
+	for i, p := range testPosts {
+		id := i + 1
+		mustExec(t, db, `INSERT INTO posts (id, user_id, title, content, created_at) VALUES (?, 'u1', 'title', 'content', ?)`, id, p.createdAt)
+		mustExec(t, db, `UPDATE posts SET like_count = ?, dislike_count = ? WHERE id = ?`, p.likes, p.dislikes, id)
+		if p.comment == "" {
+			continue
+		}
+		mustExec(t, db, `INSERT INTO comments (id, user_id, post_id, content, created_at) VALUES (?, 'u1', ?, 'comment', ?)`, id, id, p.comment)
+		if p.reply != "" {
+			mustExec(t, db, `INSERT INTO replycomments (user_id, parent_comment_id, content, created_at) VALUES ('u1', ?, 'reply', ?)`, id, p.reply)
+		}
+	}
+}
+
+// unixSeconds truncates a stored timestamp to whole seconds, as unixepoch does
+func unixSeconds(t *testing.T, s string) float64 {
+	t.Helper()
+	ts, err := time.Parse(time.DateTime, s[:len(time.DateTime)])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return float64(ts.Unix())
+}
+
+// wantOrder computes the IDs of testPosts created at or after since in the
+// given sort order
+func wantOrder(t *testing.T, sort, since string) []int {
+	t.Helper()
+	type keyed struct {
+		key float64
+		id  int
+	}
+	var posts []keyed
+	for i, p := range testPosts {
+		if p.createdAt < since {
+			continue
+		}
+		k := keyed{id: i + 1}
+		switch sort {
+		case "new", "oldest":
+			k.key = unixSeconds(t, p.createdAt)
+		case "top":
+			k.key = WilsonLowerBound(p.likes, p.dislikes)
+		case "controversial":
+			k.key = Controversy(p.likes, p.dislikes)
+		case "active":
+			k.key = unixSeconds(t, p.createdAt)
+			for _, s := range []string{p.comment, p.reply} {
+				if s != "" {
+					k.key = max(k.key, unixSeconds(t, s))
+				}
+			}
+		}
+		posts = append(posts, k)
+	}
+	desc := PostSorts[sort].desc
+	slices.SortFunc(posts, func(a, b keyed) int {
+		c := cmpKeyed(a.key, b.key)
+		if c == 0 {
+			c = a.id - b.id
+		}
+		if desc {
+			return -c
+		}
+		return c
+	})
+	ids := make([]int, len(posts))
+	for i, p := range posts {
+		ids[i] = p.id
+	}
+	return ids
+}
+
+func cmpKeyed(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// pageAll follows cursors from the first page until GetPosts returns none
+func pageAll(t *testing.T, db *sql.DB, opts PostListOptions) []int {
+	t.Helper()
+	var ids []int
+	for range len(testPosts) + 2 {
+		posts, next, err := GetPosts(context.Background(), db, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(posts) > opts.Limit {
+			t.Fatalf("page of %d posts, limit %d", len(posts), opts.Limit)
+		}
+		for _, p := range posts {
+			ids = append(ids, p.ID)
+		}
+		if next == nil {
+			return ids
+		}
+		// Cursors go through a URL between pages
+		after, err := ParsePostCursor(next.String())
+		if err != nil {
+			t.Fatalf("cursor %q: %v", next.String(), err)
+		}
+		opts.After = &after
+	}
+	t.Fatalf("paging did not end after %d pages: %v", len(testPosts)+2, ids)
+	return nil
+}
+
+func TestGetPostsPaging(t *testing.T) {
+	db := openDB(t)
+	seedPosts(t, db)
+
+	for sort := range PostSorts {
+		want := wantOrder(t, sort, "")
+		t.Run(sort, func(t *testing.T) {
+			all, next, err := GetPosts(context.Background(), db, PostListOptions{Sort: sort, Page: 1, Limit: 100})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if next != nil {
+				t.Errorf("cursor %v after the only page", next)
+			}
+			var ids []int
+			for _, p := range all {
+				ids = append(ids, p.ID)
+			}
+			if !slices.Equal(ids, want) {
+				t.Errorf("order = %v, want %v", ids, want)
+			}
+
+			for limit := 1; limit <= 5; limit++ {
+				if got := pageAll(t, db, PostListOptions{Sort: sort, Page: 1, Limit: limit}); !slices.Equal(got, want) {
+					t.Errorf("paging by %d = %v, want %v", limit, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestGetPostsSince(t *testing.T) {
+	db := openDB(t)
+	seedPosts(t, db)
+
+	tests := []struct {
+		since string
+		want  int
+	}{
+		{"", len(testPosts)},
+		{"2024-01-01 10:00:00", len(testPosts)},
+		// The same second as posts 3 and 4, which have fractions of one
+		{"2024-01-01 10:00:01", 9},
+		{"2024-01-03 12:00:00", 7},
+		{"2024-01-06 00:00:00", 2},
+		{"2024-02-01 00:00:00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.since, func(t *testing.T) {
+			var since time.Time
+			if tt.since != "" {
+				var err error
+				if since, err = time.Parse(time.DateTime, tt.since); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for sort := range PostSorts {
+				want := wantOrder(t, sort, tt.since)
+				if len(want) != tt.want {
+					t.Fatalf("%d posts since %q, want %d", len(want), tt.since, tt.want)
+				}
+				got := pageAll(t, db, PostListOptions{Sort: sort, Since: since, Page: 1, Limit: 3})
+				if !slices.Equal(got, want) {
+					t.Errorf("%s since %q = %v, want %v", sort, tt.since, got, want)
+				}
+			}
+		})
+	}
+
+	// Since is compared in UTC whatever its location
+	east := time.FixedZone("UTC+2", 2*60*60)
+	since := time.Date(2024, 1, 6, 2, 0, 0, 0, east)
+	posts, _, err := GetPosts(context.Background(), db, PostListOptions{Since: since, Page: 1, Limit: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(posts) != 2 {
+		t.Errorf("%d posts since %v, want 2", len(posts), since)
+	}
+}
+
+// TestGetPostsAuthor checks that each post carries its author's name and
+// avatar, and that AuthorID keeps only that author's posts
+func TestGetPostsAuthor(t *testing.T) {
+	db := openDB(t)
+	mustExec(t, db, `
+		INSERT INTO users (id, username, email, password_hash, avatar_url) VALUES
+			('u1', 'ann', 'ann@example.com', '', '/static/avatars/avatar_1_128.png'),
+			('u2', 'ben', 'ben@example.com', '', '/static/avatars/avatar_2_128.png');
+		INSERT INTO posts (id, user_id, title, content) VALUES
+			(1, 'u1', 'title', 'content'), (2, 'u2', 'title', 'content'), (3, 'u1', 'title', 'content');
+	`)
+
+	for _, tt := range []struct {
+		authorID string
+		want     []string
+	}{
+		{"", []string{"3 ann /static/avatars/avatar_1_128.png", "2 ben /static/avatars/avatar_2_128.png", "1 ann /static/avatars/avatar_1_128.png"}},
+		{"u2", []string{"2 ben /static/avatars/avatar_2_128.png"}},
+	} {
+		posts, _, err := GetPosts(context.Background(), db, PostListOptions{AuthorID: tt.authorID, Page: 1, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, p := range posts {
+			got = append(got, fmt.Sprintf("%d %s %s", p.ID, p.Username, p.ProfileAvatar))
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("posts by %q = %q, want %q", tt.authorID, got, tt.want)
+		}
+	}
+}
+
+func TestParsePostCursorRejectsGarbage(t *testing.T) {
+	encode := func(s string) string { return base64.RawURLEncoding.EncodeToString([]byte(s)) }
+	valid := PostCursor{Key: 1.5, ID: 3}.String()
+
+	for _, s := range []string{
+		"",
+		"!!!",
+		valid + "=",
+		strings.ToUpper(valid),
+		valid[:len(valid)-3],
+		encode("not json"),
+		encode("null"),
+		encode("[]"),
+		encode(`{"k":1}`),
+		encode(`{"k":1,"id":0}`),
+		encode(`{"k":1,"id":-4}`),
+		encode(`{"k":"1","id":3}`),
+		encode(`{"k":1,"id":3.5}`),
+		encode(`{"k":1e999,"id":3}`),
+		encode(`{"k":1,"id":99999999999999999999}`),
+	} {
+		t.Run(s, func(t *testing.T) {
+			if c, err := ParsePostCursor(s); err == nil {
+				t.Errorf("ParsePostCursor(%q) = %+v, want an error", s, c)
+			}
+		})
+	}
+
+	c, err := ParsePostCursor(valid)
+	if err != nil || c != (PostCursor{Key: 1.5, ID: 3}) {
+		t.Errorf("ParsePostCursor(%q) = %+v, %v", valid, c, err)
+	}
+}
+
+// TestGetPostsTamperedCursor checks that cursors no page produced, which
+// parse but match no post, continue from where they point
+func TestGetPostsTamperedCursor(t *testing.T) {
+	db := openDB(t)
+	seedPosts(t, db)
+
+	tests := []struct {
+		sort   string
+		cursor PostCursor
+		want   []int
+	}{
+		{"new", PostCursor{Key: 1e300, ID: 1 << 62}, wantOrder(t, "new", "")},
+		{"new", PostCursor{Key: -1e300, ID: 1}, nil},
+		{"oldest", PostCursor{Key: -1e300, ID: 1}, wantOrder(t, "oldest", "")},
+		{"oldest", PostCursor{Key: 1e300, ID: 1 << 62}, nil},
+		// Between the keys of posts 9 and 11
+		{"top", PostCursor{Key: 0.5, ID: 1000}, []int{11, 12, 4, 3, 7, 8, 10, 2, 1}},
+		// The key of posts 3, 4 and 12, from an ID none of them has
+		{"controversial", PostCursor{Key: 10, ID: 5}, []int{4, 3, 7, 9, 8, 13, 11, 10, 6, 5, 2, 1}},
+		{"active", PostCursor{Key: unixSeconds(t, "2024-01-06 00:00:00"), ID: 13}, []int{12, 5, 11, 10, 9, 8, 7, 6, 4, 3}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s after %v", tt.sort, tt.cursor), func(t *testing.T) {
+			opts := PostListOptions{Sort: tt.sort, Page: 1, Limit: 100, After: &tt.cursor}
+			posts, _, err := GetPosts(context.Background(), db, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var ids []int
+			for _, p := range posts {
+				ids = append(ids, p.ID)
+			}
+			if !slices.Equal(ids, tt.want) {
+				t.Errorf("posts = %v, want %v", ids, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"forum/models"
+)
+
+// ErrUnknownReaction is returned for reaction types that do not exist or are disabled
+var ErrUnknownReaction = errors.New("unknown reaction type")
+
+// reactionTargets maps each likes.target_type to the table the target
+// lives in and the likes column that holds its ID
+var reactionTargets = map[string]struct{ table, column string }{
+	"post":    {"posts", "post_id"},
+	"comment": {"comments", "comment_id"},
+	"reply":   {"replycomments", "reply_id"},
+}
+
+// opposingVotes pairs the reaction types a user cannot give together
+var opposingVotes = map[string]string{
+	"like":    "dislike",
+	"dislike": "like",
+}
+
+// SyncReactionTypes enables the configured reaction types in order after
+// like and dislike, and disables every other type. Disabled types keep
+// their rows in likes but are no longer counted or accepted.
+func SyncReactionTypes(ctx context.Context, db *sql.DB, types []models.ReactionType) error {
+	ctx, end := track(ctx, "SyncReactionTypes")
+	defer end()
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `UPDATE reaction_types SET enabled = 0 WHERE name NOT IN ('like', 'dislike')`); err != nil {
+		return err
+	}
+	for i, t := range types {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO reaction_types (name, emoji, position, enabled) VALUES (?, ?, ?, 1)
+			ON CONFLICT (name) DO UPDATE SET emoji = excluded.emoji, position = excluded.position, enabled = 1
+		`, t.Name, t.Emoji, i+2)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetReactionTypes returns the enabled reaction types in display order
+func GetReactionTypes(ctx context.Context, db *sql.DB) ([]models.ReactionType, error) {
+	ctx, end := track(ctx, "GetReactionTypes")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT name, emoji
+		FROM reaction_types
+		WHERE enabled
+		ORDER BY position, name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	types := []models.ReactionType{}
+	for rows.Next() {
+		var t models.ReactionType
+		if err := rows.Scan(&t.Name, &t.Emoji); err != nil {
+			return nil, err
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// CheckReactionTarget returns sql.ErrNoRows when the post, comment or reply
+// targetID does not exist
+func CheckReactionTarget(ctx context.Context, db *sql.DB, target string, targetID int) error {
+	ctx, end := track(ctx, "CheckReactionTarget")
+	defer end()
+	t, ok := reactionTargets[target]
+	if !ok {
+		return fmt.Errorf("unknown reaction target %q", target)
+	}
+	var exists bool
+	return db.QueryRowContext(ctx, `SELECT 1 FROM `+t.table+` WHERE id = ?`, targetID).Scan(&exists)
+}
+
+// GetReactors returns the users who reacted to a post, comment or reply,
+// most recent first, optionally only those who gave reactionType. It
+// returns sql.ErrNoRows when the target does not exist.
+func GetReactors(ctx context.Context, db *sql.DB, target string, targetID int, reactionType string, page, limit int) ([]models.Reactor, error) {
+	ctx, end := track(ctx, "GetReactors")
+	defer end()
+	t, ok := reactionTargets[target]
+	if !ok {
+		return nil, fmt.Errorf("unknown reaction target %q", target)
+	}
+	offset := (page - 1) * limit
+
+	if err := CheckReactionTarget(ctx, db, target, targetID); err != nil {
+		return nil, err
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT u.id, u.username, u.avatar_url, l.type, l.created_at
+		FROM likes l
+		JOIN users u ON u.id = l.user_id
+		JOIN reaction_types rt ON rt.name = l.type
+		WHERE l.`+t.column+` = ? AND rt.enabled AND (? = '' OR l.type = ?)
+		ORDER BY l.created_at DESC, l.rowid DESC
+		LIMIT ? OFFSET ?
+	`, targetID, reactionType, reactionType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactors := []models.Reactor{}
+	for rows.Next() {
+		var r models.Reactor
+		if err := rows.Scan(&r.UserID, &r.Username, &r.AvatarURL, &r.Type, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		reactors = append(reactors, r)
+	}
+	return reactors, rows.Err()
+}
+
+// GetUserReactions returns everything userID has reacted to, most recent
+// first. target and reactionType narrow the list when set, so
+// ("post", "like") lists the posts the user liked.
+func GetUserReactions(ctx context.Context, db *sql.DB, userID, target, reactionType string, page, limit int) ([]models.UserReaction, error) {
+	ctx, end := track(ctx, "GetUserReactions")
+	defer end()
+	offset := (page - 1) * limit
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			l.target_type, COALESCE(l.post_id, l.comment_id, l.reply_id), l.type,
+			p.id, p.title,
+			CASE l.target_type WHEN 'post' THEN p.content WHEN 'comment' THEN c.content ELSE r.content END,
+			l.created_at
+		FROM likes l
+		JOIN reaction_types rt ON rt.name = l.type
+		LEFT JOIN replycomments r ON r.id = l.reply_id
+		LEFT JOIN comments c ON c.id = COALESCE(l.comment_id, r.parent_comment_id)
+		JOIN posts p ON p.id = COALESCE(l.post_id, c.post_id)
+		WHERE l.user_id = ? AND rt.enabled
+			AND (? = '' OR l.target_type = ?)
+			AND (? = '' OR l.type = ?)
+		ORDER BY l.created_at DESC, l.rowid DESC
+		LIMIT ? OFFSET ?
+	`, userID, target, target, reactionType, reactionType, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reactions := []models.UserReaction{}
+	for rows.Next() {
+		var u models.UserReaction
+		err := rows.Scan(
+			&u.TargetType, &u.TargetID, &u.Type,
+			&u.PostID, &u.PostTitle,
+			&u.Content,
+			&u.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, u)
+	}
+	return reactions, rows.Err()
+}
@@ -0,0 +1,140 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+
+	"forum/models"
+)
+
+// SavePost bookmarks postID for userID in collection. Saving a post again
+// moves it to the new collection and keeps its original save time. It
+// returns sql.ErrNoRows when the post does not exist.
+func SavePost(ctx context.Context, db *sql.DB, userID string, postID int, collection string) error {
+	ctx, end := track(ctx, "SavePost")
+	defer end()
+
+	var exists int
+	if err := db.QueryRowContext(ctx, `SELECT 1 FROM posts WHERE id = ?`, postID).Scan(&exists); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		INSERT INTO saved_posts (user_id, post_id, collection)
+		VALUES (?, ?, ?)
+		ON CONFLICT (user_id, post_id) DO UPDATE SET collection = excluded.collection
+	`, userID, postID, collection)
+	return err
+}
+
+// UnsavePost removes postID from userID's saved posts. Removing a post that
+// was not saved is not an error.
+func UnsavePost(ctx context.Context, db *sql.DB, userID string, postID int) error {
+	ctx, end := track(ctx, "UnsavePost")
+	defer end()
+	_, err := db.ExecContext(ctx, `DELETE FROM saved_posts WHERE user_id = ? AND post_id = ?`, userID, postID)
+	return err
+}
+
+// GetSavedPosts returns userID's saved posts, most recently saved first.
+// A non-empty collection narrows the list to that collection.
+func GetSavedPosts(ctx context.Context, db *sql.DB, userID, collection string, page, limit int) ([]models.SavedPost, error) {
+	ctx, end := track(ctx, "GetSavedPosts")
+	defer end()
+	offset := (page - 1) * limit
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			p.id, p.user_id, u.username, u.avatar_url,
+			p.title, p.content, p.image_url, p.comment_count,
+			p.created_at, p.updated_at,
+			s.collection, s.created_at
+		FROM saved_posts s
+		JOIN posts p ON p.id = s.post_id
+		JOIN users u ON u.id = p.user_id
+		WHERE s.user_id = ? AND (? = '' OR s.collection = ?)
+		ORDER BY s.created_at DESC, s.rowid DESC
+		LIMIT ? OFFSET ?
+	`, userID, collection, collection, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	saved := []models.SavedPost{}
+	index := make(map[int]int)
+	var postIDs []int
+	for rows.Next() {
+		var s models.SavedPost
+		err := rows.Scan(
+			&s.Post.ID, &s.Post.UserID, &s.Post.Username, &s.Post.ProfileAvatar,
+			&s.Post.Title, &s.Post.Content, &s.Post.ImageURL, &s.Post.CommentCount,
+			&s.Post.CreatedAt, &s.Post.UpdatedAt,
+			&s.Collection, &s.SavedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		s.Post.CategoryIDs = []int{}
+		s.Post.IsSaved = true
+		index[s.Post.ID] = len(saved)
+		saved = append(saved, s)
+		postIDs = append(postIDs, s.Post.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(postIDs) == 0 {
+		return saved, nil
+	}
+
+	in, args := inClause(postIDs)
+	catRows, err := db.QueryContext(ctx, `
+		SELECT post_id, category_id
+		FROM post_categories
+		WHERE post_id IN (`+in+`)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer catRows.Close()
+
+	for catRows.Next() {
+		var postID, categoryID int
+		if err := catRows.Scan(&postID, &categoryID); err != nil {
+			return nil, err
+		}
+		if i, ok := index[postID]; ok {
+			saved[i].Post.CategoryIDs = append(saved[i].Post.CategoryIDs, categoryID)
+		}
+	}
+	return saved, catRows.Err()
+}
+
+// GetSavedCollections lists the named collections userID has filed saved
+// posts under, alphabetically, with the number of posts in each
+func GetSavedCollections(ctx context.Context, db *sql.DB, userID string) ([]models.SavedCollection, error) {
+	ctx, end := track(ctx, "GetSavedCollections")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT collection, COUNT(*)
+		FROM saved_posts
+		WHERE user_id = ? AND collection != ''
+		GROUP BY collection
+		ORDER BY collection
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	collections := []models.SavedCollection{}
+	for rows.Next() {
+		var c models.SavedCollection
+		if err := rows.Scan(&c.Name, &c.PostCount); err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	return collections, rows.Err()
+}
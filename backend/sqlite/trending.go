@@ -0,0 +1,119 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"forum/models"
+)
+
+// GetTrendingPosts returns the posts created at or after since, highest
+// cached hot score first. Posts created after the last recompute have no
+// score yet and rank as 0. A zero since includes every post.
+func GetTrendingPosts(ctx context.Context, db *sql.DB, since time.Time, page, limit int) ([]models.Trend, error) {
+	ctx, end := track(ctx, "GetTrendingPosts")
+	defer end()
+	offset := (page - 1) * limit
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT
+			p.id, p.user_id, u.username, u.avatar_url,
+			p.title, p.content, p.image_url,
+			p.like_count, p.dislike_count, p.comment_count,
+			(SELECT COALESCE(SUM(c.reply_count), 0) FROM comments c WHERE c.post_id = p.id),
+			COALESCE(t.score, 0),
+			p.created_at
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN trending_scores t ON t.post_id = p.id
+		WHERE p.created_at >= ?
+		ORDER BY COALESCE(t.score, 0) DESC, p.created_at DESC, p.id DESC
+		LIMIT ? OFFSET ?
+	`, since.UTC().Format(time.DateTime), limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	trends := []models.Trend{}
+	var ids []int
+	for rows.Next() {
+		var t models.Trend
+		err := rows.Scan(
+			&t.ID, &t.UserID, &t.Username, &t.ProfileAvatar,
+			&t.Title, &t.Content, &t.ImageURL,
+			&t.LikeCount, &t.DislikeCount, &t.CommentCount,
+			&t.ReplyCount,
+			&t.Score,
+			&t.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		t.CategoryIDs = []int{}
+		trends = append(trends, t)
+		ids = append(ids, t.ID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return trends, nil
+	}
+
+	in, args := inClause(ids)
+	catRows, err := db.QueryContext(ctx, `
+		SELECT post_id, category_id
+		FROM post_categories
+		WHERE post_id IN (`+in+`)
+		ORDER BY category_id
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer catRows.Close()
+
+	index := make(map[int]int, len(trends))
+	for i, t := range trends {
+		index[t.ID] = i
+	}
+	for catRows.Next() {
+		var postID, categoryID int
+		if err := catRows.Scan(&postID, &categoryID); err != nil {
+			return nil, err
+		}
+		t := &trends[index[postID]]
+		t.CategoryIDs = append(t.CategoryIDs, categoryID)
+	}
+	return trends, catRows.Err()
+}
+
+// GetTrendingCategories returns categories by the cached sum of their
+// posts' hot scores, highest first
+func GetTrendingCategories(ctx context.Context, db *sql.DB, limit int) ([]models.CategoryTrend, error) {
+	ctx, end := track(ctx, "GetTrendingCategories")
+	defer end()
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT c.id, c.name, t.post_count, t.score
+		FROM trending_category_scores t
+		JOIN categories c ON c.id = t.category_id
+		ORDER BY t.score DESC, t.post_count DESC, c.name
+		LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.CategoryTrend{}
+	for rows.Next() {
+		var c models.CategoryTrend
+		if err := rows.Scan(&c.ID, &c.Name, &c.PostCount, &c.Score); err != nil {
+			return nil, err
+		}
+		categories = append(categories, c)
+	}
+	return categories, rows.Err()
+}
@@ -1,12 +1,14 @@
 package utils
 
 import (
+	"context"
 	"database/sql"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
 
+	"forum/config"
+	"forum/logging"
 	"forum/sqlite"
 
 	"golang.org/x/crypto/bcrypt"
@@ -24,13 +26,13 @@ func CheckPasswordHash(password, hash string) bool {
 }
 
 // IsAuthor checks if the given user is the author of a specific comment
-func IsAuthor(db *sql.DB, userID string, id int, isPost bool) (bool, error) {
+func IsAuthor(ctx context.Context, db *sql.DB, userID string, id int, isPost bool) (bool, error) {
 	var authorID string
 	query := "SELECT user_id FROM comments WHERE id = ?"
 	if isPost {
 		query = "SELECT user_id FROM posts WHERE id = ?"
 	}
-	err := db.QueryRow(query, id).Scan(&authorID)
+	err := db.QueryRowContext(ctx, query, id).Scan(&authorID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return false, nil
@@ -47,9 +49,9 @@ func IsAuthenticated(db *sql.DB, r *http.Request) (bool, error) {
 		return false, err // Return error instead of just false
 	}
 
-	valid, err := validateSession(db, sessionCookie.Value)
+	valid, err := validateSession(r.Context(), db, sessionCookie.Value)
 	if err != nil {
-		log.Println("Session validation error:", err)
+		logging.FromContext(r.Context()).Error("session validation failed", "err", err)
 		return false, err
 	}
 	return valid, nil
@@ -61,23 +63,27 @@ func GetUserIDFromSession(db *sql.DB, r *http.Request) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return getUserIDFromSession(db, sessionCookie.Value)
+	userID, err := getUserIDFromSession(r.Context(), db, sessionCookie.Value)
+	if err == nil && userID != "" {
+		logging.SetUserID(r.Context(), userID)
+	}
+	return userID, err
 }
 
 // validateSession validates the session
-func validateSession(db *sql.DB, sessionID string) (bool, error) {
+func validateSession(ctx context.Context, db *sql.DB, sessionID string) (bool, error) {
 	var userID int
 	var createdAt time.Time
 
-	err := db.QueryRow(`
+	err := db.QueryRowContext(ctx, `
         SELECT user_id, created_at FROM sessions WHERE id = ?
     `, sessionID).Scan(&userID, &createdAt)
 	if err != nil {
 		return false, err
 	}
 
-	// Ensure session is not expired (e.g., 24 hours validity)
-	if time.Since(createdAt) > 24*time.Hour {
+	// Ensure session is not expired
+	if time.Since(createdAt) > config.Current().Session.Lifetime.Duration {
 		return false, nil
 	}
 
@@ -85,8 +91,8 @@ func validateSession(db *sql.DB, sessionID string) (bool, error) {
 }
 
 // getUserIDFromSession retrieves the user ID from the session
-func getUserIDFromSession(db *sql.DB, sessionID string) (string, error) {
-	userID, err := sqlite.GetUserIDFromSession(db, sessionID)
+func getUserIDFromSession(ctx context.Context, db *sql.DB, sessionID string) (string, error) {
+	userID, err := sqlite.GetUserIDFromSession(ctx, db, sessionID)
 	if err != nil {
 		return "", err
 	}
@@ -2,7 +2,12 @@ package utils
 
 import (
 	"encoding/json"
+	"log/slog"
 	"net/http"
+	"strings"
+
+	"forum/apierror"
+	"forum/logging"
 )
 
 // JSONResponse sends a JSON response with the given status code and data
@@ -12,19 +17,46 @@ func JSONResponse(w http.ResponseWriter, statusCode int, data any) {
 	json.NewEncoder(w).Encode(data)
 }
 
-// ErrorResponse sends a JSON response with an error message
-func ErrorResponse(w http.ResponseWriter, statusCode int, message string) {
-	JSONResponse(w, statusCode, map[string]string{"error": message})
-}
-
 // SuccessResponse sends a JSON response with a success message
 func SuccessResponse(w http.ResponseWriter, message string) {
 	JSONResponse(w, http.StatusOK, map[string]string{"message": message})
 }
 
-// SendJSONError sends a JSON response with an error message
-func SendJSONError(w http.ResponseWriter, message string, statusCode int) {
-	JSONResponse(w, statusCode, map[string]string{"error": message})
+// SendError sends err as the JSON error envelope, or as RFC 7807
+// problem+json when the client asks for it. Errors that are not an
+// *apierror.Error become a generic internal error. Causes are logged
+// with the request and never sent to the client.
+func SendError(w http.ResponseWriter, r *http.Request, err error) {
+	e := apierror.From(err)
+	if e.Err != nil {
+		level := slog.LevelWarn
+		if e.Status == http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		logging.FromContext(r.Context()).Log(r.Context(), level, e.Message,
+			"method", r.Method, "path", logging.Path(r), "code", e.Code, "err", e.Err)
+	}
+
+	resp := apierror.Response{
+		Code:      e.Code,
+		Message:   e.Message,
+		Details:   e.Details,
+		RequestID: logging.RequestID(r.Context()),
+	}
+	if strings.Contains(r.Header.Get("Accept"), "application/problem+json") {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(e.Status)
+		json.NewEncoder(w).Encode(apierror.Problem{
+			Type:     "about:blank",
+			Title:    http.StatusText(e.Status),
+			Status:   e.Status,
+			Detail:   e.Message,
+			Instance: r.URL.Path,
+			Response: resp,
+		})
+		return
+	}
+	JSONResponse(w, e.Status, resp)
 }
 
 // SendJSONResponse sends a JSON response with a success message
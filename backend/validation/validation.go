@@ -0,0 +1,244 @@
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"forum/apierror"
+)
+
+// Errors collects problems keyed by JSON field name. Only the first
+// problem found for each field is kept.
+type Errors map[string]string
+
+// Add records a problem with a field
+func (e Errors) Add(field, message string) {
+	if _, exists := e[field]; !exists {
+		e[field] = message
+	}
+}
+
+// Err returns nil when there are no problems, otherwise a validation
+// *apierror.Error listing every field in its details
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = field + " " + e[field]
+	}
+	return apierror.Validation(strings.Join(parts, "; "), e)
+}
+
+// Struct checks every field of the struct v points to against its
+// `validate` tag. See Var for the supported rules.
+func (e Errors) Struct(v any) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: Struct called with %T", v))
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		rules, ok := f.Tag.Lookup("validate")
+		if !ok || !f.IsExported() {
+			continue
+		}
+		e.check(jsonName(f), rv.Field(i), rules)
+	}
+}
+
+// Var checks a single value against comma separated rules:
+//
+//	required    not zero; strings must contain more than whitespace
+//	min=N       at least N characters, items, or a value of at least N
+//	max=N       at most N characters, items, or a value of at most N
+//	oneof=a b   one of the space separated values
+//	email       a plain email address
+//	username    3-30 letters, digits, underscores or hyphens
+//	password    8-72 bytes with at least one letter and one digit
+//
+// Rules other than required are skipped for empty values.
+func (e Errors) Var(field string, value any, rules string) {
+	e.check(field, reflect.ValueOf(value), rules)
+}
+
+// Struct validates v and returns the resulting error, if any
+func Struct(v any) error {
+	errs := Errors{}
+	errs.Struct(v)
+	return errs.Err()
+}
+
+// ruleProblem describes what is wrong with using a rule on values of type
+// t, or returns "". A problem is a mistake in a validate tag, not in the
+// value being checked.
+func ruleProblem(name, param string, t reflect.Type) string {
+	switch name {
+	case "required":
+		return ""
+	case "min", "max":
+		if _, err := strconv.Atoi(param); err != nil {
+			return "needs a whole number"
+		}
+		switch t.Kind() {
+		case reflect.String, reflect.Slice, reflect.Array, reflect.Map,
+			reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			return ""
+		}
+	case "oneof":
+		if len(strings.Fields(param)) == 0 {
+			return "needs at least one value"
+		}
+		return ""
+	case "email", "username", "password":
+		if t.Kind() == reflect.String {
+			return ""
+		}
+	default:
+		return "is unknown"
+	}
+	return "cannot check a " + t.Kind().String()
+}
+
+func (e Errors) check(field string, v reflect.Value, rules string) {
+	for v.IsValid() && v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			v = reflect.Value{}
+			break
+		}
+		v = v.Elem()
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+		if name == "required" {
+			if isEmpty(v) {
+				e.Add(field, "is required")
+				return
+			}
+			continue
+		}
+		if isEmpty(v) {
+			continue
+		}
+		if msg := apply(name, param, v); msg != "" {
+			e.Add(field, msg)
+			return
+		}
+	}
+}
+
+func isEmpty(v reflect.Value) bool {
+	if !v.IsValid() {
+		return true
+	}
+	if v.Kind() == reflect.String {
+		return strings.TrimSpace(v.String()) == ""
+	}
+	return v.IsZero()
+}
+
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{3,30}$`)
+
+// apply runs one rule and returns a message describing the problem, or ""
+func apply(name, param string, v reflect.Value) string {
+	if problem := ruleProblem(name, param, v.Type()); problem != "" {
+		panic(fmt.Sprintf("validation: rule %s=%s %s", name, param, problem))
+	}
+	switch name {
+	case "min", "max":
+		limit, _ := strconv.Atoi(param)
+		n, unit := measure(v)
+		if name == "min" && n < limit {
+			return fmt.Sprintf("must be at least %d%s", limit, unit)
+		}
+		if name == "max" && n > limit {
+			return fmt.Sprintf("must be at most %d%s", limit, unit)
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		allowed := strings.Fields(param)
+		for _, a := range allowed {
+			if s == a {
+				return ""
+			}
+		}
+		return "must be one of: " + strings.Join(allowed, ", ")
+	case "email":
+		s := v.String()
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s || len(s) > 254 || !strings.Contains(s[strings.LastIndex(s, "@"):], ".") {
+			return "must be a valid email address"
+		}
+	case "username":
+		if !usernamePattern.MatchString(v.String()) {
+			return "must be 3-30 characters using letters, digits, underscores or hyphens"
+		}
+	case "password":
+		return passwordProblem(v.String())
+	}
+	return ""
+}
+
+// measure returns the length of strings and collections, or the value of numbers
+func measure(v reflect.Value) (int, string) {
+	switch v.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(v.String()), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return v.Len(), " items"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(v.Uint()), ""
+	}
+	panic(fmt.Sprintf("validation: min/max on %s", v.Kind()))
+}
+
+// passwordProblem enforces a minimum strength. bcrypt ignores input past
+// 72 bytes, so longer passwords are rejected rather than silently truncated.
+func passwordProblem(password string) string {
+	if len(password) < 8 {
+		return "must be at least 8 characters"
+	}
+	if len(password) > 72 {
+		return "must be at most 72 bytes"
+	}
+	var letter, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !letter || !digit {
+		return "must contain at least one letter and one digit"
+	}
+	return ""
+}
+
+// jsonName is the name a field has in request and response bodies
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return f.Name
+	}
+	return name
+}
@@ -0,0 +1,233 @@
+package validation
+
+import (
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"forum/apierror"
+)
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		value any
+		rules string
+		want  string // "" when valid
+	}{
+		{"required string", "", "required", "is required"},
+		{"required whitespace", " \t\n", "required", "is required"},
+		{"required set", "x", "required", ""},
+		{"required zero int", 0, "required", "is required"},
+		{"required int", 3, "required", ""},
+		{"required nil slice", []int(nil), "required", "is required"},
+		{"required nil pointer", (*string)(nil), "required", "is required"},
+		{"required pointer", ptr("x"), "required", ""},
+
+		{"min string", "ab", "min=3", "must be at least 3 characters"},
+		{"min string ok", "abc", "min=3", ""},
+		{"min counts runes", "ééé", "min=3", ""},
+		{"max string", "abcd", "max=3", "must be at most 3 characters"},
+		{"max string ok", "abc", "max=3", ""},
+		{"max counts runes", "ééé", "max=3", ""},
+		{"max pointer", ptr("abcd"), "max=3", "must be at most 3 characters"},
+		{"min slice", []int{1}, "min=2", "must be at least 2 items"},
+		{"max slice", []int{1, 2, 3}, "max=2", "must be at most 2 items"},
+		{"max slice ok", []string{"a", "b"}, "max=2", ""},
+		{"min int", 4, "min=5", "must be at least 5"},
+		{"max int", 6, "max=5", "must be at most 5"},
+		{"max int ok", 5, "max=5", ""},
+		{"max uint", uint(6), "max=5", "must be at most 5"},
+		{"min skipped when empty", "", "min=3", ""},
+
+		{"oneof", "delete", "oneof=anonymize delete", ""},
+		{"oneof other", "erase", "oneof=anonymize delete", "must be one of: anonymize, delete"},
+		{"oneof int", 2, "oneof=1 2 3", ""},
+
+		{"email", "alice@example.com", "email", ""},
+		{"email no at", "alice.example.com", "email", "must be a valid email address"},
+		{"email no dot in domain", "alice@localhost", "email", "must be a valid email address"},
+		{"email with name", "Alice <alice@example.com>", "email", "must be a valid email address"},
+		{"email too long", strings.Repeat("a", 250) + "@x.io", "email", "must be a valid email address"},
+
+		{"username", "alice_01-x", "username", ""},
+		{"username short", "al", "username", "must be 3-30 characters using letters, digits, underscores or hyphens"},
+		{"username long", strings.Repeat("a", 31), "username", "must be 3-30 characters using letters, digits, underscores or hyphens"},
+		{"username space", "al ice", "username", "must be 3-30 characters using letters, digits, underscores or hyphens"},
+		{"username non ascii", "alicé", "username", "must be 3-30 characters using letters, digits, underscores or hyphens"},
+
+		{"password", "passw0rd", "password", ""},
+		{"password short", "pass0rd", "password", "must be at least 8 characters"},
+		{"password 72 bytes", strings.Repeat("a", 71) + "1", "password", ""},
+		{"password 73 bytes", strings.Repeat("a", 72) + "1", "password", "must be at most 72 bytes"},
+		// 36 two-byte letters and a digit are 37 characters but 73 bytes
+		{"password counts bytes", strings.Repeat("é", 36) + "1", "password", "must be at most 72 bytes"},
+		{"password no digit", "password", "password", "must contain at least one letter and one digit"},
+		{"password no letter", "12345678", "password", "must contain at least one letter and one digit"},
+		{"password unicode letter", "пароль12", "password", ""},
+
+		{"first failure wins", "a", "min=3,username", "must be at least 3 characters"},
+		{"required stops", "", "required,min=3", "is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errs := Errors{}
+			errs.Var("field", tt.value, tt.rules)
+			if got := errs["field"]; got != tt.want {
+				t.Errorf("%q with %q = %q, want %q", tt.value, tt.rules, got, tt.want)
+			}
+		})
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+func TestStruct(t *testing.T) {
+	type body struct {
+		Name  string  `json:"name" validate:"required,max=5"`
+		Email string  `json:"email,omitempty" validate:"email"`
+		Tags  []int   `json:"tags" validate:"max=2"`
+		Bio   *string `validate:"max=3"`
+		Note  string  `json:"note"`
+		skip  string  `validate:"required"`
+	}
+
+	if err := Struct(&body{Name: "alice"}); err != nil {
+		t.Errorf("valid body: %v", err)
+	}
+
+	err := Struct(body{Name: "alexandra", Email: "nope", Tags: []int{1, 2, 3}, Bio: ptr("long")})
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Struct returned %v, want an *apierror.Error", err)
+	}
+	want := map[string]string{
+		"name":  "must be at most 5 characters",
+		"email": "must be a valid email address",
+		"tags":  "must be at most 2 items",
+		"Bio":   "must be at most 3 characters",
+	}
+	if got := apiErr.Details.(map[string]any)["fields"]; !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %v, want %v", got, want)
+	}
+	if msg := "Bio must be at most 3 characters; email must be a valid email address; name must be at most 5 characters; tags must be at most 2 items"; apiErr.Message != msg {
+		t.Errorf("message = %q, want %q", apiErr.Message, msg)
+	}
+}
+
+func TestBadRulesPanic(t *testing.T) {
+	for _, rules := range []string{"requird", "min=x", "oneof=", "email"} {
+		t.Run(rules, func(t *testing.T) {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("rules %q did not panic", rules)
+				}
+			}()
+			value := any("x")
+			if rules == "email" {
+				value = 3
+			}
+			Errors{}.Var("field", value, rules)
+		})
+	}
+}
+
+// basicTypes are the field types a validate tag may meet, by name
+var basicTypes = map[string]reflect.Type{
+	"string": reflect.TypeFor[string](),
+	"bool":   reflect.TypeFor[bool](),
+	"int":    reflect.TypeFor[int](),
+	"int64":  reflect.TypeFor[int64](),
+	"uint":   reflect.TypeFor[uint](),
+}
+
+// fieldType approximates the type of a struct field from its source.
+// Named types other than basicTypes are treated as structs.
+func fieldType(expr ast.Expr) reflect.Type {
+	switch e := expr.(type) {
+	case *ast.Ident:
+		if t, ok := basicTypes[e.Name]; ok {
+			return t
+		}
+	case *ast.StarExpr:
+		return fieldType(e.X)
+	case *ast.ArrayType:
+		if e.Len == nil {
+			return reflect.SliceOf(fieldType(e.Elt))
+		}
+		return reflect.ArrayOf(1, fieldType(e.Elt))
+	case *ast.MapType:
+		return reflect.MapOf(fieldType(e.Key), fieldType(e.Value))
+	}
+	return reflect.TypeFor[struct{}]()
+}
+
+// TestEveryValidateTag checks the validate tags of every struct in the
+// module, models and request bodies declared in handlers alike, so that a
+// typo in a rule fails here rather than panicking on a request
+func TestEveryValidateTag(t *testing.T) {
+	fset := token.NewFileSet()
+	tags := 0
+	modelTags := 0
+	err := filepath.WalkDir("..", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == "node_modules" || strings.HasPrefix(d.Name(), ".")) && path != ".." {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			st, ok := n.(*ast.StructType)
+			if !ok {
+				return true
+			}
+			for _, field := range st.Fields.List {
+				if field.Tag == nil {
+					continue
+				}
+				tag, err := strconv.Unquote(field.Tag.Value)
+				if err != nil {
+					t.Errorf("%s: %v", fset.Position(field.Pos()), err)
+					continue
+				}
+				rules, ok := reflect.StructTag(tag).Lookup("validate")
+				if !ok {
+					continue
+				}
+				tags++
+				if filepath.Base(filepath.Dir(path)) == "models" {
+					modelTags++
+				}
+				typ := fieldType(field.Type)
+				for _, rule := range strings.Split(rules, ",") {
+					name, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+					if problem := ruleProblem(name, param, typ); problem != "" {
+						t.Errorf("%s: rule %q %s", fset.Position(field.Pos()), rule, problem)
+					}
+				}
+			}
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if modelTags == 0 || tags == modelTags {
+		t.Errorf("found %d validate tags, %d of them in models; the walk is missing files", tags, modelTags)
+	}
+}
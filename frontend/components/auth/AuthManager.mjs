@@ -16,7 +16,7 @@ export class AuthManager {
      */
     async checkAuthStatus() {
         try {
-            const user = await ApiUtils.get('/api/user', true);
+            const user = await ApiUtils.get('/api/v1/me', true);
             this.currentUser = user;
             this.isAuthenticated = true;
             return true;
@@ -35,10 +35,10 @@ export class AuthManager {
      */
     async login(email, password) {
         try {
-            const result = await ApiUtils.post('/api/login', { email, password }, true);
+            const result = await ApiUtils.post('/api/v1/login', { email, password }, true);
             
             // Fetch user data after successful login
-            const user = await ApiUtils.get('/api/user', true);
+            const user = await ApiUtils.get('/api/v1/me', true);
             this.currentUser = user;
             this.isAuthenticated = true;
             
@@ -55,7 +55,7 @@ export class AuthManager {
      */
     async register(formData) {
         try {
-            await ApiUtils.post('/api/register', formData, true, true);
+            await ApiUtils.post('/api/v1/register', formData, true, true);
             
             // Auto-login after registration
             const email = formData.get('email');
@@ -74,7 +74,7 @@ export class AuthManager {
      */
     async logout() {
         try {
-            await ApiUtils.post('/api/logout', {}, true);
+            await ApiUtils.post('/api/v1/logout', {}, true);
             this.currentUser = null;
             this.isAuthenticated = false;
             return true;
@@ -108,25 +108,28 @@ export class AuthManager {
     validateRegistrationData(formData) {
         const { username, email, password, confirmPassword } = formData;
         
-        // Regex patterns
-        const usernameRegex = /^[a-zA-Z0-9_-]{3,20}$/;
+        // Mirrors the server's username, email and password rules
+        const usernameRegex = /^[a-zA-Z0-9_-]{3,30}$/;
         const emailRegex = /^[^\s@]+@[^\s@]+\.[^\s@]+$/;
-        const passwordRegex = /^[a-zA-Z0-9!@#$%^&*()_+\-=[\]{};':"\\|,.<>/?]{6,}$/;
 
         if (!username || !email || !password || !confirmPassword) {
             return { valid: false, error: 'All fields are required.' };
         }
 
         if (!usernameRegex.test(username)) {
-            return { valid: false, error: 'Username must be 3–20 characters, no spaces or special characters.' };
+            return { valid: false, error: 'Username must be 3–30 characters using letters, digits, underscores or hyphens.' };
         }
 
         if (!emailRegex.test(email)) {
             return { valid: false, error: 'Invalid email format.' };
         }
 
-        if (!passwordRegex.test(password)) {
-            return { valid: false, error: 'Password must be at least 6 characters and safe.' };
+        if (password.length < 8 || new TextEncoder().encode(password).length > 72) {
+            return { valid: false, error: 'Password must be 8–72 characters long.' };
+        }
+
+        if (!/\p{L}/u.test(password) || !/\p{Nd}/u.test(password)) {
+            return { valid: false, error: 'Password must contain at least one letter and one digit.' };
         }
 
         if (password !== confirmPassword) {
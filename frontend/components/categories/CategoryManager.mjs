@@ -17,7 +17,7 @@ export class CategoryManager {
      */
     async renderCategories() {
         try {
-            this.categories = await ApiUtils.get("/api/categories");
+            this.categories = await ApiUtils.get("/api/v1/categories");
             this.renderCategoryList();
             this.setupCategoryFilters();
         } catch (error) {
@@ -125,7 +125,7 @@ export class CategoryManager {
      */
     async loadCategoriesForDropdown() {
         try {
-            const categories = await ApiUtils.get('/api/categories');
+            const categories = await ApiUtils.get('/api/v1/categories');
             const menu = document.getElementById("dropdownMenu");
             
             if (!menu) return;
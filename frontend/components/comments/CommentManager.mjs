@@ -30,11 +30,15 @@ export class CommentManager {
         const username = comment.username || comment.UserName;
         const avatarUrl = comment.avatar_url || comment.ProfileAvatar;
 
-        // Build comment actions - only show reactions for top-level comments
+        // Build comment actions - replies get reactions but no reply button
         let commentActions = '';
         if (isReply) {
-            // Reply comments only show timestamp, no reactions or reply button
-            commentActions = '';
+            commentActions = `
+                <div class="comment-actions">
+                    <button class="reaction-btn comment-like-btn" data-id="${comment.id}" data-target="reply"><i class="fas fa-thumbs-up"></i></button>
+                    <button class="reaction-btn comment-dislike-btn" data-id="${comment.id}" data-target="reply"><i class="fas fa-thumbs-down"></i></button>
+                </div>
+            `;
         } else {
             // Top-level comments show reactions and reply button
             commentActions = `
@@ -163,12 +167,11 @@ export class CommentManager {
      */
     async handleTopLevelCommentSubmit(form, content, postId) {
         const commentData = {
-            post_id: parseInt(postId),
             content: content
         };
 
         try {
-            const result = await ApiUtils.post('/api/comments/create', commentData, true);
+            const result = await ApiUtils.post(`/api/v1/posts/${parseInt(postId)}/comments`, commentData, true);
 
             // Clear the textarea
             form.querySelector('textarea').value = '';
@@ -195,14 +198,13 @@ export class CommentManager {
      */
     async handleReplySubmit(form, content, parentCommentId) {
         const replyData = {
-            parent_comment_id: parseInt(parentCommentId),
             content: content
         };
 
         console.log('Submitting reply:', replyData); // Debug log
 
         try {
-            const result = await ApiUtils.post('/api/comment/reply/create', replyData, true);
+            const result = await ApiUtils.post(`/api/v1/comments/${parseInt(parentCommentId)}/replies`, replyData, true);
             console.log('Reply created successfully:', result); // Debug log
 
             // Find the post ID from the parent comment context
@@ -237,7 +239,7 @@ export class CommentManager {
      */
     async refreshPostComments(postId) {
         try {
-            const comments = await ApiUtils.get(`/api/comments/get?post_id=${postId}`);
+            const comments = await ApiUtils.get(`/api/v1/posts/${postId}/comments`);
 
             console.log(`Comments for post ${postId}:`, comments); // Debug log
 
@@ -478,7 +480,7 @@ export class CommentManager {
      */
     async getPostComments(postId) {
         try {
-            const comments = await ApiUtils.get(`/api/comments/get?post_id=${postId}`);
+            const comments = await ApiUtils.get(`/api/v1/posts/${postId}/comments`);
             return Array.isArray(comments) ? comments : [];
         } catch (error) {
             console.error(`Error getting comments for post ${postId}:`, error);
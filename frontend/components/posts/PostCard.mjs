@@ -271,7 +271,7 @@ export class PostCard {
                 </button>
                 <span class="comment-count-detailed">
                     <i class="fas fa-comment"></i>
-                    ${post.comments_count || 0} comments
+                    ${post.comment_count || 0} comments
                 </span>
             </div>
 
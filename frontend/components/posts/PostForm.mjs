@@ -99,7 +99,7 @@ export class PostForm {
         const submitFormData = this.buildSubmissionData(formData);
 
         try {
-            const result = await ApiUtils.post('/api/posts/create', submitFormData, true, true);
+            const result = await ApiUtils.post('/api/v1/posts', submitFormData, true, true);
 
             // Success! Reset form and notify parent
             this.resetForm();
@@ -137,14 +137,22 @@ export class PostForm {
      * @returns {Object} - Validation result
      */
     validateFormData(formData) {
-        if (!formData.title) {
+        if (!formData.title?.trim()) {
             return { valid: false, error: "Title is required." };
         }
 
-        if (!formData.content) {
+        if (formData.title.length > 200) {
+            return { valid: false, error: "Title must be at most 200 characters." };
+        }
+
+        if (!formData.content?.trim()) {
             return { valid: false, error: "Your post can't be empty!" };
         }
 
+        if (formData.content.length > 10000) {
+            return { valid: false, error: "Your post must be at most 10000 characters." };
+        }
+
         if (formData.selectedCategories.length === 0) {
             return { valid: false, error: "Please select at least one category." };
         }
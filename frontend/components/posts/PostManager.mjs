@@ -38,7 +38,7 @@ export class PostManager {
      */
     async fetchForumPosts() {
         try {
-            this.posts = await ApiUtils.get("/api/posts");
+            this.posts = await ApiUtils.get("/api/v1/posts", true);
             return this.posts;
         } catch (error) {
             console.error("Error fetching posts:", error);
@@ -101,7 +101,7 @@ export class PostManager {
             const postId = btn.getAttribute('data-id');
 
             try {
-                const comments = await ApiUtils.get(`/api/comments/get?post_id=${postId}`);
+                const comments = await ApiUtils.get(`/api/v1/posts/${postId}/comments`);
 
                 // Handle null or undefined responses by treating them as empty arrays
                 const commentsArray = comments && Array.isArray(comments) ? comments : [];
@@ -226,7 +226,7 @@ export class PostManager {
      */
     async updatePostComments(postId) {
         try {
-            const comments = await ApiUtils.get(`/api/comments/get?post_id=${postId}`);
+            const comments = await ApiUtils.get(`/api/v1/posts/${postId}/comments`);
 
             // Handle null or undefined responses by treating them as empty arrays
             const commentsArray = comments && Array.isArray(comments) ? comments : [];
@@ -271,7 +271,7 @@ export class PostManager {
 
         // If not cached, fetch from API
         try {
-            const post = await ApiUtils.get(`/api/posts/${postId}`);
+            const post = await ApiUtils.get(`/api/v1/posts/${postId}`, true);
             return post;
         } catch (error) {
             console.error('Error fetching post by ID:', error);
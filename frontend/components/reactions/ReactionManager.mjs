@@ -1,12 +1,17 @@
 /**
- * Reaction Manager - Handles likes and dislikes for posts and comments
+ * Reaction Manager - Handles likes, dislikes and the other reaction types
+ * for posts and comments
  */
 
 import { ApiUtils } from '../utils/ApiUtils.mjs';
 
 export class ReactionManager {
+    // Most IDs of each kind the batch endpoint accepts per request
+    static BATCH_LIMIT = 100;
+
     constructor(authModal) {
         this.authModal = authModal;
+        this.reactionTypes = null;
         this.setupGlobalEventListeners();
     }
 
@@ -19,6 +24,7 @@ export class ReactionManager {
             const dislikeBtn = event.target.closest(".dislike-btn");
             const commentLikeBtn = event.target.closest(".comment-like-btn");
             const commentDislikeBtn = event.target.closest(".comment-dislike-btn");
+            const emojiBtn = event.target.closest(".emoji-reaction-btn");
 
             if (likeBtn) {
                 await this.handlePostReaction(likeBtn, "like");
@@ -39,21 +45,30 @@ export class ReactionManager {
                 await this.handleCommentReaction(commentDislikeBtn, "dislike");
                 return;
             }
+
+            if (emojiBtn) {
+                if (emojiBtn.dataset.target !== 'post') {
+                    await this.handleCommentReaction(emojiBtn, emojiBtn.dataset.type);
+                } else {
+                    await this.handlePostReaction(emojiBtn, emojiBtn.dataset.type);
+                }
+                return;
+            }
         });
     }
 
     /**
-     * Handle post like/dislike reactions
+     * Handle post reactions
      * @param {HTMLElement} button - The clicked button
-     * @param {string} type - "like" or "dislike"
+     * @param {string} type - "like", "dislike" or another reaction type
      */
     async handlePostReaction(button, type) {
         const postID = button.dataset.id;
         
         try {
             const result = await ApiUtils.post(
-                `/api/likes/toggle`, 
-                { post_id: parseInt(postID), type }, 
+                `/api/v1/posts/${parseInt(postID)}/reactions`, 
+                { type }, 
                 true
             );
             
@@ -70,17 +85,18 @@ export class ReactionManager {
     }
 
     /**
-     * Handle comment like/dislike reactions
-     * @param {HTMLElement} button - The clicked button
-     * @param {string} type - "like" or "dislike"
+     * Handle comment and reply reactions
+     * @param {HTMLElement} button - The clicked button, with data-target="reply" on replies
+     * @param {string} type - "like", "dislike" or another reaction type
      */
     async handleCommentReaction(button, type) {
         const commentID = button.dataset.id;
+        const collection = button.dataset.target === 'reply' ? 'replies' : 'comments';
         
         try {
             const result = await ApiUtils.post(
-                `/api/likes/toggle`, 
-                { comment_id: parseInt(commentID), type }, 
+                `/api/v1/${collection}/${parseInt(commentID)}/reactions`, 
+                { type }, 
                 true
             );
             
@@ -100,75 +116,162 @@ export class ReactionManager {
      * Load and display likes/dislikes for all posts
      */
     async loadPostsLikes() {
-        const reactionBtns = document.querySelectorAll(".reaction-btn");
+        const buttons = [...document.querySelectorAll(".like-btn, .dislike-btn")];
+        const { posts } = await this.fetchReactionBatch(this.collectIds(buttons), []);
+        const types = await this.getReactionTypes();
 
-        for (const btn of reactionBtns) {
-            const postId = btn.getAttribute('data-id');
-            
-            // Skip if this is a comment reaction button
-            if (btn.classList.contains('comment-like-btn') || btn.classList.contains('comment-dislike-btn')) {
-                continue;
-            }
-            
-            try {
-                const result = await ApiUtils.get(`/api/likes/reactions?post_id=${postId}`);
-
-                if (btn.classList.contains('like-btn')) {
-                    let span = btn.querySelector(".like-count");
-                    if (!span) {
-                        span = document.createElement("span");
-                        span.className = "like-count";
-                        btn.appendChild(span);
-                    }
-                    span.textContent = `${result.likes === 0 ? '' : result.likes + ' '}Likes`;
+        for (const btn of buttons) {
+            const result = posts[btn.getAttribute('data-id')];
+            if (!result) continue;
+
+            if (btn.classList.contains('like-btn')) {
+                let span = btn.querySelector(".like-count");
+                if (!span) {
+                    span = document.createElement("span");
+                    span.className = "like-count";
+                    btn.appendChild(span);
                 }
+                span.textContent = `${result.likes === 0 ? '' : result.likes + ' '}Likes`;
+                btn.classList.toggle('liked', result.viewer_reaction === 'like');
+            }
 
-                if (btn.classList.contains('dislike-btn')) {
-                    let span = btn.querySelector(".dislike-count");
-                    if (!span) {
-                        span = document.createElement("span");
-                        span.className = "dislike-count";
-                        btn.appendChild(span);
-                    }
-                    span.textContent = `${result.dislikes === 0 ? '' : result.dislikes + ' '}Dislikes`;
+            if (btn.classList.contains('dislike-btn')) {
+                let span = btn.querySelector(".dislike-count");
+                if (!span) {
+                    span = document.createElement("span");
+                    span.className = "dislike-count";
+                    btn.appendChild(span);
                 }
-            } catch (error) {
-                console.error(`Error loading reactions for post ${postId}:`, error);
+                span.textContent = `${result.dislikes === 0 ? '' : result.dislikes + ' '}Dislikes`;
+                btn.classList.toggle('disliked', result.viewer_reaction === 'dislike');
+                this.renderEmojiReactions(btn, 'post', types, result);
             }
         }
     }
 
     /**
-     * Load and display likes/dislikes for all comments
+     * Load and display likes/dislikes for all comments and replies
      */
     async loadCommentsLikes() {
-        const reactionBtns = document.querySelectorAll(".comment-actions .reaction-btn");
+        const buttons = [...document.querySelectorAll(".comment-actions .reaction-btn")];
+        const isReply = btn => btn.dataset.target === 'reply';
+        const { comments, replies } = await this.fetchReactionBatch(
+            [],
+            this.collectIds(buttons.filter(btn => !isReply(btn))),
+            this.collectIds(buttons.filter(isReply))
+        );
+        const types = await this.getReactionTypes();
 
-        for (const btn of reactionBtns) {
-            const commentId = btn.getAttribute('data-id');
-            
+        for (const btn of buttons) {
+            const result = (isReply(btn) ? replies : comments)[btn.getAttribute('data-id')];
+            if (!result) continue;
+
+            if (btn.classList.contains('comment-like-btn')) {
+                // Clear existing content and add new count
+                const icon = btn.querySelector('i');
+                btn.innerHTML = '';
+                btn.appendChild(icon);
+                btn.insertAdjacentHTML("beforeend", ` ${result.likes === 0 ? '' : result.likes}`);
+                btn.classList.toggle('liked', result.viewer_reaction === 'like');
+            }
+
+            if (btn.classList.contains('comment-dislike-btn')) {
+                // Clear existing content and add new count
+                const icon = btn.querySelector('i');
+                btn.innerHTML = '';
+                btn.appendChild(icon);
+                btn.insertAdjacentHTML("beforeend", ` ${result.dislikes === 0 ? '' : result.dislikes}`);
+                btn.classList.toggle('disliked', result.viewer_reaction === 'dislike');
+                this.renderEmojiReactions(btn, isReply(btn) ? 'reply' : 'comment', types, result);
+            }
+        }
+    }
+
+    /**
+     * Enabled reaction types other than like and dislike, fetched once
+     * @returns {Object[]} - [{ name, emoji }] in display order
+     */
+    async getReactionTypes() {
+        if (!this.reactionTypes) {
             try {
-                const result = await ApiUtils.get(`/api/likes/reactions?comment_id=${commentId}`);
-
-                if (btn.classList.contains('comment-like-btn')) {
-                    // Clear existing content and add new count
-                    const icon = btn.querySelector('i');
-                    btn.innerHTML = '';
-                    btn.appendChild(icon);
-                    btn.insertAdjacentHTML("beforeend", ` ${result.likes === 0 ? '' : result.likes}`);
-                }
-                
-                if (btn.classList.contains('comment-dislike-btn')) {
-                    // Clear existing content and add new count
-                    const icon = btn.querySelector('i');
-                    btn.innerHTML = '';
-                    btn.appendChild(icon);
-                    btn.insertAdjacentHTML("beforeend", ` ${result.dislikes === 0 ? '' : result.dislikes}`);
-                }
+                const types = await ApiUtils.get('/api/v1/reactions/types');
+                this.reactionTypes = types.filter(t => t.name !== 'like' && t.name !== 'dislike');
+            } catch (error) {
+                console.error('Error loading reaction types:', error);
+                return [];
+            }
+        }
+        return this.reactionTypes;
+    }
+
+    /**
+     * Show one button per extra reaction type after a dislike button, with
+     * its count and whether the viewer gave it
+     * @param {HTMLElement} dislikeBtn - The post or comment dislike button
+     * @param {string} target - "post", "comment" or "reply"
+     * @param {Object[]} types - Types from getReactionTypes
+     * @param {Object} result - Reaction counts from the batch endpoint
+     */
+    renderEmojiReactions(dislikeBtn, target, types, result) {
+        let chips = dislikeBtn.nextElementSibling;
+        if (!chips || !chips.classList.contains('emoji-reactions')) {
+            chips = document.createElement('span');
+            chips.className = 'emoji-reactions';
+            dislikeBtn.after(chips);
+        }
+
+        const counts = result.counts || {};
+        const mine = result.viewer_reactions || [];
+        chips.innerHTML = '';
+        for (const type of types) {
+            const chip = document.createElement('button');
+            chip.className = 'emoji-reaction-btn';
+            chip.dataset.id = dislikeBtn.dataset.id;
+            chip.dataset.target = target;
+            chip.dataset.type = type.name;
+            chip.title = type.name;
+            chip.textContent = `${type.emoji || type.name}${counts[type.name] ? ' ' + counts[type.name] : ''}`;
+            chip.classList.toggle('reacted', mine.includes(type.name));
+            chips.appendChild(chip);
+        }
+    }
+
+    /**
+     * Unique numeric IDs from the data-id attribute of each button
+     * @param {HTMLElement[]} buttons - Reaction buttons
+     * @returns {number[]}
+     */
+    collectIds(buttons) {
+        const ids = buttons.map(btn => parseInt(btn.getAttribute('data-id')));
+        return [...new Set(ids.filter(id => id > 0))];
+    }
+
+    /**
+     * Fetch counts and the viewer's own reactions for many posts, comments
+     * and replies in as few requests as the batch limit allows
+     * @param {number[]} postIds - Post IDs
+     * @param {number[]} commentIds - Comment IDs
+     * @param {number[]} replyIds - Reply IDs
+     * @returns {Object} - { posts, comments, replies } keyed by ID
+     */
+    async fetchReactionBatch(postIds, commentIds, replyIds = []) {
+        const batch = { posts: {}, comments: {}, replies: {} };
+
+        for (let i = 0; i < Math.max(postIds.length, commentIds.length, replyIds.length); i += ReactionManager.BATCH_LIMIT) {
+            try {
+                const result = await ApiUtils.post('/api/v1/reactions/batch', {
+                    post_ids: postIds.slice(i, i + ReactionManager.BATCH_LIMIT),
+                    comment_ids: commentIds.slice(i, i + ReactionManager.BATCH_LIMIT),
+                    reply_ids: replyIds.slice(i, i + ReactionManager.BATCH_LIMIT)
+                }, true);
+                Object.assign(batch.posts, result.posts);
+                Object.assign(batch.comments, result.comments);
+                Object.assign(batch.replies, result.replies);
             } catch (error) {
-                console.error(`Error loading reactions for comment ${commentId}:`, error);
+                console.error('Error loading reactions:', error);
             }
         }
+        return batch;
     }
 
     /**
@@ -178,7 +281,7 @@ export class ReactionManager {
      */
     async getPostReactions(postId) {
         try {
-            const result = await ApiUtils.get(`/api/likes/reactions?post_id=${postId}`);
+            const result = await ApiUtils.get(`/api/v1/posts/${postId}/reactions`);
             return {
                 likes: result.likes || 0,
                 dislikes: result.dislikes || 0
@@ -196,7 +299,7 @@ export class ReactionManager {
      */
     async getCommentReactions(commentId) {
         try {
-            const result = await ApiUtils.get(`/api/likes/reactions?comment_id=${commentId}`);
+            const result = await ApiUtils.get(`/api/v1/comments/${commentId}/reactions`);
             return {
                 likes: result.likes || 0,
                 dislikes: result.dislikes || 0
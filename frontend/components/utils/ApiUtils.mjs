@@ -2,9 +2,90 @@
  * API utility functions for the forum application
  */
 
+/**
+ * Error codes returned in the `code` field of API error responses
+ */
+export const ErrorCodes = Object.freeze({
+    BAD_REQUEST: 'bad_request',
+    VALIDATION_FAILED: 'validation_failed',
+    UNAUTHORIZED: 'unauthorized',
+    FORBIDDEN: 'forbidden',
+    NOT_FOUND: 'not_found',
+    METHOD_NOT_ALLOWED: 'method_not_allowed',
+    CONFLICT: 'conflict',
+    PAYLOAD_TOO_LARGE: 'payload_too_large',
+    UNSUPPORTED_MEDIA_TYPE: 'unsupported_media_type',
+    UNAVAILABLE: 'unavailable',
+    INTERNAL_ERROR: 'internal_error',
+    NETWORK_ERROR: 'network_error'
+});
+
+/**
+ * Error thrown for failed API requests
+ */
+export class ApiError extends Error {
+    /**
+     * @param {number} status - HTTP status code (0 for network failures)
+     * @param {string} code - One of ErrorCodes
+     * @param {string} message - Human readable message
+     * @param {any} details - Extra data, e.g. { fields: { username: '...' } }
+     * @param {string} requestId - Server request ID, useful in bug reports
+     */
+    constructor(status, code, message, details = null, requestId = '') {
+        super(message);
+        this.name = 'ApiError';
+        this.status = status;
+        this.code = code;
+        this.details = details;
+        this.requestId = requestId;
+    }
+}
+
 export class ApiUtils {
     static BASE_URL = 'http://localhost:8080';
 
+    /**
+     * Sends a request and turns error responses into an ApiError
+     * @param {string} endpoint - API endpoint
+     * @param {RequestInit} options - fetch options
+     * @returns {Promise<{response: Response, data: any}>} - Response and parsed body
+     */
+    static async request(endpoint, options) {
+        let response;
+        try {
+            response = await fetch(`${this.BASE_URL}${endpoint}`, options);
+        } catch (e) {
+            throw new ApiError(0, ErrorCodes.NETWORK_ERROR, 'Could not reach the server. Check your connection.');
+        }
+
+        const responseText = await response.text();
+        let data = responseText;
+        try {
+            data = responseText ? JSON.parse(responseText) : null;
+        } catch (e) {
+            // Non-JSON body; keep the raw text
+        }
+
+        if (!response.ok) {
+            throw this.toApiError(response.status, data);
+        }
+        return { response, data };
+    }
+
+    /**
+     * Builds an ApiError from an error response body
+     * @param {number} status - HTTP status code
+     * @param {any} body - Parsed response body
+     * @returns {ApiError}
+     */
+    static toApiError(status, body) {
+        if (body && typeof body === 'object' && body.code) {
+            return new ApiError(status, body.code, body.message, body.details, body.request_id);
+        }
+        const message = typeof body === 'string' && body ? body : `HTTP error! Status: ${status}`;
+        return new ApiError(status, ErrorCodes.INTERNAL_ERROR, message);
+    }
+
     /**
      * Makes a GET request to the API
      * @param {string} endpoint - API endpoint
@@ -23,13 +104,8 @@ export class ApiUtils {
             options.credentials = 'include';
         }
 
-        const response = await fetch(`${this.BASE_URL}${endpoint}`, options);
-        
-        if (!response.ok) {
-            throw new Error(`HTTP error! Status: ${response.status}`);
-        }
-
-        return await response.json();
+        const { data } = await this.request(endpoint, options);
+        return data;
     }
 
     /**
@@ -56,26 +132,86 @@ export class ApiUtils {
             options.credentials = 'include';
         }
 
-        const response = await fetch(`${this.BASE_URL}${endpoint}`, options);
-        
-        // Handle text responses for debugging
-        const responseText = await response.text();
-        let responseData;
-        
-        try {
-            responseData = JSON.parse(responseText);
-        } catch (e) {
-            if (!response.ok) {
-                throw new Error(`Server error: ${responseText}`);
-            }
-            responseData = responseText;
+        return await this.request(endpoint, options);
+    }
+
+    /**
+     * Makes a PUT request to the API with a JSON or form body
+     * @param {string} endpoint - API endpoint
+     * @param {any} data - Data to send
+     * @param {boolean} includeCredentials - Whether to include credentials
+     * @param {boolean} isFormData - Whether data is FormData
+     * @returns {Promise<any>} - Response data
+     */
+    static async put(endpoint, data, includeCredentials = false, isFormData = false) {
+        const options = {
+            method: 'PUT',
+            body: isFormData ? data : JSON.stringify(data)
+        };
+
+        if (!isFormData) {
+            options.headers = {
+                'Content-Type': 'application/json',
+            };
         }
 
-        if (!response.ok) {
-            throw new Error(responseData.error || `HTTP error! Status: ${response.status}`);
+        if (includeCredentials) {
+            options.credentials = 'include';
         }
 
-        return { response, data: responseData };
+        const { data: result } = await this.request(endpoint, options);
+        return result;
+    }
+
+    /**
+     * Makes a PATCH request to the API with a JSON body
+     * @param {string} endpoint - API endpoint
+     * @param {any} data - Fields to change
+     * @param {boolean} includeCredentials - Whether to include credentials
+     * @returns {Promise<any>} - Response data
+     */
+    static async patch(endpoint, data, includeCredentials = false) {
+        const options = {
+            method: 'PATCH',
+            headers: {
+                'Content-Type': 'application/json',
+            },
+            body: JSON.stringify(data)
+        };
+
+        if (includeCredentials) {
+            options.credentials = 'include';
+        }
+
+        const { data: result } = await this.request(endpoint, options);
+        return result;
+    }
+
+    /**
+     * Makes a DELETE request to the API
+     * @param {string} endpoint - API endpoint
+     * @param {boolean} includeCredentials - Whether to include credentials
+     * @param {any} data - Optional JSON body
+     * @returns {Promise<any>} - Response data
+     */
+    static async delete(endpoint, includeCredentials = false, data = undefined) {
+        const options = {
+            method: 'DELETE'
+        };
+
+        if (data !== undefined) {
+            options.headers = {
+                'Content-Type': 'application/json',
+            };
+            options.body = JSON.stringify(data);
+        }
+
+        if (includeCredentials) {
+            options.credentials = 'include';
+        }
+
+        const { data: result } = await this.request(endpoint, options);
+        return result;
     }
 
     /**
@@ -85,11 +221,23 @@ export class ApiUtils {
      */
     static handleError(error, context = '') {
         console.error(`Error in ${context}:`, error);
-        
-        if (error.message.includes('401')) {
-            return { requiresAuth: true, message: 'Please log in to continue.' };
+
+        switch (error.code) {
+            case ErrorCodes.UNAUTHORIZED:
+                return { requiresAuth: true, message: 'Please log in to continue.' };
+            case ErrorCodes.FORBIDDEN:
+                return { requiresAuth: false, message: error.message || 'You are not allowed to do that.' };
+            case ErrorCodes.VALIDATION_FAILED:
+                return { requiresAuth: false, message: error.message, fields: error.details?.fields || {} };
+            case ErrorCodes.NETWORK_ERROR:
+            case ErrorCodes.UNAVAILABLE:
+                return { requiresAuth: false, message: 'The server is unavailable. Please try again shortly.' };
+            case ErrorCodes.INTERNAL_ERROR: {
+                const ref = error.requestId ? ` (reference ${error.requestId})` : '';
+                return { requiresAuth: false, message: `Something went wrong on our side${ref}.` };
+            }
+            default:
+                return { requiresAuth: false, message: error.message };
         }
-        
-        return { requiresAuth: false, message: error.message };
     }
 }
@@ -166,14 +166,14 @@ export class CategoryView extends BaseView {
             let sortedPosts = [...categoryPosts];
             switch (filter) {
                 case 'popular':
-                    // Sort by likes (assuming we have likes data)
-                    sortedPosts.sort((a, b) => (b.likes || 0) - (a.likes || 0));
+                    // Sort by likes
+                    sortedPosts.sort((a, b) => (b.reactions?.likes || 0) - (a.reactions?.likes || 0));
                     break;
                 case 'trending':
                     // Sort by recent activity and engagement
                     sortedPosts.sort((a, b) => {
-                        const aScore = (a.likes || 0) + (a.comments_count || 0);
-                        const bScore = (b.likes || 0) + (b.comments_count || 0);
+                        const aScore = (a.reactions?.likes || 0) + (a.comment_count || 0);
+                        const bScore = (b.reactions?.likes || 0) + (b.comment_count || 0);
                         return bScore - aScore;
                     });
                     break;
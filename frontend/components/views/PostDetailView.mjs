@@ -3,6 +3,7 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
 
 export class PostDetailView extends BaseView {
     constructor(app, params, query) {
@@ -64,7 +65,7 @@ export class PostDetailView extends BaseView {
 
             // Get additional data for the post
             const [reactions, comments] = await Promise.all([
-                this.app.getReactionManager().getPostReactions(post.id),
+                post.reactions || this.app.getReactionManager().getPostReactions(post.id),
                 this.app.getCommentManager().getPostComments(post.id)
             ]);
 
@@ -100,8 +101,8 @@ export class PostDetailView extends BaseView {
                     <i class="fas fa-arrow-left"></i> Back
                 </button>
                 <div class="post-actions">
-                    <button class="save-post-btn" title="Save Post">
-                        <i class="fas fa-bookmark"></i>
+                    <button class="save-post-btn${this.post.is_saved ? ' saved' : ''}" title="${this.post.is_saved ? 'Unsave Post' : 'Save Post'}">
+                        <i class="${this.post.is_saved ? 'fas' : 'far'} fa-bookmark"></i>
                     </button>
                     <button class="share-post-btn" title="Share Post">
                         <i class="fas fa-share"></i>
@@ -350,16 +351,27 @@ export class PostDetailView extends BaseView {
                 return;
             }
 
-            // This would typically toggle save status via API
-            console.log('Toggling save for post:', this.postId);
-            
+            const saved = !this.post.is_saved;
+            if (saved) {
+                await ApiUtils.put(`/api/v1/posts/${this.postId}/save`, {}, true);
+            } else {
+                await ApiUtils.delete(`/api/v1/posts/${this.postId}/save`, true);
+            }
+            this.post.is_saved = saved;
+
+            // Keep the cached listing in step so navigating back shows the new state
+            const cached = this.app.postManager.getPosts().find(p => p.id.toString() === this.postId.toString());
+            if (cached) {
+                cached.is_saved = saved;
+            }
+
             const saveBtn = document.querySelector('.save-post-btn');
             if (saveBtn) {
-                saveBtn.classList.toggle('saved');
+                saveBtn.classList.toggle('saved', saved);
+                saveBtn.title = saved ? 'Unsave Post' : 'Save Post';
                 const icon = saveBtn.querySelector('i');
                 if (icon) {
-                    icon.className = saveBtn.classList.contains('saved') ? 
-                        'fas fa-bookmark' : 'far fa-bookmark';
+                    icon.className = saved ? 'fas fa-bookmark' : 'far fa-bookmark';
                 }
             }
 
@@ -3,11 +3,13 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
 
 export class ProfileView extends BaseView {
     constructor(app, params, query) {
         super(app, params, query);
         this.user = null;
+        this.profile = null;
     }
 
     /**
@@ -36,6 +38,9 @@ export class ProfileView extends BaseView {
                 throw new Error('User data not available');
             }
 
+            // Public profile with activity counts
+            this.profile = await ApiUtils.get(`/api/v1/users/${encodeURIComponent(this.user.username)}`);
+
             // Create profile view
             await this.renderProfileContent(container);
 
@@ -68,35 +73,339 @@ export class ProfileView extends BaseView {
                 <div class="profile-info">
                     <h1>${this.user.username}</h1>
                     <p class="profile-email">${this.user.email}</p>
+                    <p class="profile-bio">${this.escape(this.user.bio || '')}</p>
                     <p class="profile-joined">Joined: ${this.formatDate(this.user.created_at)}</p>
+                    <p class="profile-stats">
+                        <span><strong>${this.profile.post_count}</strong> posts</span>
+                        <span><strong>${this.profile.comment_count}</strong> comments</span>
+                        <span><strong>${this.profile.reactions_received}</strong> reactions received</span>
+                    </p>
+                    <button class="edit-profile-btn"><i class="fas fa-pen"></i> Edit Profile</button>
                     </div>
             </div>
 
-            <div class="profile-details">
+            <div class="profile-tabs">
+                <button class="tab-btn active" data-tab="posts">Posts</button>
+                <button class="tab-btn" data-tab="comments">Comments</button>
+                <button class="tab-btn" data-tab="edit">Edit Profile</button>
+            </div>
+
+            <div class="tab-content active" data-tab="posts" id="profilePosts"></div>
+            <div class="tab-content" data-tab="comments" id="profileComments"></div>
+            <div class="tab-content" data-tab="edit">
                 <div class="profile-info-section">
                     <h3><i class="fas fa-user"></i> Profile Information</h3>
-                    <div class="profile-data">
+                    <form class="profile-edit-form">
+                        <div class="profile-field">
+                            <label for="profileUsername">Username</label>
+                            <input id="profileUsername" name="username" value="${this.user.username}">
+                        </div>
+                        <div class="profile-field">
+                            <label for="profileEmail">Email Address</label>
+                            <input id="profileEmail" name="email" type="email" value="${this.user.email}">
+                        </div>
+                        <div class="profile-field">
+                            <label for="profileBio">Bio</label>
+                            <textarea id="profileBio" name="bio" maxlength="500" rows="4">${this.escape(this.user.bio || '')}</textarea>
+                        </div>
+                        <div class="profile-field">
+                            <label for="profilePassword">Current Password (required to change email)</label>
+                            <input id="profilePassword" name="current_password" type="password" autocomplete="current-password">
+                        </div>
+                        <p class="profile-edit-error"></p>
+                        <button type="submit" class="edit-profile-btn">Save Changes</button>
+                    </form>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-image"></i> Avatar</h3>
+                    <form class="profile-avatar-form profile-edit-form">
                         <div class="profile-field">
-                            <label>Username</label>
-                            <div class="field-value">${this.user.username}</div>
+                            <label for="avatarFile">New Avatar (JPG, PNG or GIF, cropped to a centred square)</label>
+                            <input id="avatarFile" name="avatar" type="file" accept="image/jpeg,image/png,image/gif" required>
                         </div>
+                        <p class="profile-edit-error"></p>
+                        <button type="submit" class="edit-profile-btn">Upload Avatar</button>
+                        <button type="button" class="edit-profile-btn remove-avatar-btn">Remove Avatar</button>
+                    </form>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-key"></i> Change Password</h3>
+                    <form class="profile-password-form profile-edit-form">
                         <div class="profile-field">
-                            <label>Email Address</label>
-                            <div class="field-value">${this.user.email}</div>
+                            <label for="currentPassword">Current Password</label>
+                            <input id="currentPassword" name="current_password" type="password" autocomplete="current-password" required>
                         </div>
                         <div class="profile-field">
-                            <label>Member Since</label>
-                            <div class="field-value">${this.formatDate(this.user.created_at)}</div>
+                            <label for="newPassword">New Password</label>
+                            <input id="newPassword" name="new_password" type="password" autocomplete="new-password" required>
                         </div>
+                        <p class="profile-edit-error"></p>
+                        <button type="submit" class="edit-profile-btn">Change Password</button>
+                    </form>
+                </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-download"></i> Download Your Data</h3>
+                    <div class="profile-edit-form profile-export">
+                        <p>A ZIP archive of your profile, posts, comments, reactions, saved posts, sessions and images.</p>
+                        <p class="profile-edit-error"></p>
+                        <button type="button" class="edit-profile-btn export-data-btn">Export My Data</button>
                     </div>
                 </div>
+
+                <div class="profile-info-section">
+                    <h3><i class="fas fa-trash"></i> Delete Account</h3>
+                    <form class="profile-delete-form profile-edit-form">
+                        <div class="profile-field">
+                            <label for="deleteMode">Your posts and comments</label>
+                            <select id="deleteMode" name="mode">
+                                <option value="anonymize">Keep them, shown as [deleted]</option>
+                                <option value="delete">Delete them</option>
+                            </select>
+                        </div>
+                        <div class="profile-field">
+                            <label for="deletePassword">Password</label>
+                            <input id="deletePassword" name="password" type="password" autocomplete="current-password" required>
+                        </div>
+                        <p class="profile-edit-error"></p>
+                        <button type="submit" class="edit-profile-btn">Delete Account</button>
+                    </form>
+                </div>
             </div>
         `;
 
         container.appendChild(profileContent);
+
+        this.setupEventListeners(profileContent, container);
+        this.setupAccountForms(profileContent);
+        await this.loadPosts();
     }
 
+    /**
+     * Setup tab switching and the edit form
+     * @param {HTMLElement} profileContent - Profile element
+     * @param {HTMLElement} container - Container element, re-rendered after saving
+     */
+    setupEventListeners(profileContent, container) {
+        const tabBtns = profileContent.querySelectorAll('.tab-btn');
+        const showTab = (tab) => {
+            tabBtns.forEach(b => b.classList.toggle('active', b.getAttribute('data-tab') === tab));
+            profileContent.querySelectorAll('.tab-content').forEach(c => {
+                c.classList.toggle('active', c.getAttribute('data-tab') === tab);
+            });
+            if (tab === 'posts') this.loadPosts();
+            if (tab === 'comments') this.loadComments();
+        };
+
+        tabBtns.forEach(btn => {
+            btn.addEventListener('click', () => showTab(btn.getAttribute('data-tab')));
+        });
+        profileContent.querySelector('.profile-info .edit-profile-btn')
+            .addEventListener('click', () => showTab('edit'));
+
+        const form = profileContent.querySelector('.profile-edit-form');
+        form.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            const errorEl = form.querySelector('.profile-edit-error');
+            errorEl.textContent = '';
 
+            // Only send what changed
+            const changes = {};
+            for (const field of ['username', 'email', 'bio']) {
+                const value = form.elements[field].value.trim();
+                if (value !== (this.user[field] || '')) {
+                    changes[field] = value;
+                }
+            }
+            if (changes.email !== undefined) {
+                changes.current_password = form.elements.current_password.value;
+            }
+            if (Object.keys(changes).length === 0) {
+                showTab('posts');
+                return;
+            }
+
+            try {
+                const user = await ApiUtils.patch('/api/v1/me', changes, true);
+                this.app.getAuthManager().currentUser = user;
+                await this.render(container);
+            } catch (error) {
+                errorEl.textContent = ApiUtils.handleError(error, 'updating profile').message;
+            }
+        });
+    }
+
+    /**
+     * Setup the avatar, change password, export and delete account forms
+     * @param {HTMLElement} profileContent - Profile element
+     */
+    setupAccountForms(profileContent) {
+        const avatarForm = profileContent.querySelector('.profile-avatar-form');
+        const showAvatar = (result) => {
+            this.user.avatar_url = result.avatar_url;
+            profileContent.querySelector('.avatar-large').src = `${ApiUtils.BASE_URL}${result.avatar_urls['256']}`;
+        };
+        avatarForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            const errorEl = avatarForm.querySelector('.profile-edit-error');
+            errorEl.textContent = '';
+            try {
+                showAvatar(await ApiUtils.put('/api/v1/me/avatar', new FormData(avatarForm), true, true));
+                avatarForm.reset();
+            } catch (error) {
+                errorEl.textContent = ApiUtils.handleError(error, 'uploading avatar').message;
+            }
+        });
+        avatarForm.querySelector('.remove-avatar-btn').addEventListener('click', async () => {
+            const errorEl = avatarForm.querySelector('.profile-edit-error');
+            errorEl.textContent = '';
+            try {
+                showAvatar(await ApiUtils.delete('/api/v1/me/avatar', true));
+            } catch (error) {
+                errorEl.textContent = ApiUtils.handleError(error, 'removing avatar').message;
+            }
+        });
+
+        const passwordForm = profileContent.querySelector('.profile-password-form');
+        passwordForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            const errorEl = passwordForm.querySelector('.profile-edit-error');
+            errorEl.textContent = '';
+            try {
+                const result = await ApiUtils.post('/api/v1/me/password', {
+                    current_password: passwordForm.elements.current_password.value,
+                    new_password: passwordForm.elements.new_password.value
+                }, true);
+                passwordForm.reset();
+                errorEl.textContent = `Password changed. ${result.data.revoked_sessions} other session(s) logged out.`;
+            } catch (error) {
+                errorEl.textContent = ApiUtils.handleError(error, 'changing password').message;
+            }
+        });
+
+        const exportSection = profileContent.querySelector('.profile-export');
+        exportSection.querySelector('.export-data-btn').addEventListener('click', async () => {
+            const statusEl = exportSection.querySelector('.profile-edit-error');
+            statusEl.textContent = '';
+            try {
+                const dataExport = await ApiUtils.get('/api/v1/me/export', true);
+                if (dataExport.status === 'ready') {
+                    statusEl.textContent = `Download link valid until ${this.formatDate(dataExport.expires_at)}.`;
+                    window.location.href = `${ApiUtils.BASE_URL}${dataExport.download_url}`;
+                } else {
+                    statusEl.textContent = 'Your export is being prepared. Try again in a minute.';
+                }
+            } catch (error) {
+                statusEl.textContent = ApiUtils.handleError(error, 'exporting data').message;
+            }
+        });
+
+        const deleteForm = profileContent.querySelector('.profile-delete-form');
+        deleteForm.addEventListener('submit', async (e) => {
+            e.preventDefault();
+            const errorEl = deleteForm.querySelector('.profile-edit-error');
+            errorEl.textContent = '';
+            if (!confirm('Delete your account? Logging in again before the deletion date cancels it.')) {
+                return;
+            }
+            try {
+                const result = await ApiUtils.delete('/api/v1/me', true, {
+                    password: deleteForm.elements.password.value,
+                    mode: deleteForm.elements.mode.value
+                });
+                alert(`Your account will be deleted on ${this.formatDate(result.delete_after)}.`);
+                const authManager = this.app.getAuthManager();
+                authManager.currentUser = null;
+                authManager.isAuthenticated = false;
+                window.location.href = '/';
+            } catch (error) {
+                errorEl.textContent = ApiUtils.handleError(error, 'deleting account').message;
+            }
+        });
+    }
+
+    /**
+     * Load the user's posts into the posts tab
+     */
+    async loadPosts() {
+        const list = document.getElementById('profilePosts');
+        if (!list) return;
+
+        try {
+            const posts = await ApiUtils.get(`/api/v1/users/${encodeURIComponent(this.user.username)}/posts?limit=20`, true);
+            if (!posts || posts.length === 0) {
+                list.innerHTML = '';
+                list.appendChild(this.createEmptyStateElement('No posts yet.', 'fas fa-file-alt'));
+                return;
+            }
+            list.innerHTML = posts.map(post => `
+                <div class="profile-activity-item" data-post-id="${post.id}">
+                    <strong>${this.escape(post.title)}</strong>
+                    <span class="profile-activity-date">${this.formatDate(post.created_at)}</span>
+                </div>
+            `).join('');
+            this.setupActivityLinks(list);
+        } catch (error) {
+            console.error('Error loading profile posts:', error);
+            list.innerHTML = '';
+            list.appendChild(this.createErrorElement('Failed to load posts.', () => this.loadPosts()));
+        }
+    }
+
+    /**
+     * Load the user's comments and replies into the comments tab
+     */
+    async loadComments() {
+        const list = document.getElementById('profileComments');
+        if (!list) return;
+
+        try {
+            const comments = await ApiUtils.get(`/api/v1/users/${encodeURIComponent(this.user.username)}/comments?limit=20`);
+            if (!comments || comments.length === 0) {
+                list.innerHTML = '';
+                list.appendChild(this.createEmptyStateElement('No comments yet.', 'fas fa-comment'));
+                return;
+            }
+            list.innerHTML = comments.map(comment => `
+                <div class="profile-activity-item" data-post-id="${comment.post_id}">
+                    <span>${this.escape(comment.content)}</span>
+                    <span class="profile-activity-date">
+                        ${comment.type === 'reply' ? 'Replied' : 'Commented'} on ${this.escape(comment.post_title)} · ${this.formatDate(comment.created_at)}
+                    </span>
+                </div>
+            `).join('');
+            this.setupActivityLinks(list);
+        } catch (error) {
+            console.error('Error loading profile comments:', error);
+            list.innerHTML = '';
+            list.appendChild(this.createErrorElement('Failed to load comments.', () => this.loadComments()));
+        }
+    }
+
+    /**
+     * Open the post of each activity item when it is clicked
+     * @param {HTMLElement} list - Tab content element
+     */
+    setupActivityLinks(list) {
+        list.querySelectorAll('.profile-activity-item').forEach(item => {
+            item.addEventListener('click', () => {
+                this.app.router.navigate(`/post/${item.getAttribute('data-post-id')}`);
+            });
+        });
+    }
+
+    /**
+     * Escape text for use in HTML
+     * @param {string} text - Text to escape
+     * @returns {string} - Escaped text
+     */
+    escape(text) {
+        const div = document.createElement('div');
+        div.textContent = text;
+        return div.innerHTML;
+    }
 
     /**
      * Format date for display
@@ -3,6 +3,8 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
+import { PostCard } from '../posts/PostCard.mjs';
 
 export class SavedView extends BaseView {
     constructor(app, params, query) {
@@ -56,10 +58,8 @@ export class SavedView extends BaseView {
                 <p>Posts you've bookmarked for later</p>
             </div>
 
-            <div class="saved-filters">
-                <button class="filter-btn active" data-filter="all">All Saved</button>
-                <button class="filter-btn" data-filter="recent">Recently Saved</button>
-                <button class="filter-btn" data-filter="category">By Category</button>
+            <div class="saved-filters" id="savedFilters">
+                <button class="filter-btn active" data-collection="">All Saved</button>
             </div>
 
             <div class="saved-content">
@@ -71,46 +71,93 @@ export class SavedView extends BaseView {
 
         container.appendChild(savedContent);
 
-        // Setup event listeners
-        this.setupEventListeners();
+        // Add a filter button per collection
+        await this.renderCollectionFilters();
 
         // Load saved posts
-        await this.loadSavedPosts('all');
+        await this.loadSavedPosts('');
+    }
+
+    /**
+     * Render one filter button per saved post collection
+     */
+    async renderCollectionFilters() {
+        const filters = document.getElementById('savedFilters');
+        if (!filters) return;
+
+        try {
+            const collections = await ApiUtils.get('/api/v1/me/saved/collections', true);
+            for (const collection of collections || []) {
+                const btn = document.createElement('button');
+                btn.className = 'filter-btn';
+                btn.setAttribute('data-collection', collection.name);
+                btn.textContent = `${collection.name} (${collection.post_count})`;
+                filters.appendChild(btn);
+            }
+        } catch (error) {
+            console.error('Error loading saved collections:', error);
+        }
+
+        this.setupEventListeners();
     }
 
     /**
      * Setup event listeners
      */
     setupEventListeners() {
-        const filterBtns = document.querySelectorAll('.filter-btn');
+        const filterBtns = document.querySelectorAll('.saved-filters .filter-btn');
         
         filterBtns.forEach(btn => {
             btn.addEventListener('click', () => {
-                const filter = btn.getAttribute('data-filter');
+                const collection = btn.getAttribute('data-collection');
                 
                 // Update active state
                 filterBtns.forEach(b => b.classList.remove('active'));
                 btn.classList.add('active');
                 
                 // Load filtered data
-                this.loadSavedPosts(filter);
+                this.loadSavedPosts(collection);
             });
         });
     }
 
     /**
      * Load saved posts
-     * @param {string} filter - Filter type
+     * @param {string} collection - Collection to show, or '' for all saved posts
      */
-    async loadSavedPosts(filter) {
+    async loadSavedPosts(collection) {
         const postsContainer = document.getElementById('savedPosts');
         if (!postsContainer) return;
+        this.collection = collection;
 
         try {
             postsContainer.innerHTML = '<div class="loading">Loading your saved posts...</div>';
 
-            // This would typically fetch saved posts from the API
-            // For now, we'll show a placeholder since saved posts functionality isn't implemented yet
+            const query = collection ? `?collection=${encodeURIComponent(collection)}&limit=50` : '?limit=50';
+            const saved = await ApiUtils.get(`/api/v1/me/saved${query}`, true);
+
+            if (saved && saved.length > 0) {
+                postsContainer.innerHTML = '';
+                for (const entry of saved) {
+                    const postCard = PostCard.create(entry.post);
+                    postCard.classList.add('saved-post-card');
+
+                    const removeBtn = document.createElement('button');
+                    removeBtn.className = 'remove-saved-btn';
+                    removeBtn.title = 'Remove from saved';
+                    removeBtn.innerHTML = '<i class="fas fa-bookmark"></i> Unsave';
+                    removeBtn.addEventListener('click', (e) => {
+                        e.stopPropagation();
+                        this.removeSavedPost(entry.post.id);
+                    });
+                    postCard.appendChild(removeBtn);
+
+                    PostCard.setupCommentToggle(postCard);
+                    postsContainer.appendChild(postCard);
+                }
+                return;
+            }
+
             postsContainer.innerHTML = `
                 <div class="empty-state">
                     <i class="fas fa-bookmark"></i>
@@ -133,7 +180,7 @@ export class SavedView extends BaseView {
             postsContainer.innerHTML = '';
             postsContainer.appendChild(this.createErrorElement(
                 'Failed to load saved posts.',
-                () => this.loadSavedPosts(filter)
+                () => this.loadSavedPosts(collection)
             ));
         }
     }
@@ -144,11 +191,16 @@ export class SavedView extends BaseView {
      */
     async removeSavedPost(postId) {
         try {
-            // This would typically make an API call to remove the saved post
-            console.log(`Removing saved post ${postId}`);
-            
+            await ApiUtils.delete(`/api/v1/posts/${postId}/save`, true);
+
+            // Keep the cached listing in step with the server
+            const cached = this.app.postManager.getPosts().find(p => p.id === postId);
+            if (cached) {
+                cached.is_saved = false;
+            }
+
             // Refresh the saved posts list
-            await this.loadSavedPosts('all');
+            await this.loadSavedPosts(this.collection || '');
             
         } catch (error) {
             console.error('Error removing saved post:', error);
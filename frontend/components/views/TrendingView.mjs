@@ -3,6 +3,7 @@
  */
 
 import { BaseView } from './BaseView.mjs';
+import { ApiUtils } from '../utils/ApiUtils.mjs';
 
 export class TrendingView extends BaseView {
     constructor(app, params, query) {
@@ -114,7 +115,7 @@ export class TrendingView extends BaseView {
     }
 
     /**
-     * Load trending posts based on engagement metrics
+     * Load trending posts ranked by hot score
      * @param {string} filter - Time filter
      */
     async loadTrendingPosts(filter) {
@@ -124,51 +125,9 @@ export class TrendingView extends BaseView {
         try {
             postsContainer.innerHTML = '<div class="loading">Loading trending posts...</div>';
 
-            // Fetch all posts first
-            const allPosts = await this.app.postManager.fetchForumPosts();
-
-            // Fetch engagement data for each post
-            const postsWithEngagement = await Promise.all(
-                allPosts.map(async (post) => {
-                    try {
-                        // Fetch likes/dislikes for the post
-                        const reactions = await this.app.getReactionManager().getPostReactions(post.id);
-
-                        // Fetch comments count
-                        const comments = await this.app.getCommentManager().getPostComments(post.id);
-
-                        // Calculate engagement score
-                        const likes = reactions?.likes || 0;
-                        const dislikes = reactions?.dislikes || 0;
-                        const commentsCount = Array.isArray(comments) ? comments.length : 0;
-
-                        // Engagement score: likes + comments - dislikes (weighted)
-                        const engagementScore = likes + (commentsCount * 2) - (dislikes * 0.5);
-
-                        return {
-                            ...post,
-                            likes,
-                            dislikes,
-                            commentsCount,
-                            engagementScore
-                        };
-                    } catch (error) {
-                        console.error(`Error fetching engagement for post ${post.id}:`, error);
-                        return {
-                            ...post,
-                            likes: 0,
-                            dislikes: 0,
-                            commentsCount: 0,
-                            engagementScore: 0
-                        };
-                    }
-                })
-            );
-
-            // Sort by engagement score and take top 10
-            const trendingPosts = postsWithEngagement
-                .sort((a, b) => b.engagementScore - a.engagementScore)
-                .slice(0, 10);
+            // Ranked and windowed on the server
+            const timeWindow = filter === 'today' ? 'day' : filter;
+            const trendingPosts = await ApiUtils.get(`/api/v1/trending?window=${timeWindow}&limit=10`);
 
             if (trendingPosts.length === 0) {
                 postsContainer.appendChild(this.createEmptyStateElement(
@@ -203,19 +162,19 @@ export class TrendingView extends BaseView {
                             <div class="action-buttons">
                                 <button class="reaction-btn like-btn" data-id="${post.id}">
                                     <i class="fas fa-thumbs-up"></i>
-                                    <span class="like-count">${post.likes}</span>
+                                    <span class="like-count">${post.like_count}</span>
                                 </button>
                                 <button class="reaction-btn dislike-btn" data-id="${post.id}">
                                     <i class="fas fa-thumbs-down"></i>
-                                    <span class="dislike-count">${post.dislikes}</span>
+                                    <span class="dislike-count">${post.dislike_count}</span>
                                 </button>
                                 <button class="reaction-btn comment-btn" data-id="${post.id}">
                                     <i class="fas fa-comment"></i>
-                                    <span class="comment-count">${post.commentsCount}</span>
+                                    <span class="comment-count">${post.comment_count}</span>
                                 </button>
                                 <div class="engagement-score">
                                     <i class="fas fa-fire"></i>
-                                    <span>${Math.round(post.engagementScore)}</span>
+                                    <span>${post.score.toFixed(2)}</span>
                                 </div>
                             </div>
                         </div>
@@ -275,7 +234,8 @@ export class TrendingView extends BaseView {
     }
 
     /**
-     * Load trending topics based on category usage
+     * Load trending topics. The ranking covers all time, so the filter
+     * is only used to retry
      * @param {string} filter - Time filter
      */
     async loadTrendingTopics(filter) {
@@ -285,47 +245,8 @@ export class TrendingView extends BaseView {
         try {
             topicsContainer.innerHTML = '<div class="loading">Loading popular topics...</div>';
 
-            // Fetch categories and posts to calculate trending topics
-            const [categories, posts] = await Promise.all([
-                this.app.getCategoryManager().getCategories(),
-                this.app.postManager.fetchForumPosts()
-            ]);
-
-            // Count posts per category
-            const categoryCount = {};
-            const categoryNames = {};
-
-            // Initialize category data
-            if (categories && Array.isArray(categories)) {
-                categories.forEach(category => {
-                    categoryCount[category.id] = 0;
-                    categoryNames[category.id] = category.name;
-                });
-            }
-
-            // Count posts in each category
-            if (posts && Array.isArray(posts)) {
-                posts.forEach(post => {
-                    if (post.category_ids && Array.isArray(post.category_ids)) {
-                        post.category_ids.forEach(categoryId => {
-                            if (categoryCount[categoryId] !== undefined) {
-                                categoryCount[categoryId]++;
-                            }
-                        });
-                    }
-                });
-            }
-
-            // Convert to array and sort by count
-            const trendingTopics = Object.entries(categoryCount)
-                .map(([categoryId, count]) => ({
-                    id: categoryId,
-                    name: categoryNames[categoryId] || `Category ${categoryId}`,
-                    count
-                }))
-                .filter(topic => topic.count > 0)
-                .sort((a, b) => b.count - a.count)
-                .slice(0, 8); // Top 8 trending topics
+            // Categories ranked by the hot scores of their posts
+            const trendingTopics = await ApiUtils.get('/api/v1/trending/categories?limit=8');
 
             if (trendingTopics.length === 0) {
                 topicsContainer.appendChild(this.createEmptyStateElement(
@@ -340,7 +261,7 @@ export class TrendingView extends BaseView {
                     
                     <div class="topic-info">
                         <span class="topic-name">${topic.name}</span>
-                        <span class="topic-count">${topic.count} post${topic.count !== 1 ? 's' : ''}</span>
+                        <span class="topic-count">${topic.post_count} post${topic.post_count !== 1 ? 's' : ''}</span>
                     </div>
                     <div class="topic-arrow">
                         <i class="fas fa-chevron-right"></i>